@@ -0,0 +1,261 @@
+// Package apikey provides issuance and authentication of API keys.
+//
+// The API key has the "<prefix>_<id>_<secret>" form. The prefix makes the key
+// identifiable, e.g. by secret scanners, the ID is used to look up the key in
+// the Store, and the secret is verified against the hash. Only the hash of the
+// secret is stored, so the plaintext key is available only once, at issuance.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/plainq/servekit/authkit/hashkit"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/idkit"
+)
+
+var (
+	// ErrKeyMalformed indicates that the API key does not have the expected form.
+	ErrKeyMalformed = errors.Join(errkit.ErrUnauthenticated, errors.New("api key is malformed"))
+
+	// ErrKeyRevoked indicates that the API key has been revoked.
+	ErrKeyRevoked = errors.Join(errkit.ErrUnauthenticated, errors.New("api key is revoked"))
+
+	// ErrKeyExpired indicates that the API key has been expired.
+	ErrKeyExpired = errors.Join(errkit.ErrUnauthenticated, errors.New("api key is expired"))
+
+	// ErrKeyInvalid indicates that the API key is unknown or its secret does not match.
+	ErrKeyInvalid = errors.Join(errkit.ErrUnauthenticated, errors.New("api key is invalid"))
+)
+
+// secretSize represents the number of random bytes in the key secret.
+const secretSize = 32
+
+// keyCtx represents a Key for context by which
+// the authenticated API key can be received from the context.
+const keyCtx ctxkit.Key = "ctx.apikey.key"
+
+// Key represents the issued API key. It never holds the plaintext secret.
+type Key struct {
+	ID         string
+	Name       string
+	Hash       string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	RevokedAt  time.Time
+}
+
+// HasScopes reports whether the key has all the given scopes.
+func (k *Key) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(k.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Store represents the storage of API keys.
+type Store interface {
+	// Create stores the new key.
+	Create(ctx context.Context, key *Key) error
+
+	// Get returns the key by the given ID.
+	// Returns errkit.ErrNotFound if there is no such key.
+	Get(ctx context.Context, id string) (*Key, error)
+
+	// Touch sets the last used time of the key.
+	Touch(ctx context.Context, id string, at time.Time) error
+
+	// Revoke sets the revocation time of the key.
+	// Returns errkit.ErrNotFound if there is no such key.
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+// Option represents a function type that modifies the Manager.
+type Option func(m *Manager)
+
+// WithPrefix sets the prefix of the issued keys. Default is "sk".
+func WithPrefix(prefix string) Option {
+	return func(m *Manager) { m.prefix = prefix }
+}
+
+// WithHasher sets the hasher of the key secrets. Default is the hashkit.SHA256Hasher, which is enough for
+// the random 256-bit secrets and cheap to check on each request, unlike the password hashers,
+// e.g. hashkit.BCryptHasher, which are meant for the low-entropy passwords.
+func WithHasher(hasher hashkit.Hasher) Option {
+	return func(m *Manager) { m.hasher = hasher }
+}
+
+// WithTouchInterval sets the minimal interval between the updates of the key last used time,
+// to avoid the store write on each request. Default is 1 minute.
+func WithTouchInterval(interval time.Duration) Option {
+	return func(m *Manager) { m.touchInterval = interval }
+}
+
+// Manager issues, authenticates and revokes API keys.
+type Manager struct {
+	store         Store
+	hasher        hashkit.Hasher
+	prefix        string
+	touchInterval time.Duration
+	now           func() time.Time
+}
+
+// NewManager returns a pointer to a new instance of Manager.
+func NewManager(store Store, options ...Option) *Manager {
+	m := Manager{
+		store:         store,
+		hasher:        hashkit.NewSHA256Hasher(),
+		prefix:        "sk",
+		touchInterval: time.Minute,
+		now:           time.Now,
+	}
+
+	for _, option := range options {
+		option(&m)
+	}
+
+	return &m
+}
+
+// IssueOption represents a function type that modifies the Key at issuance.
+type IssueOption func(k *Key)
+
+// WithScopes sets the scopes of the issued key.
+func WithScopes(scopes ...string) IssueOption {
+	return func(k *Key) { k.Scopes = scopes }
+}
+
+// WithExpiresAt sets the expiration time of the issued key.
+// By default, the key never expires.
+func WithExpiresAt(t time.Time) IssueOption {
+	return func(k *Key) { k.ExpiresAt = t }
+}
+
+// Issue generates a new key with the given name and stores its hash.
+// Returns the plaintext key, which must be handed to the client, and the stored Key.
+func (m *Manager) Issue(ctx context.Context, name string, options ...IssueOption) (string, *Key, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("generate secret: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	hash, hashErr := m.hasher.HashPassword(encoded)
+	if hashErr != nil {
+		return "", nil, fmt.Errorf("hash secret: %w", hashErr)
+	}
+
+	key := Key{
+		ID:        idkit.XID(),
+		Name:      name,
+		Hash:      hash,
+		CreatedAt: m.now().UTC(),
+	}
+
+	for _, option := range options {
+		option(&key)
+	}
+
+	if err := m.store.Create(ctx, &key); err != nil {
+		return "", nil, fmt.Errorf("create key: %w", err)
+	}
+
+	return m.prefix + "_" + key.ID + "_" + encoded, &key, nil
+}
+
+// Authenticate verifies the plaintext key and returns the stored Key.
+// Returned error wraps errkit.ErrUnauthenticated if the key is not valid.
+func (m *Manager) Authenticate(ctx context.Context, raw string) (*Key, error) {
+	id, secret, ok := m.parse(raw)
+	if !ok {
+		return nil, ErrKeyMalformed
+	}
+
+	key, getErr := m.store.Get(ctx, id)
+	if getErr != nil {
+		if errors.Is(getErr, errkit.ErrNotFound) {
+			return nil, ErrKeyInvalid
+		}
+
+		return nil, fmt.Errorf("get key: %w", getErr)
+	}
+
+	if err := m.hasher.CheckPassword(key.Hash, secret); err != nil {
+		if errors.Is(err, errkit.ErrPasswordIncorrect) {
+			return nil, ErrKeyInvalid
+		}
+
+		return nil, fmt.Errorf("check secret: %w", err)
+	}
+
+	now := m.now()
+
+	if !key.RevokedAt.IsZero() {
+		return nil, ErrKeyRevoked
+	}
+
+	if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+
+	if now.Sub(key.LastUsedAt) >= m.touchInterval {
+		if err := m.store.Touch(ctx, key.ID, now.UTC()); err != nil {
+			return nil, fmt.Errorf("touch key: %w", err)
+		}
+
+		key.LastUsedAt = now.UTC()
+	}
+
+	return key, nil
+}
+
+// Revoke revokes the key by the given ID.
+func (m *Manager) Revoke(ctx context.Context, id string) error {
+	if err := m.store.Revoke(ctx, id, m.now().UTC()); err != nil {
+		return fmt.Errorf("revoke key: %w", err)
+	}
+
+	return nil
+}
+
+// parse returns the ID and the secret of the plaintext key.
+func (m *Manager) parse(raw string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(raw, m.prefix+"_")
+	if !ok {
+		return "", "", false
+	}
+
+	id, secret, ok = strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
+
+// SetKey sets the authenticated key to the context.
+func SetKey(ctx context.Context, key *Key) context.Context {
+	return ctxkit.Set(ctx, keyCtx, key)
+}
+
+// KeyFromContext gets the authenticated key from the context.
+// The second return value reports whether the key is present.
+func KeyFromContext(ctx context.Context) (*Key, bool) {
+	key := ctxkit.Get[*Key](ctx, keyCtx)
+
+	return key, key != nil
+}
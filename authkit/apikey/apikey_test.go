@@ -0,0 +1,146 @@
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/authkit/hashkit"
+	"github.com/plainq/servekit/errkit"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newManager(store Store) *Manager {
+	return NewManager(store, WithPrefix("pq_live"))
+}
+
+func TestManager_hasher(t *testing.T) {
+	m := NewManager(NewMemoryStore(), WithHasher(hashkit.NewBCryptHasher(hashkit.WithCost(bcrypt.MinCost))))
+
+	raw, key, err := m.Issue(t.Context(), "ci")
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, key.Hash, td.HasPrefix("$2a$"))
+
+	got, err := m.Authenticate(t.Context(), raw)
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, got.ID, key.ID)
+}
+
+func TestManager(t *testing.T) {
+	m := newManager(NewMemoryStore())
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	raw, key, err := m.Issue(t.Context(), "ci", WithScopes("orders:read"), WithExpiresAt(now.Add(time.Hour)))
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, raw, td.HasPrefix("pq_live_"+key.ID+"_"))
+	td.Cmp(t, key.Hash, td.Not(td.Contains(strings.TrimPrefix(raw, "pq_live_"+key.ID+"_"))))
+
+	t.Run("Authenticate", func(t *testing.T) {
+		got, err := m.Authenticate(t.Context(), raw)
+		td.Require(t).CmpNoError(err)
+		td.Cmp(t, got.ID, key.ID)
+		td.Cmp(t, got.LastUsedAt, now.UTC())
+		td.CmpTrue(t, got.HasScopes("orders:read"))
+		td.CmpFalse(t, got.HasScopes("orders:write"))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		f := func(raw string, want error) {
+			t.Helper()
+
+			_, err := m.Authenticate(t.Context(), raw)
+			td.CmpErrorIs(t, err, want)
+			td.CmpErrorIs(t, err, errkit.ErrUnauthenticated)
+		}
+
+		f("garbage", ErrKeyMalformed)
+		f("pq_live_"+key.ID+"_wrong", ErrKeyInvalid)
+		f("pq_live_UNKNOWN_secret", ErrKeyInvalid)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		m.now = func() time.Time { return now.Add(2 * time.Hour) }
+		defer func() { m.now = func() time.Time { return now } }()
+
+		_, err := m.Authenticate(t.Context(), raw)
+		td.CmpErrorIs(t, err, ErrKeyExpired)
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		td.Require(t).CmpNoError(m.Revoke(t.Context(), key.ID))
+
+		_, err := m.Authenticate(t.Context(), raw)
+		td.CmpErrorIs(t, err, ErrKeyRevoked)
+		td.CmpErrorIs(t, m.Revoke(t.Context(), "UNKNOWN"), errkit.ErrNotFound)
+	})
+}
+
+func TestManager_Middleware(t *testing.T) {
+	m := newManager(NewMemoryStore())
+
+	raw, _, err := m.Issue(t.Context(), "ci", WithScopes("orders:read"))
+	td.Require(t).CmpNoError(err)
+
+	f := func(name string, handler http.Handler, header, value string, want int) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if header != "" {
+				r.Header.Set(header, value)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			td.Cmp(t, w.Code, want)
+		})
+	}
+
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if _, ok := KeyFromContext(r.Context()); !ok {
+			panic("key is missing in the context")
+		}
+	})
+
+	read := m.Middleware(WithRequiredScopes("orders:read"))(next)
+	write := m.Middleware(WithRequiredScopes("orders:write"))(next)
+
+	f("Header", read, "X-API-Key", raw, http.StatusOK)
+	f("Bearer", read, "Authorization", "Bearer "+raw, http.StatusOK)
+	f("Missing", read, "", "", http.StatusUnauthorized)
+	f("Invalid", read, "X-API-Key", raw+"x", http.StatusUnauthorized)
+	f("Scope", write, "X-API-Key", raw, http.StatusForbidden)
+}
+
+func TestManager_UnaryInterceptor(t *testing.T) {
+	m := newManager(NewMemoryStore())
+
+	raw, _, err := m.Issue(t.Context(), "ci", WithScopes("orders:read"))
+	td.Require(t).CmpNoError(err)
+
+	interceptor := m.UnaryInterceptor(WithMethodScopes(map[string][]string{"/svc/Write": {"orders:write"}}))
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	ctx := metadata.NewIncomingContext(t.Context(), metadata.Pairs("x-api-key", raw))
+
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Read"}, handler)
+	td.CmpNoError(t, err)
+	td.Cmp(t, resp, "ok")
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Write"}, handler)
+	td.Cmp(t, status.Code(err), codes.PermissionDenied)
+
+	_, err = interceptor(t.Context(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Read"}, handler)
+	td.Cmp(t, status.Code(err), codes.Unauthenticated)
+}
@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plainq/servekit/errkit"
+)
+
+// Compilation time check that MemoryStore implements the Store.
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore implements Store interface keeping keys in memory.
+// Suitable for tests and single-instance deployments.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore returns a pointer to a new instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := MemoryStore{
+		keys: make(map[string]Key),
+	}
+
+	return &s
+}
+
+func (s *MemoryStore) Create(_ context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok {
+		return errkit.ErrAlreadyExists
+	}
+
+	stored := *key
+	stored.Scopes = slices.Clone(key.Scopes)
+	s.keys[key.ID] = stored
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, errkit.ErrNotFound
+	}
+
+	key.Scopes = slices.Clone(key.Scopes)
+
+	return &key, nil
+}
+
+func (s *MemoryStore) Touch(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[id]; ok {
+		key.LastUsedAt = at
+		s.keys[id] = key
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Revoke(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return errkit.ErrNotFound
+	}
+
+	key.RevokedAt = at
+	s.keys[id] = key
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/grpckit"
+	"github.com/plainq/servekit/httpkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	// ErrKeyMissing indicates that the request does not carry an API key.
+	ErrKeyMissing = errors.Join(errkit.ErrUnauthenticated, errors.New("api key is missing"))
+
+	// ErrScopeMissing indicates that the API key does not have the required scopes.
+	ErrScopeMissing = errors.Join(errkit.ErrUnauthorized, errors.New("api key does not have the required scopes"))
+)
+
+// AuthOptions represents the options of the API key middleware and interceptors.
+type AuthOptions struct {
+	header       string
+	metadataKey  string
+	scopes       []string
+	methodScopes map[string][]string
+}
+
+// AuthOption represents a function type that modifies AuthOptions.
+type AuthOption func(o *AuthOptions)
+
+// WithHeader sets the name of the header to extract the key from. Default is "X-API-Key".
+// The key is also accepted as the bearer token in the Authorization header.
+func WithHeader(name string) AuthOption {
+	return func(o *AuthOptions) { o.header = name }
+}
+
+// WithMetadataKey sets the gRPC metadata key to extract the key from. Default is "x-api-key".
+// The key is also accepted as the bearer token in the authorization metadata.
+func WithMetadataKey(key string) AuthOption {
+	return func(o *AuthOptions) { o.metadataKey = strings.ToLower(key) }
+}
+
+// WithRequiredScopes sets the scopes the key must have to be accepted.
+func WithRequiredScopes(scopes ...string) AuthOption {
+	return func(o *AuthOptions) { o.scopes = scopes }
+}
+
+// WithMethodScopes sets the scopes the key must have to call the gRPC methods.
+// The key is the full method name. Scopes set by the WithRequiredScopes are required as well.
+func WithMethodScopes(methods map[string][]string) AuthOption {
+	return func(o *AuthOptions) { o.methodScopes = methods }
+}
+
+func newAuthOptions(options ...AuthOption) *AuthOptions {
+	o := AuthOptions{
+		header:      "X-API-Key",
+		metadataKey: "x-api-key",
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &o
+}
+
+// Middleware returns the middleware which authenticates the request by the API key.
+// Authenticated key can be received from the request context by the KeyFromContext.
+// Requests without a valid key are rejected with 401, and with 403 if the key lacks the required scopes.
+func (m *Manager) Middleware(options ...AuthOption) httpkit.Middleware {
+	o := newAuthOptions(options...)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get(o.header)
+			if raw == "" {
+				raw, _ = httpkit.BearerToken(r.Header.Get("Authorization"))
+			}
+
+			key, err := m.authenticate(r.Context(), raw, o.scopes)
+			if err != nil {
+				httpkit.ErrorHTTP(w, r, err, httpkit.WithStatus(statusCode(err)))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(SetKey(r.Context(), key)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// UnaryInterceptor returns the gRPC unary server interceptor which authenticates the call by the API key.
+// Authenticated key can be received from the context by the KeyFromContext.
+func (m *Manager) UnaryInterceptor(options ...AuthOption) grpckit.UnaryInterceptor {
+	o := newAuthOptions(options...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		key, err := m.authenticateCall(ctx, o, info.FullMethod)
+		if err != nil {
+			return grpckit.ErrorGRPC[any](ctx, err)
+		}
+
+		return handler(SetKey(ctx, key), req)
+	}
+}
+
+// StreamInterceptor returns the gRPC stream server interceptor.
+// See UnaryInterceptor for the details.
+func (m *Manager) StreamInterceptor(options ...AuthOption) grpckit.StreamInterceptor {
+	o := newAuthOptions(options...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key, err := m.authenticateCall(ss.Context(), o, info.FullMethod)
+		if err != nil {
+			_, err = grpckit.ErrorGRPC[any](ss.Context(), err)
+			return err
+		}
+
+		return handler(srv, grpckit.WrapServerStream(SetKey(ss.Context(), key), ss))
+	}
+}
+
+// authenticateCall authenticates the gRPC call by the key from the incoming metadata.
+func (m *Manager) authenticateCall(ctx context.Context, o *AuthOptions, method string) (*Key, error) {
+	var raw string
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(o.metadataKey); len(values) > 0 {
+			raw = values[0]
+		} else if values := md.Get("authorization"); len(values) > 0 {
+			raw, _ = httpkit.BearerToken(values[0])
+		}
+	}
+
+	return m.authenticate(ctx, raw, slices.Concat(o.scopes, o.methodScopes[method]))
+}
+
+// authenticate authenticates the key and checks its scopes.
+func (m *Manager) authenticate(ctx context.Context, raw string, scopes []string) (*Key, error) {
+	if raw == "" {
+		return nil, ErrKeyMissing
+	}
+
+	key, err := m.Authenticate(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.HasScopes(scopes...) {
+		return nil, ErrScopeMissing
+	}
+
+	return key, nil
+}
+
+// statusCode returns the HTTP status code of the authentication error.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, errkit.ErrUnauthenticated):
+		return http.StatusUnauthorized
+
+	case errors.Is(err, errkit.ErrUnauthorized):
+		return http.StatusForbidden
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
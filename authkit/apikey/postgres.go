@@ -0,0 +1,144 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/plainq/servekit/dbkit/pgkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// Compilation time check that PostgresStore implements the Store.
+var _ Store = (*PostgresStore)(nil)
+
+const postgresSchema = `
+create table if not exists api_keys
+(
+    id           text        not null primary key,
+    name         text        not null,
+    hash         text        not null,
+    scopes       text[]      not null default '{}',
+    created_at   timestamptz not null,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz
+);`
+
+// PostgresStore implements Store interface on top of the pgkit.Conn.
+type PostgresStore struct {
+	conn *pgkit.Conn
+}
+
+// NewPostgresStore returns a pointer to a new instance of PostgresStore.
+// Creates the api_keys table if it does not exist.
+func NewPostgresStore(conn *pgkit.Conn) (*PostgresStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, postgresSchema); err != nil {
+		return nil, fmt.Errorf("postgres: create api_keys table: %w", err)
+	}
+
+	s := PostgresStore{
+		conn: conn,
+	}
+
+	return &s, nil
+}
+
+func (s *PostgresStore) Create(ctx context.Context, key *Key) error {
+	const query = `
+		insert into api_keys (id, name, hash, scopes, created_at, expires_at)
+		values ($1, $2, $3, $4, $5, $6)
+		on conflict (id) do nothing;`
+
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	tag, err := s.conn.Exec(ctx, query, key.ID, key.Name, key.Hash, scopes, key.CreatedAt, nullTime(key.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("postgres: create key: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errkit.ErrAlreadyExists
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id string) (*Key, error) {
+	const query = `
+		select id, name, hash, scopes, created_at, expires_at, last_used_at, revoked_at
+		from api_keys
+		where id = $1;`
+
+	var (
+		key                              Key
+		expiresAt, lastUsedAt, revokedAt *time.Time
+	)
+
+	if err := s.conn.QueryRow(ctx, query, id).Scan(
+		&key.ID, &key.Name, &key.Hash, &key.Scopes, &key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errkit.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("postgres: get key: %w", err)
+	}
+
+	key.ExpiresAt = fromNullTime(expiresAt)
+	key.LastUsedAt = fromNullTime(lastUsedAt)
+	key.RevokedAt = fromNullTime(revokedAt)
+
+	return &key, nil
+}
+
+func (s *PostgresStore) Touch(ctx context.Context, id string, at time.Time) error {
+	const query = `update api_keys set last_used_at = $1 where id = $2;`
+
+	if _, err := s.conn.Exec(ctx, query, at, id); err != nil {
+		return fmt.Errorf("postgres: touch key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	const query = `update api_keys set revoked_at = coalesce(revoked_at, $1) where id = $2;`
+
+	tag, err := s.conn.Exec(ctx, query, at, id)
+	if err != nil {
+		return fmt.Errorf("postgres: revoke key: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errkit.ErrNotFound
+	}
+
+	return nil
+}
+
+// nullTime returns nil for zero time.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// fromNullTime returns zero time for nil.
+func fromNullTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return t.UTC()
+}
@@ -0,0 +1,160 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/plainq/servekit/dbkit/litekit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// Compilation time check that SQLiteStore implements the Store.
+var _ Store = (*SQLiteStore)(nil)
+
+const sqliteSchema = `
+create table if not exists api_keys
+(
+    id           text    not null primary key,
+    name         text    not null,
+    hash         text    not null,
+    scopes       text    not null default '[]',
+    created_at   integer not null,
+    expires_at   integer,
+    last_used_at integer,
+    revoked_at   integer
+);`
+
+// SQLiteStore implements Store interface on top of the litekit.Conn.
+type SQLiteStore struct {
+	conn *litekit.Conn
+}
+
+// NewSQLiteStore returns a pointer to a new instance of SQLiteStore.
+// Creates the api_keys table if it does not exist.
+func NewSQLiteStore(conn *litekit.Conn) (*SQLiteStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, fmt.Errorf("sqlite: create api_keys table: %w", err)
+	}
+
+	s := SQLiteStore{
+		conn: conn,
+	}
+
+	return &s, nil
+}
+
+func (s *SQLiteStore) Create(ctx context.Context, key *Key) error {
+	const query = `
+		insert into api_keys (id, name, hash, scopes, created_at, expires_at)
+		values (?, ?, ?, ?, ?, ?)
+		on conflict (id) do nothing;`
+
+	scopes, marshalErr := json.Marshal(key.Scopes)
+	if marshalErr != nil {
+		return fmt.Errorf("sqlite: marshal scopes: %w", marshalErr)
+	}
+
+	result, execErr := s.conn.ExecContext(ctx, query,
+		key.ID, key.Name, key.Hash, string(scopes), key.CreatedAt.UnixNano(), nullUnixNano(key.ExpiresAt),
+	)
+	if execErr != nil {
+		return fmt.Errorf("sqlite: create key: %w", execErr)
+	}
+
+	affected, affectedErr := result.RowsAffected()
+	if affectedErr != nil {
+		return fmt.Errorf("sqlite: create key: %w", affectedErr)
+	}
+
+	if affected == 0 {
+		return errkit.ErrAlreadyExists
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) (*Key, error) {
+	const query = `
+		select id, name, hash, scopes, created_at, expires_at, last_used_at, revoked_at
+		from api_keys
+		where id = ?;`
+
+	var (
+		key                              Key
+		scopes                           string
+		createdAt                        int64
+		expiresAt, lastUsedAt, revokedAt sql.NullInt64
+	)
+
+	if err := s.conn.QueryRowContext(ctx, query, id).Scan(
+		&key.ID, &key.Name, &key.Hash, &scopes, &createdAt, &expiresAt, &lastUsedAt, &revokedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errkit.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("sqlite: get key: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, fmt.Errorf("sqlite: unmarshal scopes: %w", err)
+	}
+
+	key.CreatedAt = time.Unix(0, createdAt).UTC()
+	key.ExpiresAt = fromNullUnixNano(expiresAt)
+	key.LastUsedAt = fromNullUnixNano(lastUsedAt)
+	key.RevokedAt = fromNullUnixNano(revokedAt)
+
+	return &key, nil
+}
+
+func (s *SQLiteStore) Touch(ctx context.Context, id string, at time.Time) error {
+	const query = `update api_keys set last_used_at = ? where id = ?;`
+
+	if _, err := s.conn.ExecContext(ctx, query, at.UnixNano(), id); err != nil {
+		return fmt.Errorf("sqlite: touch key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	const query = `update api_keys set revoked_at = coalesce(revoked_at, ?) where id = ?;`
+
+	result, execErr := s.conn.ExecContext(ctx, query, at.UnixNano(), id)
+	if execErr != nil {
+		return fmt.Errorf("sqlite: revoke key: %w", execErr)
+	}
+
+	affected, affectedErr := result.RowsAffected()
+	if affectedErr != nil {
+		return fmt.Errorf("sqlite: revoke key: %w", affectedErr)
+	}
+
+	if affected == 0 {
+		return errkit.ErrNotFound
+	}
+
+	return nil
+}
+
+// nullUnixNano returns the Unix time in nanoseconds or NULL for zero time.
+func nullUnixNano(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: !t.IsZero()}
+}
+
+// fromNullUnixNano returns the time from the Unix time in nanoseconds or zero time for NULL.
+func fromNullUnixNano(v sql.NullInt64) time.Time {
+	if !v.Valid {
+		return time.Time{}
+	}
+
+	return time.Unix(0, v.Int64).UTC()
+}
@@ -0,0 +1,51 @@
+package apikey
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/dbkit/litekit"
+	"github.com/plainq/servekit/errkit"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	conn, err := litekit.New(filepath.Join(t.TempDir(), "test.db"))
+	td.Require(t).CmpNoError(err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	store, err := NewSQLiteStore(conn)
+	td.Require(t).CmpNoError(err)
+
+	now := time.Now().UTC()
+
+	key := Key{
+		ID:        "KEY",
+		Name:      "ci",
+		Hash:      "hash",
+		Scopes:    []string{"orders:read"},
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	td.Require(t).CmpNoError(store.Create(t.Context(), &key))
+	td.CmpErrorIs(t, store.Create(t.Context(), &key), errkit.ErrAlreadyExists)
+
+	got, err := store.Get(t.Context(), "KEY")
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, got, &key)
+
+	td.CmpNoError(t, store.Touch(t.Context(), "KEY", now.Add(time.Minute)))
+	td.CmpNoError(t, store.Revoke(t.Context(), "KEY", now.Add(2*time.Minute)))
+
+	got, err = store.Get(t.Context(), "KEY")
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, got.LastUsedAt, now.Add(time.Minute))
+	td.Cmp(t, got.RevokedAt, now.Add(2*time.Minute))
+
+	_, err = store.Get(t.Context(), "UNKNOWN")
+	td.CmpErrorIs(t, err, errkit.ErrNotFound)
+	td.CmpErrorIs(t, store.Revoke(t.Context(), "UNKNOWN", now), errkit.ErrNotFound)
+}
@@ -0,0 +1,306 @@
+// Package authz provides role and permission based authorization.
+//
+// Roles are named sets of permissions and may inherit other roles.
+// Permissions are resource-scoped strings in the "resource:action" form,
+// e.g. "orders:read". The "orders:*" permission grants every action on the
+// "orders" resource, and the "*" permission grants everything.
+// Permissions granted by a role can be narrowed by attribute conditions, which are evaluated
+// against the subject and the resource, e.g. to allow customers editing own orders only.
+// The conditions apply to the grant of the role only, so other roles granting the same
+// permission, e.g. an admin, are not narrowed.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// Permission represents the resource-scoped permission in the "resource:action" form.
+type Permission string
+
+// Resource returns the resource part of the permission.
+func (p Permission) Resource() string {
+	resource, _, _ := strings.Cut(string(p), ":")
+	return resource
+}
+
+// grants reports whether the permission grants the requested one.
+func (p Permission) grants(requested Permission) bool {
+	switch {
+	case p == "*", p == requested:
+		return true
+
+	case strings.HasSuffix(string(p), ":*"):
+		return strings.HasPrefix(string(requested), string(p[:len(p)-1]))
+
+	default:
+		return false
+	}
+}
+
+// Subject represents the authenticated caller.
+type Subject struct {
+	ID         string
+	Roles      []string
+	Attributes map[string]any
+}
+
+// Resource represents the resource the access is requested to.
+type Resource struct {
+	Type       string
+	ID         string
+	Attributes map[string]any
+}
+
+// Condition represents a function which reports whether the subject
+// satisfies the attribute condition of the permission for the resource.
+type Condition func(ctx context.Context, s Subject, r Resource) bool
+
+// Decision represents the result of the authorization.
+type Decision struct {
+	// Allowed reports whether the access is granted.
+	Allowed bool
+
+	// Permission is the requested permission.
+	Permission Permission
+
+	// Role is the name of the role which granted the permission.
+	Role string
+
+	// Reason describes why the access is denied.
+	Reason string
+}
+
+// LogValue implements slog.LogValuer.
+func (d Decision) LogValue() slog.Value {
+	if d.Allowed {
+		return slog.GroupValue(
+			slog.String("decision", "allow"),
+			slog.String("permission", string(d.Permission)),
+			slog.String("role", d.Role),
+		)
+	}
+
+	return slog.GroupValue(
+		slog.String("decision", "deny"),
+		slog.String("permission", string(d.Permission)),
+		slog.String("reason", d.Reason),
+	)
+}
+
+// Option represents a function type that modifies the Authorizer.
+type Option func(a *Authorizer)
+
+// WithRole defines the role with the given permissions.
+// Multiple calls with the same role name extend the role.
+func WithRole(name string, permissions ...Permission) Option {
+	return func(a *Authorizer) {
+		r := a.role(name)
+		r.permissions = append(r.permissions, permissions...)
+	}
+}
+
+// WithInherits makes the role inherit permissions of the given parent roles.
+func WithInherits(name string, parents ...string) Option {
+	return func(a *Authorizer) {
+		r := a.role(name)
+		r.parents = append(r.parents, parents...)
+	}
+}
+
+// WithCondition adds the attribute condition to the permission granted by the role,
+// as it is given to the WithRole, e.g. "orders:*". The role, and the roles inheriting it,
+// grant the permission only if all its conditions are satisfied.
+func WithCondition(name string, permission Permission, condition Condition) Option {
+	return func(a *Authorizer) {
+		r := a.role(name)
+
+		if r.conditions == nil {
+			r.conditions = make(map[Permission][]Condition)
+		}
+
+		r.conditions[permission] = append(r.conditions[permission], condition)
+	}
+}
+
+// WithRolesKey sets the key of the jwtkit.Token metadata to read
+// the subject roles from. Default is "roles".
+func WithRolesKey(key string) Option {
+	return func(a *Authorizer) { a.rolesKey = key }
+}
+
+// role represents the role definition.
+type role struct {
+	permissions []Permission
+	conditions  map[Permission][]Condition
+	parents     []string
+}
+
+// Authorizer evaluates the permissions of subjects.
+// It is safe for concurrent use once created.
+type Authorizer struct {
+	roles    map[string]*role
+	rolesKey string
+}
+
+// New returns a pointer to a new instance of Authorizer.
+func New(options ...Option) *Authorizer {
+	a := Authorizer{
+		roles:    make(map[string]*role),
+		rolesKey: "roles",
+	}
+
+	for _, option := range options {
+		option(&a)
+	}
+
+	return &a
+}
+
+// Evaluate evaluates the permission of the subject for the resource.
+func (a *Authorizer) Evaluate(ctx context.Context, s Subject, p Permission, r Resource) Decision {
+	d := Decision{
+		Permission: p,
+	}
+
+	var conditional bool
+
+	for _, name := range s.Roles {
+		allowed, matched := a.grants(ctx, name, s, p, r, make(map[string]struct{}))
+		if allowed {
+			d.Allowed = true
+			d.Role = name
+
+			return d
+		}
+
+		conditional = conditional || matched
+	}
+
+	d.Reason = "no role grants the permission"
+	if conditional {
+		d.Reason = "condition is not satisfied"
+	}
+
+	return d
+}
+
+// Authorize evaluates the permission of the subject for the resource and records
+// the decision to the access log. Returns an error which wraps errkit.ErrUnauthorized on denial.
+func (a *Authorizer) Authorize(ctx context.Context, s Subject, p Permission, r Resource) error {
+	d := a.Evaluate(ctx, s, p, r)
+
+	ctxkit.LogAttrs(ctx, slog.Any("authz", d))
+
+	if !d.Allowed {
+		return errors.Join(errkit.ErrUnauthorized, fmt.Errorf("permission %q: %s", p, d.Reason))
+	}
+
+	return nil
+}
+
+// Subject returns the Subject from the jwtkit.Token. The subject ID is the token subject claim,
+// the roles are read from the token metadata by the roles key, and the attributes are the token metadata.
+func (a *Authorizer) Subject(t *jwtkit.Token) Subject {
+	s := Subject{
+		ID:         t.Subject,
+		Attributes: t.Meta,
+	}
+
+	switch roles := t.Meta[a.rolesKey].(type) {
+	case string:
+		s.Roles = strings.Fields(roles)
+
+	case []string:
+		s.Roles = roles
+
+	case []any:
+		for _, v := range roles {
+			if name, ok := v.(string); ok {
+				s.Roles = append(s.Roles, name)
+			}
+		}
+	}
+
+	return s
+}
+
+// subject returns the Subject from the jwtkit.Token in the context.
+func (a *Authorizer) subject(ctx context.Context) (Subject, error) {
+	t, ok := jwtkit.TokenFromContext(ctx)
+	if !ok {
+		return Subject{}, errors.Join(errkit.ErrUnauthenticated, errors.New("token is missing in the context"))
+	}
+
+	return a.Subject(t), nil
+}
+
+// role returns the role by the name and creates it if needed.
+func (a *Authorizer) role(name string) *role {
+	r, ok := a.roles[name]
+	if !ok {
+		r = &role{}
+		a.roles[name] = r
+	}
+
+	return r
+}
+
+// grants reports whether the role or its parents grant the permission to the subject for the resource,
+// and whether any of them grants the permission, but its conditions are not satisfied.
+// The visited set protects from the inheritance cycles.
+func (a *Authorizer) grants(
+	ctx context.Context, name string, s Subject, p Permission, res Resource, visited map[string]struct{},
+) (allowed, matched bool) {
+	if _, ok := visited[name]; ok {
+		return false, false
+	}
+
+	visited[name] = struct{}{}
+
+	r, ok := a.roles[name]
+	if !ok {
+		return false, false
+	}
+
+	for _, granted := range r.permissions {
+		if !granted.grants(p) {
+			continue
+		}
+
+		if satisfied(ctx, r.conditions[granted], s, res) {
+			return true, true
+		}
+
+		matched = true
+	}
+
+	for _, parent := range r.parents {
+		parentAllowed, parentMatched := a.grants(ctx, parent, s, p, res, visited)
+		if parentAllowed {
+			return true, true
+		}
+
+		matched = matched || parentMatched
+	}
+
+	return false, matched
+}
+
+// satisfied reports whether all the conditions are satisfied.
+func satisfied(ctx context.Context, conditions []Condition, s Subject, r Resource) bool {
+	for _, condition := range conditions {
+		if !condition(ctx, s, r) {
+			return false
+		}
+	}
+
+	return true
+}
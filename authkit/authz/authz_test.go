@@ -0,0 +1,136 @@
+package authz
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newAuthorizer() *Authorizer {
+	return New(
+		WithRole("viewer", "orders:read"),
+		WithRole("editor", "orders:write"),
+		WithInherits("editor", "viewer"),
+		WithRole("admin", "*"),
+		WithInherits("viewer", "editor"), // Cycle must not hang the evaluation.
+		WithCondition("editor", "orders:write", func(_ context.Context, s Subject, r Resource) bool {
+			return r.Attributes["owner"] == s.ID
+		}),
+		WithRole("manager"),
+		WithInherits("manager", "editor"),
+	)
+}
+
+func TestAuthorizer_Evaluate(t *testing.T) {
+	a := newAuthorizer()
+
+	f := func(name string, roles []string, p Permission, owner string, want bool) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			s := Subject{ID: "alice", Roles: roles}
+			r := Resource{Type: p.Resource(), Attributes: map[string]any{"owner": owner}}
+
+			td.Cmp(t, a.Evaluate(context.Background(), s, p, r).Allowed, want)
+		})
+	}
+
+	f("Direct", []string{"viewer"}, "orders:read", "", true)
+	f("Inherited", []string{"editor"}, "orders:read", "", true)
+	f("Condition", []string{"editor"}, "orders:write", "alice", true)
+	f("ConditionFailed", []string{"editor"}, "orders:write", "bob", false)
+	f("Wildcard", []string{"admin"}, "users:delete", "", true)
+	f("InheritedCondition", []string{"manager"}, "orders:write", "bob", false)
+	f("ConditionOfOtherRole", []string{"editor", "admin"}, "orders:write", "bob", true)
+	f("Denied", []string{"viewer"}, "users:read", "", false)
+	f("UnknownRole", []string{"ghost"}, "orders:read", "", false)
+	f("NoRoles", nil, "orders:read", "", false)
+}
+
+func TestPermission_grants(t *testing.T) {
+	td.CmpTrue(t, Permission("orders:*").grants("orders:read"))
+	td.CmpFalse(t, Permission("orders:*").grants("ordersx:read"))
+	td.CmpFalse(t, Permission("orders:read").grants("orders:write"))
+}
+
+func TestAuthorizer_Subject(t *testing.T) {
+	a := New(WithRolesKey("groups"))
+
+	s := a.Subject(&jwtkit.Token{
+		Claims: jwtkit.Claims{Subject: "alice"},
+		Meta:   map[string]any{"groups": []any{"viewer", "editor", 1}},
+	})
+
+	td.Cmp(t, s.ID, "alice")
+	td.Cmp(t, s.Roles, []string{"viewer", "editor"})
+}
+
+func TestAuthorizer_Require(t *testing.T) {
+	a := newAuthorizer()
+
+	var attrs []slog.Attr
+
+	handler := a.Require("orders:read")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	f := func(name string, token *jwtkit.Token, want int) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			attrs = nil
+
+			ctx := ctxkit.SetLogAttrHook(context.Background(), func(a ...slog.Attr) { attrs = append(attrs, a...) })
+			if token != nil {
+				ctx = jwtkit.SetClaims(ctx, token)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/", http.NoBody))
+
+			td.Cmp(t, w.Code, want)
+		})
+	}
+
+	f("Allowed", &jwtkit.Token{Meta: map[string]any{"roles": "viewer"}}, http.StatusOK)
+	td.Cmp(t, attrs, td.Len(1))
+
+	f("Denied", &jwtkit.Token{Meta: map[string]any{"roles": "guest"}}, http.StatusForbidden)
+	td.Cmp(t, attrs, td.Len(1))
+
+	f("Unauthenticated", nil, http.StatusUnauthorized)
+	td.Cmp(t, attrs, td.Len(0))
+}
+
+func TestAuthorizer_UnaryInterceptor(t *testing.T) {
+	a := newAuthorizer()
+
+	interceptor := a.UnaryInterceptor(Methods{"/orders.v1.OrderService/GetOrder": "orders:read"},
+		WithPublicMethods("/grpc.health.v1.Health/Check"),
+	)
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	ctx := jwtkit.SetClaims(context.Background(), &jwtkit.Token{Meta: map[string]any{"roles": []string{"guest"}}})
+
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/GetOrder"}, handler)
+	td.Cmp(t, status.Code(err), codes.PermissionDenied)
+
+	// The methods absent in the Methods are denied unless they are public.
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/orders.v1.OrderService/ListOrders"}, handler)
+	td.Cmp(t, status.Code(err), codes.PermissionDenied)
+
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	td.CmpNoError(t, err)
+	td.Cmp(t, resp, "ok")
+
+	err = a.Authorize(context.Background(), Subject{}, "orders:read", Resource{})
+	td.CmpErrorIs(t, err, errkit.ErrUnauthorized)
+}
@@ -0,0 +1,141 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/grpckit"
+	"github.com/plainq/servekit/httpkit"
+	"google.golang.org/grpc"
+)
+
+// RequireOptions represents the options of the Require middleware.
+type RequireOptions struct {
+	resource func(r *http.Request) Resource
+}
+
+// RequireOption represents a function type that modifies RequireOptions.
+type RequireOption func(o *RequireOptions)
+
+// WithResource sets the function which returns the resource requested by the HTTP request,
+// e.g. with the ID from the route parameters. By default, the resource has only the type
+// which is the resource part of the permission.
+func WithResource(resource func(r *http.Request) Resource) RequireOption {
+	return func(o *RequireOptions) { o.resource = resource }
+}
+
+// Require returns the middleware which allows the request only if the subject of the
+// jwtkit.Token from the request context has the permission. It should be mounted after
+// the httpkit.JWTAuthMiddleware. Denied requests are rejected with 403.
+func (a *Authorizer) Require(p Permission, options ...RequireOption) httpkit.Middleware {
+	o := RequireOptions{
+		resource: func(*http.Request) Resource { return Resource{Type: p.Resource()} },
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			s, err := a.subject(r.Context())
+			if err != nil {
+				httpkit.ErrorHTTP(w, r, err, httpkit.WithStatus(http.StatusUnauthorized))
+				return
+			}
+
+			if err := a.Authorize(r.Context(), s, p, o.resource(r)); err != nil {
+				httpkit.ErrorHTTP(w, r, err, httpkit.WithStatus(http.StatusForbidden))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Methods represents the permissions required by gRPC methods.
+// The key is the full method name, e.g. "/orders.v1.OrderService/GetOrder".
+type Methods map[string]Permission
+
+// MethodsOptions represents the options of the gRPC interceptors.
+type MethodsOptions struct {
+	public map[string]struct{}
+}
+
+// MethodsOption represents a function type that modifies MethodsOptions.
+type MethodsOption func(o *MethodsOptions)
+
+// WithPublicMethods sets the full names of the methods which are called without the authorization,
+// e.g. "/grpc.health.v1.Health/Check".
+func WithPublicMethods(methods ...string) MethodsOption {
+	return func(o *MethodsOptions) {
+		for _, method := range methods {
+			o.public[method] = struct{}{}
+		}
+	}
+}
+
+// UnaryInterceptor returns the gRPC unary server interceptor which allows the calls to
+// the given methods only if the subject of the jwtkit.Token from the context has the
+// required permission. Calls to the methods absent in the Methods are denied, unless they
+// are public, see WithPublicMethods. It should be chained after the grpckit.JWTAuthUnaryInterceptor.
+func (a *Authorizer) UnaryInterceptor(methods Methods, options ...MethodsOption) grpckit.UnaryInterceptor {
+	o := newMethodsOptions(options...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := a.authorizeMethod(ctx, methods, o, info.FullMethod); err != nil {
+			return grpckit.ErrorGRPC[any](ctx, err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns the gRPC stream server interceptor.
+// See UnaryInterceptor for the details.
+func (a *Authorizer) StreamInterceptor(methods Methods, options ...MethodsOption) grpckit.StreamInterceptor {
+	o := newMethodsOptions(options...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorizeMethod(ss.Context(), methods, o, info.FullMethod); err != nil {
+			_, err = grpckit.ErrorGRPC[any](ss.Context(), err)
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func newMethodsOptions(options ...MethodsOption) MethodsOptions {
+	o := MethodsOptions{public: make(map[string]struct{})}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o
+}
+
+func (a *Authorizer) authorizeMethod(ctx context.Context, methods Methods, o MethodsOptions, method string) error {
+	if _, ok := o.public[method]; ok {
+		return nil
+	}
+
+	p, ok := methods[method]
+	if !ok {
+		return errors.Join(errkit.ErrUnauthorized, fmt.Errorf("method %q has no required permission", method))
+	}
+
+	s, err := a.subject(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.Authorize(ctx, s, p, Resource{Type: p.Resource()})
+}
@@ -1,6 +1,9 @@
 package hashkit
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
 	"fmt"
 
@@ -72,3 +75,26 @@ func (h *BCryptHasher) HashPassword(pass string) (string, error) {
 
 	return string(hash), nil
 }
+
+// NewSHA256Hasher returns a pointer to a new instance of SHA256Hasher type.
+func NewSHA256Hasher() *SHA256Hasher { return &SHA256Hasher{} }
+
+// SHA256Hasher implements Hasher interface.
+// Hashes the high-entropy secrets, e.g. the random API keys, using SHA-256 algorithm.
+// It is fast, so it must not be used for the passwords, use the BCryptHasher instead.
+type SHA256Hasher struct{}
+
+func (*SHA256Hasher) HashPassword(pass string) (string, error) {
+	sum := sha256.Sum256([]byte(pass))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (h *SHA256Hasher) CheckPassword(hash, pass string) error {
+	computed, _ := h.HashPassword(pass)
+
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(computed)) != 1 {
+		return errkit.ErrPasswordIncorrect
+	}
+
+	return nil
+}
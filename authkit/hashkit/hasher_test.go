@@ -70,3 +70,25 @@ func TestBCryptHasher_HashAndCheck(t *testing.T) {
 		td.Cmp(t, err, td.NotNil())
 	})
 }
+
+func TestSHA256Hasher_HashAndCheck(t *testing.T) {
+	td.NewT(t)
+
+	hasher := hashkit.NewSHA256Hasher()
+
+	hash, err := hasher.HashPassword("secret")
+	td.CmpNil(t, err)
+	td.Cmp(t, hash, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b")
+
+	t.Run("check correct password", func(t *testing.T) {
+		td.CmpNil(t, hasher.CheckPassword(hash, "secret"))
+	})
+
+	t.Run("check incorrect password", func(t *testing.T) {
+		td.Cmp(t, hasher.CheckPassword(hash, "wrong"), errkit.ErrPasswordIncorrect)
+	})
+
+	t.Run("check invalid hash", func(t *testing.T) {
+		td.Cmp(t, hasher.CheckPassword("invalid-hash", "secret"), errkit.ErrPasswordIncorrect)
+	})
+}
@@ -0,0 +1,56 @@
+package jwtkit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// claimsKey represents a Key for context by which
+// the verified token claims can be received from the context.
+const claimsKey ctxkit.Key = "ctx.jwtkit.claims"
+
+// VerifyClaims parses and verifies the token by the given TokenManager and decodes its claims into T.
+// If T is Token, the TokenManager.ParseVerify is used, otherwise the TokenManager.ParseVerifyClaims is used.
+// Returned error always wraps errkit.ErrUnauthenticated.
+func VerifyClaims[T any](tm TokenManager, token string) (*T, error) {
+	var claims T
+
+	if t, ok := any(&claims).(*Token); ok {
+		parsed, err := tm.ParseVerify(token)
+		if err != nil {
+			return nil, errors.Join(errkit.ErrUnauthenticated, err)
+		}
+
+		*t = *parsed
+
+		return &claims, nil
+	}
+
+	if err := tm.ParseVerifyClaims(token, &claims); err != nil {
+		return nil, errors.Join(errkit.ErrUnauthenticated, err)
+	}
+
+	return &claims, nil
+}
+
+// SetClaims sets the verified token claims to the context.
+func SetClaims[T any](ctx context.Context, claims *T) context.Context {
+	return ctxkit.Set(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext gets the verified token claims of type T from the context.
+// The second return value reports whether the claims of type T are present.
+func ClaimsFromContext[T any](ctx context.Context) (*T, bool) {
+	claims := ctxkit.Get[*T](ctx, claimsKey)
+
+	return claims, claims != nil
+}
+
+// TokenFromContext gets the verified Token from the context.
+// It is a shorthand for ClaimsFromContext[Token].
+func TokenFromContext(ctx context.Context) (*Token, bool) {
+	return ClaimsFromContext[Token](ctx)
+}
@@ -2,6 +2,9 @@ package ctxkit
 
 import (
 	"context"
+	"log/slog"
+	"net/netip"
+	"strings"
 )
 
 const (
@@ -9,9 +12,21 @@ const (
 	// the error log hook can be received from the context.
 	logErrHook Key = "ctx.log-error-hook"
 
+	// logAttrHook represents a Key for context by which
+	// the log attributes hook can be received from the context.
+	logAttrHook Key = "ctx.log-attr-hook"
+
+	// metricLabelHook represents a Key for context by which
+	// the metric label hook can be received from the context.
+	metricLabelHook Key = "ctx.metric-label-hook"
+
 	// RequestID represents a Key for context by which
 	// the request ID can be received from the context.
 	requestID Key = "ctx.request-id"
+
+	// clientIP represents a Key for context by which
+	// the client IP address can be received from the context.
+	clientIP Key = "ctx.client-ip"
 )
 
 // Key represents a context Key with custom type.
@@ -48,6 +63,59 @@ func GetLogErrHook(ctx context.Context) func(error) {
 	return nil
 }
 
+// SetLogAttrHook sets the hook function to the context.
+func SetLogAttrHook(ctx context.Context, hook func(attrs ...slog.Attr)) context.Context {
+	return context.WithValue(ctx, logAttrHook, hook)
+}
+
+// GetLogAttrHook gets the hook function from the context which adds the attributes to the access log line.
+// If searched values is absent in context, then nil wil be returned.
+func GetLogAttrHook(ctx context.Context) func(attrs ...slog.Attr) {
+	if hook, ok := ctx.Value(logAttrHook).(func(attrs ...slog.Attr)); ok {
+		return hook
+	}
+
+	return nil
+}
+
+// LogAttrs adds the given attributes to the access log line if the hook is present in the context.
+func LogAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if hook := GetLogAttrHook(ctx); hook != nil {
+		hook(attrs...)
+	}
+}
+
+// SetMetricLabelHook sets the hook function to the context.
+func SetMetricLabelHook(ctx context.Context, hook func(name, value string)) context.Context {
+	return context.WithValue(ctx, metricLabelHook, hook)
+}
+
+// GetMetricLabelHook gets the hook function from the context which adds the label to the request metrics.
+// If searched values is absent in context, then nil wil be returned.
+func GetMetricLabelHook(ctx context.Context) func(name, value string) {
+	if hook, ok := ctx.Value(metricLabelHook).(func(name, value string)); ok {
+		return hook
+	}
+
+	return nil
+}
+
+// metricLabelEscaper escapes the metric label value.
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// FormatMetricLabel formats the label added by the MetricLabel to append it
+// to the labels of the metric name, e.g. `, version="v1"`. The value is escaped.
+func FormatMetricLabel(name, value string) string {
+	return `, ` + name + `="` + metricLabelEscaper.Replace(value) + `"`
+}
+
+// MetricLabel adds the given label to the request metrics if the hook is present in the context.
+func MetricLabel(ctx context.Context, name, value string) {
+	if hook := GetMetricLabelHook(ctx); hook != nil {
+		hook(name, value)
+	}
+}
+
 // SetRequestID sets the request ID to the context.
 func SetRequestID(ctx context.Context, id string) context.Context {
 	return context.WithValue(ctx, requestID, id)
@@ -63,5 +131,20 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+// SetClientIP sets the client IP address to the context.
+func SetClientIP(ctx context.Context, ip netip.Addr) context.Context {
+	return context.WithValue(ctx, clientIP, ip)
+}
+
+// GetClientIP gets the client IP address from the context.
+// If searched values is absent in context, then invalid netip.Addr wil be returned.
+func GetClientIP(ctx context.Context) netip.Addr {
+	if ip, ok := ctx.Value(clientIP).(netip.Addr); ok {
+		return ip
+	}
+
+	return netip.Addr{}
+}
+
 // zero returns default zeroed value for type T.
 func zero[T any]() (v T) { return v }
@@ -2,6 +2,7 @@ package ctxkit
 
 import (
 	"context"
+	"log/slog"
 	"testing"
 
 	"github.com/maxatome/go-testdeep/td"
@@ -14,6 +15,16 @@ func TestGetLogErrHook(t *testing.T) {
 	td.Cmp(t, got, td.Shallow(want))
 }
 
+func TestLogAttrs(t *testing.T) {
+	var got []slog.Attr
+
+	ctx := SetLogAttrHook(context.Background(), func(attrs ...slog.Attr) { got = append(got, attrs...) })
+	LogAttrs(ctx, slog.String("key", "value"))
+	LogAttrs(context.Background(), slog.String("key", "ignored"))
+
+	td.Cmp(t, got, []slog.Attr{slog.String("key", "value")})
+}
+
 func TestGetRequestID(t *testing.T) {
 	want := "testid"
 	ctx := context.WithValue(context.Background(), requestID, want)
@@ -48,3 +59,8 @@ func TestGet(t *testing.T) {
 	got := Get[string](ctx, "ctx.str")
 	td.Cmp(t, got, want)
 }
+
+func TestFormatMetricLabel(t *testing.T) {
+	td.Cmp(t, FormatMetricLabel("version", "v1"), `, version="v1"`)
+	td.Cmp(t, FormatMetricLabel("tenant", "a\"b\\c\nd"), `, tenant="a\"b\\c\nd"`)
+}
@@ -27,6 +27,10 @@ const (
 	// This kind of error is retryable. Caller should retry with a backoff.
 	ErrUnavailable Error = "temporarily unavailable"
 
+	// ErrPreconditionFailed indicates that one of the request preconditions,
+	// like If-Match or If-Unmodified-Since, has been evaluated to false.
+	ErrPreconditionFailed Error = "precondition failed"
+
 	// ErrConnFailed shows that connection to a resource failed.
 	ErrConnFailed Error = "connection failed"
 
@@ -20,6 +20,7 @@ func TestError_Error(t *testing.T) {
 	f("ErrUnauthenticated", ErrUnauthenticated, "authentication failed")
 	f("ErrUnauthorized", ErrUnauthorized, "permission denied")
 	f("ErrUnavailable", ErrUnavailable, "temporarily unavailable")
+	f("ErrPreconditionFailed", ErrPreconditionFailed, "precondition failed")
 	f("ErrConnFailed", ErrConnFailed, "connection failed")
 	f("ErrNotFound", ErrNotFound, "not found")
 	f("Custom", Error("test error"), "test error")
@@ -0,0 +1,33 @@
+package errkit
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError represents the error recovered from a panic.
+// It keeps the stack trace of the panicking goroutine for the error reporter.
+type PanicError struct {
+	// Value represents the value passed to the panic.
+	Value any
+
+	// Stack represents the formatted stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// NewPanicError returns a pointer to a new instance of PanicError with the current stack trace.
+// It must be called from the deferred function which recovered the panic.
+func NewPanicError(value any) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("panic: %v", e.Value) }
+
+// Unwrap returns the value passed to the panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,17 @@
+package errkit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestPanicError(t *testing.T) {
+	err := NewPanicError(ErrNotFound)
+
+	td.Cmp(t, err.Error(), "panic: not found")
+	td.Cmp(t, errors.Is(err, ErrNotFound), true)
+	td.Cmp(t, string(err.Stack), td.Contains("panic_test.go"))
+	td.CmpNil(t, NewPanicError("boom").Unwrap())
+}
@@ -94,4 +94,18 @@ func Close() error {
 
 type reporter struct{}
 
-func (reporter) Report(err error) { _ = sentry.CaptureMessage(err.Error()) }
+func (reporter) Report(err error) {
+	var panicErr *errkit.PanicError
+	if errors.As(err, &panicErr) {
+		sentry.WithScope(func(scope *sentry.Scope) {
+			scope.SetContext("panic", sentry.Context{"stack": string(panicErr.Stack)})
+			scope.SetLevel(sentry.LevelFatal)
+
+			_ = sentry.CaptureMessage(err.Error())
+		})
+
+		return
+	}
+
+	_ = sentry.CaptureMessage(err.Error())
+}
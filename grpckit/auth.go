@@ -0,0 +1,151 @@
+package grpckit
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ErrTokenMissing indicates that the call metadata does not carry a bearer token.
+var ErrTokenMissing = errors.Join(errkit.ErrUnauthenticated, errors.New("bearer token is missing"))
+
+// JWTAuthOptions represents the options of the JWT authentication interceptors.
+type JWTAuthOptions struct {
+	metadataKey string
+	skip        func(fullMethod string) bool
+}
+
+// JWTAuthOption represents a function type that modifies JWTAuthOptions.
+type JWTAuthOption func(o *JWTAuthOptions)
+
+// WithJWTMetadataKey sets the metadata key to extract the bearer token from.
+// Default is "authorization". The value must have the "Bearer" scheme.
+func WithJWTMetadataKey(key string) JWTAuthOption {
+	return func(o *JWTAuthOptions) { o.metadataKey = strings.ToLower(key) }
+}
+
+// WithJWTSkip sets the function which reports whether the authentication
+// of the call to the given full method should be skipped. Multiple skip functions are combined.
+func WithJWTSkip(skip func(fullMethod string) bool) JWTAuthOption {
+	return func(o *JWTAuthOptions) {
+		if prev := o.skip; prev != nil {
+			o.skip = func(fullMethod string) bool { return prev(fullMethod) || skip(fullMethod) }
+			return
+		}
+
+		o.skip = skip
+	}
+}
+
+// WithJWTSkipMethods skips the authentication of the calls to the given full methods,
+// e.g. "/grpc.health.v1.Health/Check".
+func WithJWTSkipMethods(methods ...string) JWTAuthOption {
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[method] = struct{}{}
+	}
+
+	return WithJWTSkip(func(fullMethod string) bool {
+		_, ok := set[fullMethod]
+		return ok
+	})
+}
+
+// JWTAuthUnaryInterceptor is a gRPC unary server interceptor which extracts the bearer token
+// from the call metadata, verifies it by the given TokenManager, and decodes its claims into T.
+// Verified claims can be received from the context by the jwtkit.ClaimsFromContext.
+// Calls without a valid token are rejected with codes.Unauthenticated.
+func JWTAuthUnaryInterceptor[T any](tm jwtkit.TokenManager, options ...JWTAuthOption) UnaryInterceptor {
+	o := newJWTAuthOptions(options...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if o.skip != nil && o.skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, err := authenticate[T](ctx, tm, o)
+		if err != nil {
+			return ErrorGRPC[any](ctx, err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// JWTAuthStreamInterceptor is a gRPC stream server interceptor.
+// See JWTAuthUnaryInterceptor for the details.
+func JWTAuthStreamInterceptor[T any](tm jwtkit.TokenManager, options ...JWTAuthOption) StreamInterceptor {
+	o := newJWTAuthOptions(options...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if o.skip != nil && o.skip(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		ctx, err := authenticate[T](ss.Context(), tm, o)
+		if err != nil {
+			_, err = ErrorGRPC[any](ctx, err)
+			return err
+		}
+
+		return handler(srv, WrapServerStream(ctx, ss))
+	}
+}
+
+func newJWTAuthOptions(options ...JWTAuthOption) *JWTAuthOptions {
+	o := JWTAuthOptions{
+		metadataKey: "authorization",
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &o
+}
+
+// authenticate verifies the bearer token from the incoming metadata
+// and returns the context with the verified claims.
+func authenticate[T any](ctx context.Context, tm jwtkit.TokenManager, o *JWTAuthOptions) (context.Context, error) {
+	var token string
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get(o.metadataKey) {
+			scheme, t, ok := strings.Cut(strings.TrimSpace(value), " ")
+			if ok && strings.EqualFold(scheme, "Bearer") {
+				token = strings.TrimSpace(t)
+				break
+			}
+		}
+	}
+
+	if token == "" {
+		return ctx, ErrTokenMissing
+	}
+
+	claims, err := jwtkit.VerifyClaims[T](tm, token)
+	if err != nil {
+		return ctx, err
+	}
+
+	return jwtkit.SetClaims(ctx, claims), nil
+}
+
+// WrapServerStream returns the grpc.ServerStream which context is overridden by the given one,
+// so the stream interceptors are able to pass the values to the handler, e.g. the claims.
+func WrapServerStream(ctx context.Context, ss grpc.ServerStream) grpc.ServerStream {
+	return &serverStream{ServerStream: ss, ctx: ctx}
+}
+
+// serverStream wraps grpc.ServerStream to override its context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
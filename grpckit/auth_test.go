@@ -0,0 +1,61 @@
+package grpckit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestJWTAuthUnaryInterceptor(t *testing.T) {
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	verifier, err := jwt.NewVerifierHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	manager := jwtkit.NewTokenManager(signer, verifier)
+
+	token, err := manager.Sign(&jwtkit.Token{Claims: jwtkit.Claims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}})
+	td.Require(t).CmpNoError(err)
+
+	interceptor := JWTAuthUnaryInterceptor[jwtkit.Token](manager,
+		WithJWTSkipMethods("/grpc.health.v1.Health/Check"),
+	)
+
+	handler := func(ctx context.Context, _ any) (any, error) {
+		token, _ := jwtkit.TokenFromContext(ctx)
+		if token == nil {
+			return "", nil
+		}
+
+		return token.Subject, nil
+	}
+
+	f := func(name, method string, md metadata.MD, want any, wantCode codes.Code) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+			td.Cmp(t, status.Code(err), wantCode)
+			td.Cmp(t, resp, want)
+		})
+	}
+
+	f("Valid", "/svc/Method", metadata.Pairs("authorization", "Bearer "+token), "user", codes.OK)
+	f("Missing", "/svc/Method", metadata.MD{}, nil, codes.Unauthenticated)
+	f("Invalid", "/svc/Method", metadata.Pairs("authorization", "Bearer invalid"), nil, codes.Unauthenticated)
+	f("Skipped", "/grpc.health.v1.Health/Check", metadata.MD{}, "", codes.OK)
+}
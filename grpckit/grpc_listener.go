@@ -54,6 +54,12 @@ func WithStreamInterceptors(interceptors ...StreamInterceptor) Option[ListenerCo
 	}
 }
 
+// WithErrorResponder sets the responder which is used by the ErrorGRPC
+// in the calls of the listener instead of the default one.
+func WithErrorResponder(responder GRPCErrorResponder) Option[ListenerConfig] {
+	return func(o *ListenerConfig) { o.errResponder = responder }
+}
+
 // GRPCEndpointRegistrator abstracts a mechanics of registering
 // the gRPC service in the gRPC server.
 type GRPCEndpointRegistrator interface {
@@ -79,6 +85,12 @@ func NewListenerGRPC(addr string, options ...Option[ListenerConfig]) (*ListenerG
 	// Apply all option to the default applyOptionsHTTP.
 	cfg := applyOptionsGRPC(options...)
 
+	// The responder is set first, so it is used by all the interceptors.
+	if cfg.errResponder != nil {
+		cfg.unaryInterceptors = append([]UnaryInterceptor{ErrorResponderUnaryInterceptor(cfg.errResponder)}, cfg.unaryInterceptors...)
+		cfg.streamInterceptors = append([]StreamInterceptor{ErrorResponderStreamInterceptor(cfg.errResponder)}, cfg.streamInterceptors...)
+	}
+
 	serverOptions := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(cfg.unaryInterceptors...),
 		grpc.ChainStreamInterceptor(cfg.streamInterceptors...),
@@ -200,4 +212,5 @@ type ListenerConfig struct {
 	logger             *slog.Logger
 	unaryInterceptors  []UnaryInterceptor
 	streamInterceptors []StreamInterceptor
+	errResponder       GRPCErrorResponder
 }
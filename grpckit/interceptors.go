@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
@@ -26,14 +27,31 @@ func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 		start := time.Now().UTC()
 
-		var reqErr error
+		var (
+			reqErr   error
+			reqAttrs []any
+		)
 
 		ctx = ctxkit.SetLogErrHook(ctx, func(err error) { reqErr = err })
+		ctx = ctxkit.SetLogAttrHook(ctx, func(attrs ...slog.Attr) {
+			for _, attr := range attrs {
+				reqAttrs = append(reqAttrs, attr)
+			}
+		})
 
 		resp, err = handler(ctx, req)
+
+		rpcLogger := logger.With(reqAttrs...)
+
 		if err != nil {
+			// The error may be returned without the ErrorGRPC,
+			// so the hook is not called.
+			if reqErr == nil {
+				reqErr = err
+			}
+
 			if s, ok := status.FromError(err); ok {
-				logger.Error("RPC",
+				rpcLogger.Error("RPC",
 					slog.String("code", s.Code().String()),
 					slog.String("message", s.Message()),
 					slog.String("method", info.FullMethod),
@@ -44,7 +62,7 @@ func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 				return resp, err
 			}
 
-			logger.Error("RPC",
+			rpcLogger.Error("RPC",
 				slog.String("method", info.FullMethod),
 				slog.Duration("duration", time.Since(start)),
 				slog.String("error", reqErr.Error()),
@@ -53,7 +71,7 @@ func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 			return resp, err
 		}
 
-		logger.Info("RPC",
+		rpcLogger.Info("RPC",
 			slog.String("method", info.FullMethod),
 			slog.Duration("duration", time.Since(start)),
 		)
@@ -62,11 +80,19 @@ func LoggingInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
 	}
 }
 
+// MetricsInterceptor is a gRPC unary server interceptor that collects the call metrics.
+// The labels added by the ctxkit.MetricLabel are appended to the metrics labels.
 func MetricsInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 		start := time.Now()
 		code := 0
 
+		var labels strings.Builder
+
+		ctx = ctxkit.SetMetricLabelHook(ctx, func(name, value string) {
+			labels.WriteString(ctxkit.FormatMetricLabel(name, value))
+		})
+
 		resp, err = handler(ctx, req)
 		if err != nil {
 			if s, ok := status.FromError(err); ok {
@@ -75,8 +101,8 @@ func MetricsInterceptor() grpc.UnaryServerInterceptor {
 		}
 
 		statusCode := strconv.Itoa(code)
-		httpReqTotal := grpcReqTotalStr(info.FullMethod, statusCode)
-		grpcReqDur := grpcReqDurationStr(info.FullMethod, statusCode)
+		httpReqTotal := grpcReqTotalStr(info.FullMethod, statusCode, labels.String())
+		grpcReqDur := grpcReqDurationStr(info.FullMethod, statusCode, labels.String())
 
 		metrics.GetOrCreateCounter(httpReqTotal).
 			Inc()
@@ -88,10 +114,10 @@ func MetricsInterceptor() grpc.UnaryServerInterceptor {
 	}
 }
 
-func grpcReqDurationStr(route, code string) string {
-	return `grpc_request_duration{route="` + route + `", code="` + code + `"}`
+func grpcReqDurationStr(route, code, labels string) string {
+	return `grpc_request_duration{route="` + route + `", code="` + code + `"` + labels + `}`
 }
 
-func grpcReqTotalStr(route, code string) string {
-	return `grpc_requests_total{route="` + route + `", code="` + code + `"}`
+func grpcReqTotalStr(route, code, labels string) string {
+	return `grpc_requests_total{route="` + route + `", code="` + code + `"` + labels + `}`
 }
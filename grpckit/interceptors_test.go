@@ -1 +1,61 @@
 package grpckit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryUnaryInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+
+	interceptor := RecoveryUnaryInterceptor(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"},
+		func(context.Context, any) (any, error) { panic("boom") },
+	)
+
+	td.Cmp(t, status.Code(err), codes.Internal)
+	td.CmpNil(t, resp)
+	td.Cmp(t, buf.String(), td.All(
+		td.Contains(`msg="Panic recovered"`),
+		td.Contains(`request_id=req-1`),
+		td.Contains(`method=/svc/Method`),
+		td.Contains(`panic=boom`),
+		td.Contains(`interceptors_test.go`),
+	))
+}
+
+func TestRecoveryStreamInterceptor(t *testing.T) {
+	interceptor := RecoveryStreamInterceptor(slog.New(slog.DiscardHandler))
+
+	err := interceptor(nil, &serverStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/svc/Stream"},
+		func(any, grpc.ServerStream) error { panic("boom") },
+	)
+
+	td.Cmp(t, status.Code(err), codes.Internal)
+}
+
+func TestErrorResponderUnaryInterceptor(t *testing.T) {
+	interceptor := ErrorResponderUnaryInterceptor(func(error, ...ResponseOption) error {
+		return status.Error(codes.Aborted, codes.Aborted.String())
+	})
+
+	_, err := ErrorGRPC[any](context.Background(), errkit.ErrNotFound)
+	td.Cmp(t, status.Code(err), codes.NotFound)
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Method"},
+		func(ctx context.Context, _ any) (any, error) { return ErrorGRPC[any](ctx, errkit.ErrNotFound) },
+	)
+	td.Cmp(t, status.Code(err), codes.Aborted)
+}
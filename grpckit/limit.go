@@ -0,0 +1,122 @@
+package grpckit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/plainq/servekit/limitkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ConcurrencyLimitOptions represents the options of the concurrency limit interceptors.
+type ConcurrencyLimitOptions struct {
+	priorities map[string]limitkit.Priority
+	retryAfter time.Duration
+}
+
+// ConcurrencyLimitOption represents a function type that modifies ConcurrencyLimitOptions.
+type ConcurrencyLimitOption func(o *ConcurrencyLimitOptions)
+
+// WithConcurrencyMethodPriorities sets the priority classes of the full method names.
+// Methods which are not listed have the limitkit.PriorityNormal. The gRPC health check
+// methods have the limitkit.PriorityCritical by default.
+func WithConcurrencyMethodPriorities(priorities map[string]limitkit.Priority) ConcurrencyLimitOption {
+	return func(o *ConcurrencyLimitOptions) {
+		for method, p := range priorities {
+			o.priorities[method] = p
+		}
+	}
+}
+
+// WithConcurrencyRetryAfter sets the retry-after header of the shed calls. Default is 1 second.
+func WithConcurrencyRetryAfter(d time.Duration) ConcurrencyLimitOption {
+	return func(o *ConcurrencyLimitOptions) { o.retryAfter = d }
+}
+
+// ConcurrencyLimitUnaryInterceptor returns the gRPC unary server interceptor which caps the number
+// of in-flight calls by the adaptive limit of the limitkit.Limiter. Shed calls are responded by the
+// ErrorGRPC with the limitkit.ErrLimitExceeded error, which is codes.Unavailable by default, and the
+// retry-after header. Calls failed with codes.Unavailable, codes.DeadlineExceeded or
+// codes.ResourceExhausted are reported to the limiter as dropped.
+func ConcurrencyLimitUnaryInterceptor(l *limitkit.Limiter, options ...ConcurrencyLimitOption) UnaryInterceptor {
+	o := concurrencyLimitOptions(options...)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		token, ok := l.Acquire(o.priority(info.FullMethod))
+		if !ok {
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", limitkit.RetryAfter(o.retryAfter)))
+
+			return ErrorGRPC[any](ctx, limitkit.ErrLimitExceeded)
+		}
+
+		defer func() { token.Release(dropped(err)) }()
+
+		return handler(ctx, req)
+	}
+}
+
+// ConcurrencyLimitStreamInterceptor returns the gRPC stream server interceptor.
+// See ConcurrencyLimitUnaryInterceptor for the details.
+func ConcurrencyLimitStreamInterceptor(l *limitkit.Limiter, options ...ConcurrencyLimitOption) StreamInterceptor {
+	o := concurrencyLimitOptions(options...)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		token, ok := l.Acquire(o.priority(info.FullMethod))
+		if !ok {
+			_ = ss.SetHeader(metadata.Pairs("retry-after", limitkit.RetryAfter(o.retryAfter)))
+
+			_, err = ErrorGRPC[any](ss.Context(), limitkit.ErrLimitExceeded)
+
+			return err
+		}
+
+		defer func() { token.Release(dropped(err)) }()
+
+		return handler(srv, ss)
+	}
+}
+
+// concurrencyLimitOptions returns the ConcurrencyLimitOptions with the given options applied.
+func concurrencyLimitOptions(options ...ConcurrencyLimitOption) ConcurrencyLimitOptions {
+	o := ConcurrencyLimitOptions{
+		priorities: map[string]limitkit.Priority{
+			"/grpc.health.v1.Health/Check": limitkit.PriorityCritical,
+			"/grpc.health.v1.Health/Watch": limitkit.PriorityCritical,
+		},
+		retryAfter: time.Second,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return o
+}
+
+// priority returns the priority class of the method.
+func (o *ConcurrencyLimitOptions) priority(method string) limitkit.Priority {
+	if p, ok := o.priorities[method]; ok {
+		return p
+	}
+
+	return limitkit.PriorityNormal
+}
+
+// dropped reports whether the call has failed due to the overload.
+func dropped(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,39 @@
+package grpckit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/limitkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimitUnaryInterceptor(t *testing.T) {
+	l := limitkit.NewLimiter("grpckit_test", limitkit.WithInitialLimit(1))
+
+	interceptor := ConcurrencyLimitUnaryInterceptor(l)
+
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+
+	f := func(method string, want any, wantCode codes.Code) {
+		t.Helper()
+
+		resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		td.Cmp(t, status.Code(err), wantCode, method)
+		td.Cmp(t, resp, want, method)
+	}
+
+	f("/svc/Method", "ok", codes.OK)
+
+	// Hold the only slot.
+	token, ok := l.Acquire(limitkit.PriorityNormal)
+	td.Require(t).True(ok)
+
+	t.Cleanup(func() { token.Release(false) })
+
+	f("/svc/Method", nil, codes.Unavailable)
+	f("/grpc.health.v1.Health/Check", "ok", codes.OK)
+}
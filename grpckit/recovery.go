@@ -0,0 +1,65 @@
+package grpckit
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// RecoveryUnaryInterceptor returns the gRPC unary server interceptor which recovers from panics
+// in the handlers. The panic is logged by the logger with the stack trace, the request ID and the
+// method, reported by the errkit.ErrorReporter as the errkit.PanicError, and responded by the
+// ErrorGRPC with codes.Internal. Place it last in the chain to recover the other interceptors too.
+func RecoveryUnaryInterceptor(logger *slog.Logger) UnaryInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				resp, err = recovered(ctx, logger, info.FullMethod, rec)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor returns the gRPC stream server interceptor.
+// See RecoveryUnaryInterceptor for the details.
+func RecoveryStreamInterceptor(logger *slog.Logger) StreamInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				_, err = recovered(ss.Context(), logger, info.FullMethod, rec)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs and responds the recovered panic.
+func recovered(ctx context.Context, logger *slog.Logger, method string, rec any) (any, error) {
+	err := errkit.NewPanicError(rec)
+
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-request-id"); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+
+	logger.ErrorContext(ctx, "Panic recovered",
+		slog.String("request_id", cmp.Or(ctxkit.GetRequestID(ctx), requestID)),
+		slog.String("method", method),
+		slog.String("panic", fmt.Sprint(rec)),
+		slog.String("stack", string(err.Stack)),
+	)
+
+	return ErrorGRPC[any](ctx, err, WithStatus(codes.Internal), WithErrorReport())
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/plainq/servekit/ctxkit"
 	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -23,6 +24,11 @@ var (
 			errkit.Report(err)
 		}
 
+		// Explicitly given error status overrides the mapped one.
+		if o.statusCode != codes.Unknown && o.statusCode != codes.OK {
+			return status.Error(o.statusCode, o.statusCode.String())
+		}
+
 		switch {
 		case errors.Is(err, errkit.ErrAlreadyExists):
 			return status.Error(codes.AlreadyExists, codes.AlreadyExists.String())
@@ -42,6 +48,12 @@ var (
 		case errors.Is(err, errkit.ErrUnavailable):
 			return status.Error(codes.Unavailable, codes.Unavailable.String())
 
+		case errors.Is(err, errkit.ErrPreconditionFailed):
+			return status.Error(codes.FailedPrecondition, codes.FailedPrecondition.String())
+
+		case errors.Is(err, context.DeadlineExceeded):
+			return status.Error(codes.DeadlineExceeded, codes.DeadlineExceeded.String())
+
 		default:
 			return status.Error(codes.Internal, codes.Internal.String())
 		}
@@ -51,11 +63,41 @@ var (
 // GRPCErrorResponder represents a function type that handles errors from gRPC responses.
 type GRPCErrorResponder func(err error, options ...ResponseOption) error
 
-// SetGRPCErrorResponder sets the given responder as errGRPCResponder.
+// errGRPCResponderKey represents a Key for context by which
+// the GRPCErrorResponder of the call can be received from the context.
+const errGRPCResponderKey ctxkit.Key = "ctx.grpckit.error-responder"
+
+// SetGRPCErrorResponder sets the given responder as errGRPCResponder, which is used
+// by default, when the call context has no responder. See WithErrorResponder.
 func SetGRPCErrorResponder(responder GRPCErrorResponder) {
 	errGRPCResponderInit.Do(func() { errGRPCResponder = responder })
 }
 
+// ErrorResponderUnaryInterceptor returns the gRPC unary server interceptor which sets
+// the responder to the call context, so it is used by the ErrorGRPC instead of the default one.
+func ErrorResponderUnaryInterceptor(responder GRPCErrorResponder) UnaryInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(ctxkit.Set(ctx, errGRPCResponderKey, responder), req)
+	}
+}
+
+// ErrorResponderStreamInterceptor returns the gRPC stream server interceptor.
+// See ErrorResponderUnaryInterceptor for the details.
+func ErrorResponderStreamInterceptor(responder GRPCErrorResponder) StreamInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, WrapServerStream(ctxkit.Set(ss.Context(), errGRPCResponderKey, responder), ss))
+	}
+}
+
+// errorResponder returns the responder of the call context or the default one.
+func errorResponder(ctx context.Context) GRPCErrorResponder {
+	if responder := ctxkit.Get[GRPCErrorResponder](ctx, errGRPCResponderKey); responder != nil {
+		return responder
+	}
+
+	return errGRPCResponder
+}
+
 // ErrorGRPC tries to map err to errkit.Error and based on result
 // writes standard gRPC error with status statusCode to the response writer.
 func ErrorGRPC[T any](ctx context.Context, err error, options ...ResponseOption) (T, error) {
@@ -65,7 +107,7 @@ func ErrorGRPC[T any](ctx context.Context, err error, options ...ResponseOption)
 		hook(err)
 	}
 
-	return zero[T](), errGRPCResponder(err, options...)
+	return zero[T](), errorResponder(ctx)(err, options...)
 }
 
 // ResponseOptions represents the options for an gRPC response.
@@ -98,6 +140,14 @@ func WithStatus(code codes.Code) ResponseOption {
 	}
 }
 
+// WithErrorReport is an Option function that will enable error reporting by the
+// errkit.ErrorReporter. Used in the default implementation of GRPCErrorResponder.
+func WithErrorReport() ResponseOption {
+	return func(o *ResponseOptions) {
+		o.reportError = true
+	}
+}
+
 // zero returns default zeroed value for type T.
 func zero[T any]() T {
 	var v T
@@ -0,0 +1,59 @@
+package grpckit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// TimeoutUnaryInterceptor returns the gRPC unary server interceptor which sets the deadline to
+// the call context. The methods map overrides the default timeout for the given full method names.
+// Zero timeout means no deadline. The deadline of the incoming grpc-timeout is honoured, if it is
+// shorter. If the deadline is exceeded, codes.DeadlineExceeded is returned through the ErrorGRPC.
+// The remaining budget is forwarded by the gRPC clients which use the call context.
+func TimeoutUnaryInterceptor(timeout time.Duration, methods map[string]time.Duration) UnaryInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withMethodTimeout(ctx, timeout, methods, info.FullMethod)
+		defer cancel()
+
+		resp, err := handler(ctx, req)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrorGRPC[any](ctx, context.DeadlineExceeded)
+		}
+
+		return resp, err
+	}
+}
+
+// TimeoutStreamInterceptor returns the gRPC stream server interceptor.
+// See TimeoutUnaryInterceptor for the details.
+func TimeoutStreamInterceptor(timeout time.Duration, methods map[string]time.Duration) StreamInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withMethodTimeout(ss.Context(), timeout, methods, info.FullMethod)
+		defer cancel()
+
+		err := handler(srv, WrapServerStream(ctx, ss))
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			_, err = ErrorGRPC[any](ctx, context.DeadlineExceeded)
+		}
+
+		return err
+	}
+}
+
+// withMethodTimeout returns the context with the timeout of the method.
+func withMethodTimeout(
+	ctx context.Context, timeout time.Duration, methods map[string]time.Duration, method string,
+) (context.Context, context.CancelFunc) {
+	if t, ok := methods[method]; ok {
+		timeout = t
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
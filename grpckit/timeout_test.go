@@ -0,0 +1,41 @@
+package grpckit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTimeoutUnaryInterceptor(t *testing.T) {
+	interceptor := TimeoutUnaryInterceptor(time.Second, map[string]time.Duration{
+		"/svc/Slow": 50 * time.Millisecond,
+		"/svc/Free": 0,
+	})
+
+	handler := func(ctx context.Context, _ any) (any, error) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+			_, ok := ctx.Deadline()
+			return ok, nil
+		}
+	}
+
+	f := func(method string, want any, wantCode codes.Code) {
+		t.Helper()
+
+		resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		td.Cmp(t, status.Code(err), wantCode, method)
+		td.Cmp(t, resp, want, method)
+	}
+
+	f("/svc/Fast", true, codes.OK)
+	f("/svc/Slow", nil, codes.DeadlineExceeded)
+	f("/svc/Free", false, codes.OK)
+}
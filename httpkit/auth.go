@@ -0,0 +1,137 @@
+package httpkit
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// ErrTokenMissing indicates that the request does not carry a bearer token.
+var ErrTokenMissing = errors.Join(errkit.ErrUnauthenticated, errors.New("bearer token is missing"))
+
+// JWTAuthOptions represents the options of the JWTAuthMiddleware.
+type JWTAuthOptions struct {
+	header string
+	cookie string
+	skip   func(r *http.Request) bool
+}
+
+// JWTAuthOption represents a function type that modifies JWTAuthOptions.
+type JWTAuthOption func(o *JWTAuthOptions)
+
+// WithJWTHeader sets the name of the header to extract the bearer token from.
+// Default is "Authorization". The value must have the "Bearer" scheme.
+func WithJWTHeader(name string) JWTAuthOption {
+	return func(o *JWTAuthOptions) { o.header = name }
+}
+
+// WithJWTCookie sets the name of the cookie to extract the token from
+// when the request has no token in the header.
+func WithJWTCookie(name string) JWTAuthOption {
+	return func(o *JWTAuthOptions) { o.cookie = name }
+}
+
+// WithJWTSkip sets the function which reports whether the authentication
+// of the request should be skipped. Multiple skip functions are combined.
+func WithJWTSkip(skip func(r *http.Request) bool) JWTAuthOption {
+	return func(o *JWTAuthOptions) {
+		if prev := o.skip; prev != nil {
+			o.skip = func(r *http.Request) bool { return prev(r) || skip(r) }
+			return
+		}
+
+		o.skip = skip
+	}
+}
+
+// WithJWTSkipPaths skips the authentication of the requests with the given URL paths.
+func WithJWTSkipPaths(paths ...string) JWTAuthOption {
+	set := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		set[path] = struct{}{}
+	}
+
+	return WithJWTSkip(func(r *http.Request) bool {
+		_, ok := set[r.URL.Path]
+		return ok
+	})
+}
+
+// JWTAuthMiddleware represents the bearer token authentication middleware.
+// It extracts the token from the request, verifies it by the given TokenManager,
+// and decodes its claims into T. Use jwtkit.Token as T to get the standard claims
+// along with metadata. Verified claims can be received from the request context
+// by the jwtkit.ClaimsFromContext. Requests without a valid token are rejected with 401.
+func JWTAuthMiddleware[T any](tm jwtkit.TokenManager, options ...JWTAuthOption) Middleware {
+	o := JWTAuthOptions{
+		header: "Authorization",
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if o.skip != nil && o.skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := o.token(r)
+			if token == "" {
+				unauthenticated(w, r, ErrTokenMissing)
+				return
+			}
+
+			claims, err := jwtkit.VerifyClaims[T](tm, token)
+			if err != nil {
+				unauthenticated(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(jwtkit.SetClaims(r.Context(), claims)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// token returns the token from the request header or cookie.
+func (o *JWTAuthOptions) token(r *http.Request) string {
+	if token, ok := BearerToken(r.Header.Get(o.header)); ok {
+		return token
+	}
+
+	if o.cookie != "" {
+		if cookie, err := r.Cookie(o.cookie); err == nil {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+// BearerToken returns the token from the value of the Authorization header with
+// the "Bearer" scheme. The second return value reports whether the token is present.
+func BearerToken(value string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+// unauthenticated responds with 401 and the bearer challenge.
+func unauthenticated(w http.ResponseWriter, r *http.Request, err error) {
+	ErrorHTTP(w, r, err,
+		WithStatus(http.StatusUnauthorized),
+		WithHeader("WWW-Authenticate", "Bearer"),
+	)
+}
@@ -0,0 +1,97 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cristalhq/jwt/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/authkit/jwtkit"
+)
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	verifier, err := jwt.NewVerifierHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	manager := jwtkit.NewTokenManager(signer, verifier)
+
+	valid, err := manager.Sign(&jwtkit.Token{Claims: jwtkit.Claims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}})
+	td.Require(t).CmpNoError(err)
+
+	expired, err := manager.Sign(&jwtkit.Token{Claims: jwtkit.Claims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}})
+	td.Require(t).CmpNoError(err)
+
+	handler := JWTAuthMiddleware[jwtkit.Token](manager,
+		WithJWTCookie("session"),
+		WithJWTSkipPaths("/health"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := jwtkit.TokenFromContext(r.Context())
+		if ok {
+			_, _ = w.Write([]byte(token.Subject))
+		}
+	}))
+
+	f := func(name string, path string, setup func(r *http.Request), wantStatus int, wantBody string) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+			setup(r)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			td.Cmp(t, w.Code, wantStatus)
+			td.Cmp(t, w.Body.String(), td.HasPrefix(wantBody))
+		})
+	}
+
+	f("Header", "/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+valid) }, http.StatusOK, "user")
+	f("Cookie", "/", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: valid}) }, http.StatusOK, "user")
+	f("Missing", "/", func(*http.Request) {}, http.StatusUnauthorized, "Unauthorized")
+	f("Expired", "/", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+expired) }, http.StatusUnauthorized, "Unauthorized")
+	f("Scheme", "/", func(r *http.Request) { r.Header.Set("Authorization", "Basic "+valid) }, http.StatusUnauthorized, "Unauthorized")
+	f("Skipped", "/health", func(*http.Request) {}, http.StatusOK, "")
+}
+
+func TestJWTAuthMiddleware_customClaims(t *testing.T) {
+	type claims struct {
+		jwtkit.Claims
+		Role string `json:"role"`
+	}
+
+	signer, err := jwt.NewSignerHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	verifier, err := jwt.NewVerifierHS(jwt.HS256, []byte("secret"))
+	td.Require(t).CmpNoError(err)
+
+	manager := jwtkit.NewTokenManager(signer, verifier)
+
+	token, err := jwt.NewBuilder(signer).Build(claims{Role: "admin"})
+	td.Require(t).CmpNoError(err)
+
+	var got *claims
+
+	handler := JWTAuthMiddleware[claims](manager)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got, _ = jwtkit.ClaimsFromContext[claims](r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Authorization", "bearer "+token.String())
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	td.Cmp(t, got, td.Struct(&claims{Role: "admin"}))
+}
@@ -0,0 +1,365 @@
+package httpkit
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// CaptureOptions represents the options of the Capture.
+type CaptureOptions struct {
+	size          int
+	bodyLimit     int
+	filter        func(r *http.Request) bool
+	secret        []byte
+	header        string
+	redactHeaders map[string]struct{}
+	redactQuery   map[string]struct{}
+}
+
+// CaptureOption represents a function type that modifies CaptureOptions.
+type CaptureOption func(o *CaptureOptions)
+
+// WithCaptureSize sets the number of the exchanges kept by the Capture, the oldest ones are dropped. Default is 100.
+func WithCaptureSize(size int) CaptureOption {
+	return func(o *CaptureOptions) { o.size = size }
+}
+
+// WithCaptureBodyLimit sets the number of bytes of the request and response bodies which are kept,
+// the rest is truncated. Default is 64 KiB.
+func WithCaptureBodyLimit(limit int) CaptureOption {
+	return func(o *CaptureOptions) { o.bodyLimit = limit }
+}
+
+// WithCaptureFilter sets the function which reports whether the request is captured,
+// e.g. by the path or the tenant of the customer which bug is reproduced.
+func WithCaptureFilter(filter func(r *http.Request) bool) CaptureOption {
+	return func(o *CaptureOptions) { o.filter = filter }
+}
+
+// WithCaptureSecret sets the secret which signs the tokens of the debug header, see Capture.Token.
+// The requests which carry the valid token are captured regardless of the filter.
+func WithCaptureSecret(secret []byte) CaptureOption {
+	return func(o *CaptureOptions) { o.secret = secret }
+}
+
+// WithCaptureHeader sets the name of the debug header. Default is X-Debug-Capture.
+func WithCaptureHeader(header string) CaptureOption {
+	return func(o *CaptureOptions) { o.header = http.CanonicalHeaderKey(header) }
+}
+
+// WithCaptureRedactHeaders sets the headers which values are redacted in the captured exchanges.
+// The Authorization, Proxy-Authorization, Cookie, Set-Cookie and the debug headers are redacted by default.
+func WithCaptureRedactHeaders(headers ...string) CaptureOption {
+	return func(o *CaptureOptions) {
+		for _, header := range headers {
+			o.redactHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+		}
+	}
+}
+
+// WithCaptureRedactQuery sets the query parameters which values are redacted in the captured URL.
+// The token and access_token parameters are redacted by default.
+func WithCaptureRedactQuery(params ...string) CaptureOption {
+	return func(o *CaptureOptions) {
+		for _, param := range params {
+			o.redactQuery[param] = struct{}{}
+		}
+	}
+}
+
+// CapturedExchange represents the captured request and its response.
+type CapturedExchange struct {
+	RequestID             string        `json:"request_id,omitempty"`
+	Time                  time.Time     `json:"time"`
+	Duration              time.Duration `json:"duration"`
+	Method                string        `json:"method"`
+	URL                   string        `json:"url"`
+	Proto                 string        `json:"proto"`
+	Remote                string        `json:"remote"`
+	RequestHeaders        http.Header   `json:"request_headers"`
+	RequestBody           string        `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool          `json:"request_body_truncated,omitempty"`
+	Status                int           `json:"status"`
+	ResponseHeaders       http.Header   `json:"response_headers"`
+	ResponseBody          string        `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool          `json:"response_body_truncated,omitempty"`
+}
+
+// Capture represents the recorder of the full HTTP exchanges for reproducing the bugs.
+// The requests which match the filter, or carry the debug header with the token signed
+// by the secret, are recorded with the headers, the truncated bodies, the status and
+// the duration into the bounded ring buffer. The sensitive headers and query parameters
+// are redacted. The exchanges are viewable by the admin endpoint, see Handler, which the
+// caller mounts behind its own authentication. Nothing is captured without the filter
+// or the secret.
+type Capture struct {
+	options CaptureOptions
+
+	mu   sync.Mutex
+	ring []CapturedExchange
+	next int
+	full bool
+}
+
+// NewCapture returns a pointer to a new instance of Capture.
+func NewCapture(options ...CaptureOption) *Capture {
+	o := CaptureOptions{
+		size:      100,
+		bodyLimit: 64 << 10,
+		header:    "X-Debug-Capture",
+		redactHeaders: map[string]struct{}{
+			"Authorization":       {},
+			"Proxy-Authorization": {},
+			"Cookie":              {},
+			"Set-Cookie":          {},
+		},
+		redactQuery: map[string]struct{}{
+			"token":        {},
+			"access_token": {},
+		},
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	o.redactHeaders[o.header] = struct{}{}
+
+	return &Capture{
+		options: o,
+		ring:    make([]CapturedExchange, max(o.size, 1)),
+	}
+}
+
+// Token returns the token of the debug header which is valid until the given time.
+// The token is the expiry unix time and its HMAC-SHA256 signature, e.g. 1735689600.c2lnbmF0dXJl.
+func (c *Capture) Token(expiry time.Time) string {
+	payload := strconv.FormatInt(expiry.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+func (c *Capture) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, c.options.secret)
+	mac.Write([]byte(payload))
+
+	return mac.Sum(nil)
+}
+
+// verify reports whether the token is signed by the secret and is not expired.
+func (c *Capture) verify(token string) bool {
+	if len(c.options.secret) == 0 || token == "" {
+		return false
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.sign(payload)) {
+		return false
+	}
+
+	expiry, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Unix() < expiry
+}
+
+// captured reports whether the request is captured.
+func (c *Capture) captured(r *http.Request) bool {
+	if c.verify(r.Header.Get(c.options.header)) {
+		return true
+	}
+
+	return c.options.filter != nil && c.options.filter(r)
+}
+
+// Exchanges returns the captured exchanges from the newest to the oldest.
+func (c *Capture) Exchanges() []CapturedExchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := c.next
+	if c.full {
+		count = len(c.ring)
+	}
+
+	exchanges := make([]CapturedExchange, 0, count)
+
+	for i := range count {
+		exchanges = append(exchanges, c.ring[(c.next-1-i+len(c.ring))%len(c.ring)])
+	}
+
+	return exchanges
+}
+
+// Reset drops the captured exchanges.
+func (c *Capture) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.ring)
+	c.next = 0
+	c.full = false
+}
+
+func (c *Capture) add(exchange CapturedExchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ring[c.next] = exchange
+	c.next = (c.next + 1) % len(c.ring)
+
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// Handler returns the admin endpoint which responds with the captured exchanges as the JSON on GET,
+// from the newest to the oldest, and drops them on DELETE. The exchanges carry the bodies of the
+// customers, so the endpoint must be protected by the authentication middleware.
+func (c *Capture) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			JSON(w, r, c.Exchanges(), WithHeader("Cache-Control", "no-store"))
+
+		case http.MethodDelete:
+			c.Reset()
+			Status(w, r, http.StatusNoContent)
+
+		default:
+			ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, errors.New("method not allowed")),
+				WithStatus(http.StatusMethodNotAllowed),
+			)
+		}
+	})
+}
+
+// Middleware returns the middleware which captures the requests.
+// The request body is captured as it is read by the handler, so the streaming is not affected.
+func (c *Capture) Middleware() Middleware {
+	return c.middleware()
+}
+
+// middleware returns the middleware with the given routes exempt from the capture.
+func (c *Capture) middleware(exempt ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			for _, route := range exempt {
+				if route = strings.TrimSuffix(route, "/"); r.URL.Path == route || strings.HasPrefix(r.URL.Path, route+"/") {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			if !c.captured(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now().UTC()
+
+			exchange := CapturedExchange{
+				RequestID:      ctxkit.GetRequestID(r.Context()),
+				Time:           start,
+				Method:         r.Method,
+				URL:            redactURI(r.URL, c.options.redactQuery),
+				Proto:          r.Proto,
+				Remote:         remoteAddr(r),
+				RequestHeaders: c.redact(r.Header),
+			}
+
+			reqBody := captureBuffer{limit: c.options.bodyLimit}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &captureBody{ReadCloser: r.Body, buf: &reqBody}
+			}
+
+			respBody := captureBuffer{limit: c.options.bodyLimit}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&respBody)
+
+			defer func() {
+				exchange.Duration = time.Since(start)
+				exchange.Status = cmp.Or(ww.Status(), http.StatusOK)
+				exchange.ResponseHeaders = c.redact(ww.Header())
+				exchange.RequestBody, exchange.RequestBodyTruncated = reqBody.String(), reqBody.truncated
+				exchange.ResponseBody, exchange.ResponseBodyTruncated = respBody.String(), respBody.truncated
+
+				c.add(exchange)
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// redact returns the copy of the header with the values of the redacted headers replaced.
+func (c *Capture) redact(header http.Header) http.Header {
+	redactedHeader := header.Clone()
+
+	for key, values := range redactedHeader {
+		if _, ok := c.options.redactHeaders[key]; ok {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+
+	return redactedHeader
+}
+
+// captureBuffer represents the buffer which keeps the first bytes written to it up to the limit.
+type captureBuffer struct {
+	data      []byte
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer interface. It never fails, so the response is not affected.
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	n := max(min(len(p), b.limit-len(b.data)), 0)
+	b.data = append(b.data, p[:n]...)
+
+	if n < len(p) {
+		b.truncated = true
+	}
+
+	return len(p), nil
+}
+
+func (b *captureBuffer) String() string { return string(b.data) }
+
+// captureBody represents the request body which copies the read bytes to the buffer.
+type captureBody struct {
+	io.ReadCloser
+
+	buf *captureBuffer
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	_, _ = b.buf.Write(p[:n])
+
+	return n, err
+}
@@ -0,0 +1,114 @@
+package httpkit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestCapture(t *testing.T) {
+	c := NewCapture(
+		WithCaptureSize(2),
+		WithCaptureBodyLimit(4),
+		WithCaptureSecret([]byte("secret")),
+		WithCaptureFilter(func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/customer") }),
+		WithCaptureRedactHeaders("X-Api-Key"),
+	)
+
+	l, err := NewListenerHTTP(":0", WithHealthCheck(), WithProfiler(PPROFConfig{}), WithCapture(c))
+	td.CmpNoError(t, err)
+
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.Header().Set("Set-Cookie", "session=1")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}
+
+	l.MountGroup("/", func(r chi.Router) {
+		r.Post("/customer", echo)
+		r.Post("/other", echo)
+	})
+
+	l.Mount("/admin/capture", c.Handler())
+
+	f := func(method, target, body string, header http.Header, wantStatus int) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for key, values := range header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		l.router.ServeHTTP(w, r)
+		td.Cmp(t, w.Code, wantStatus, "%s %s", method, target)
+
+		return w
+	}
+
+	w := f(http.MethodPost, "/customer?id=1&token=abc", "payload", http.Header{"X-Api-Key": {"key"}, "Accept": {"*/*"}}, http.StatusCreated)
+	td.Cmp(t, w.Body.String(), "payload")
+
+	td.Cmp(t, c.Exchanges(), td.Len(1))
+	td.Cmp(t, c.Exchanges()[0], td.Struct(CapturedExchange{
+		Method:                http.MethodPost,
+		URL:                   "/customer?id=1&token=%5BREDACTED%5D",
+		Proto:                 "HTTP/1.1",
+		Remote:                "192.0.2.1:1234",
+		RequestHeaders:        http.Header{"X-Api-Key": {redacted}, "Accept": {"*/*"}},
+		RequestBody:           "payl",
+		RequestBodyTruncated:  true,
+		Status:                http.StatusCreated,
+		ResponseHeaders:       http.Header{"Set-Cookie": {redacted}},
+		ResponseBody:          "payl",
+		ResponseBodyTruncated: true,
+	}, td.StructFields{
+		"Time":     td.Between(time.Now().Add(-time.Minute), time.Now()),
+		"Duration": td.Gte(time.Duration(0)),
+	}))
+
+	// Requests are not captured without the filter match or the valid token.
+	f(http.MethodPost, "/other", "", nil, http.StatusCreated)
+	f(http.MethodPost, "/other", "", http.Header{"X-Debug-Capture": {c.Token(time.Now().Add(-time.Minute))}}, http.StatusCreated)
+	f(http.MethodPost, "/other", "", http.Header{"X-Debug-Capture": {NewCapture().Token(time.Now().Add(time.Minute))}}, http.StatusCreated)
+	f(http.MethodGet, "/health", "", nil, http.StatusOK)
+	td.Cmp(t, c.Exchanges(), td.Len(1))
+
+	f(http.MethodPost, "/other", "ok", http.Header{"X-Debug-Capture": {c.Token(time.Now().Add(time.Minute))}}, http.StatusCreated)
+	f(http.MethodPost, "/customer", "", nil, http.StatusCreated)
+
+	// The oldest exchange is dropped.
+	td.Cmp(t, c.Exchanges(), td.Smuggle(func(exchanges []CapturedExchange) []string {
+		urls := make([]string, 0, len(exchanges))
+		for _, exchange := range exchanges {
+			urls = append(urls, exchange.URL)
+		}
+
+		return urls
+	}, []string{"/customer", "/other"}))
+
+	td.Cmp(t, c.Exchanges()[1].RequestHeaders.Get("X-Debug-Capture"), redacted)
+
+	// The exchanges are not exposed on the profiler route.
+	f(http.MethodGet, "/debug/capture", "", nil, http.StatusNotFound)
+
+	w = f(http.MethodGet, "/admin/capture", "", nil, http.StatusOK)
+
+	var exchanges []CapturedExchange
+	td.CmpNoError(t, json.Unmarshal(w.Body.Bytes(), &exchanges))
+	td.Cmp(t, exchanges, td.Len(2))
+
+	f(http.MethodDelete, "/admin/capture", "", nil, http.StatusNoContent)
+	td.Cmp(t, c.Exchanges(), td.Len(0))
+
+	f(http.MethodPost, "/admin/capture", "", nil, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,307 @@
+package httpkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plainq/servekit/errkit"
+)
+
+// ContentETag returns a strong entity tag computed as a hash of the given content.
+// The returned value is already quoted and can be used as an ETag header value.
+func ContentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// VersionETag returns a strong entity tag built from the caller-provided version,
+// for example, a row version or an updated_at timestamp of the entity.
+func VersionETag(version string) string {
+	return `"` + strings.ReplaceAll(version, `"`, "") + `"`
+}
+
+// WeakETag returns a weak entity tag built from the caller-provided version.
+// Weak entity tags indicate semantically equivalent, but not byte-identical representations.
+func WeakETag(version string) string {
+	return "W/" + VersionETag(version)
+}
+
+// CacheControl represents a typed value of the Cache-Control response header.
+// Zero durations are omitted from the resulting header value.
+type CacheControl struct {
+	// Public indicates that the response may be stored by any cache, including CDNs.
+	Public bool
+
+	// Private indicates that the response may be stored only by the client cache.
+	Private bool
+
+	// NoCache indicates that the response must be revalidated before each reuse.
+	NoCache bool
+
+	// NoStore indicates that the response must not be stored by any cache.
+	NoStore bool
+
+	// NoTransform indicates that intermediaries must not transform the response.
+	NoTransform bool
+
+	// MustRevalidate indicates that a stale response must be revalidated before reuse.
+	MustRevalidate bool
+
+	// ProxyRevalidate is the same as MustRevalidate, but only for shared caches.
+	ProxyRevalidate bool
+
+	// Immutable indicates that the response will not change while it is fresh.
+	Immutable bool
+
+	// MaxAge represents how long the response remains fresh.
+	MaxAge time.Duration
+
+	// SharedMaxAge represents how long the response remains fresh in shared caches.
+	SharedMaxAge time.Duration
+
+	// StaleWhileRevalidate represents how long a stale response can be reused
+	// while it is being revalidated in the background.
+	StaleWhileRevalidate time.Duration
+
+	// StaleIfError represents how long a stale response can be reused
+	// when the origin responds with an error.
+	StaleIfError time.Duration
+}
+
+// String returns the Cache-Control header value.
+func (c CacheControl) String() string {
+	directives := make([]string, 0, 12)
+
+	flags := []struct {
+		set  bool
+		name string
+	}{
+		{c.Public, "public"},
+		{c.Private, "private"},
+		{c.NoCache, "no-cache"},
+		{c.NoStore, "no-store"},
+		{c.NoTransform, "no-transform"},
+		{c.MustRevalidate, "must-revalidate"},
+		{c.ProxyRevalidate, "proxy-revalidate"},
+		{c.Immutable, "immutable"},
+	}
+
+	for _, f := range flags {
+		if f.set {
+			directives = append(directives, f.name)
+		}
+	}
+
+	durations := []struct {
+		value time.Duration
+		name  string
+	}{
+		{c.MaxAge, "max-age"},
+		{c.SharedMaxAge, "s-maxage"},
+		{c.StaleWhileRevalidate, "stale-while-revalidate"},
+		{c.StaleIfError, "stale-if-error"},
+	}
+
+	for _, d := range durations {
+		if d.value > 0 {
+			directives = append(directives, d.name+"="+strconv.FormatInt(int64(d.value/time.Second), 10))
+		}
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// WithETag sets the given entity tag as the ETag header of the response.
+// The value is quoted if it is not a valid entity tag yet.
+// GET and HEAD requests with matching If-None-Match header receive 304 Not Modified.
+func WithETag(etag string) ResponseOption {
+	return func(o *ResponseOptions) {
+		if tag, ok := scanETag(etag); !ok || tag != etag {
+			etag = VersionETag(etag)
+		}
+
+		o.etag = etag
+	}
+}
+
+// WithContentETag enables the strong ETag generation from the response body.
+// Has no effect for responders which stream the body and do not buffer it.
+func WithContentETag() ResponseOption {
+	return func(o *ResponseOptions) { o.contentETag = true }
+}
+
+// WithLastModified sets the Last-Modified header of the response.
+// GET and HEAD requests with If-Modified-Since header equal or after
+// the given time receive 304 Not Modified.
+func WithLastModified(t time.Time) ResponseOption {
+	return func(o *ResponseOptions) { o.lastModified = t }
+}
+
+// WithCacheControl sets the Cache-Control header of the response.
+func WithCacheControl(cc CacheControl) ResponseOption {
+	return func(o *ResponseOptions) { o.cacheControl = cc.String() }
+}
+
+// NotModified reports whether the client representation identified by
+// If-None-Match or If-Modified-Since request headers is still fresh.
+// Only GET and HEAD requests are evaluated.
+func NotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+
+		return matchETag(inm, etag, false)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// CheckPreconditions evaluates If-Match and If-Unmodified-Since request headers against
+// the current entity tag and modification time of the resource. It is intended to be
+// used by update handlers to implement optimistic concurrency control.
+// Returns errkit.ErrPreconditionFailed which is mapped to 412 by the default error responder.
+func CheckPreconditions(r *http.Request, etag string, lastModified time.Time) error {
+	if im := r.Header.Get("If-Match"); im != "" {
+		if etag == "" || !matchETag(im, etag, true) {
+			return errkit.ErrPreconditionFailed
+		}
+
+		return nil
+	}
+
+	ius := r.Header.Get("If-Unmodified-Since")
+	if ius == "" || lastModified.IsZero() {
+		return nil
+	}
+
+	// Invalid date must be ignored according to RFC 9110.
+	if t, err := http.ParseTime(ius); err == nil && lastModified.Truncate(time.Second).After(t) {
+		return errkit.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// conditional sets caching related headers to the response and reports
+// whether the request has been answered with 304 Not Modified.
+// The body is used to compute the strong ETag when WithContentETag was given.
+func (o *ResponseOptions) conditional(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	etag := o.etag
+
+	if etag == "" && o.contentETag && body != nil {
+		etag = ContentETag(body)
+	}
+
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if !o.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", o.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if o.cacheControl != "" {
+		w.Header().Set("Cache-Control", o.cacheControl)
+	}
+
+	if o.statusCode < http.StatusOK || o.statusCode >= http.StatusMultipleChoices {
+		return false
+	}
+
+	if !NotModified(r, etag, o.lastModified) {
+		return false
+	}
+
+	// Representation headers must not be sent with 304 response.
+	w.Header().Del("Content-Type")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// matchETag reports whether the list of entity tags from the If-Match or If-None-Match
+// header contains the given entity tag. The strong flag enables strong comparison.
+func matchETag(header, etag string, strong bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			break
+		}
+
+		tag, ok := scanETag(header)
+		if !ok {
+			return false
+		}
+
+		header = header[len(tag):]
+
+		if strong {
+			if !strings.HasPrefix(tag, "W/") && tag == etag && !strings.HasPrefix(etag, "W/") {
+				return true
+			}
+
+			continue
+		}
+
+		if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scanETag scans the beginning of s for a valid entity tag.
+// Returns the entity tag and true if it is valid.
+func scanETag(s string) (string, bool) {
+	start := 0
+
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+
+	if len(s[start:]) < 2 || s[start] != '"' {
+		return "", false
+	}
+
+	for i := start + 1; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '"':
+			return s[:i+1], true
+
+		// Characters allowed by the etagc rule of RFC 9110.
+		case c == 0x21 || c >= 0x23 && c <= 0x7E || c >= 0x80:
+
+		default:
+			return "", false
+		}
+	}
+
+	return "", false
+}
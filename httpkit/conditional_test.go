@@ -0,0 +1,114 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+)
+
+func TestCacheControl_String(t *testing.T) {
+	f := func(name string, cc CacheControl, want string) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			td.Cmp(t, cc.String(), want)
+		})
+	}
+
+	f("Empty", CacheControl{}, "")
+	f("NoStore", CacheControl{NoStore: true}, "no-store")
+	f("Public", CacheControl{Public: true, MaxAge: time.Hour, SharedMaxAge: 2 * time.Hour}, "public, max-age=3600, s-maxage=7200")
+	f("Immutable", CacheControl{Public: true, Immutable: true, MaxAge: 365 * 24 * time.Hour}, "public, immutable, max-age=31536000")
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f := func(name, method, header, value, etag string, want bool) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(method, "/", http.NoBody)
+			r.Header.Set(header, value)
+
+			td.Cmp(t, NotModified(r, etag, lastModified), want)
+		})
+	}
+
+	f("Match", http.MethodGet, "If-None-Match", `"v1"`, `"v1"`, true)
+	f("MatchList", http.MethodGet, "If-None-Match", `"v0", W/"v1"`, `"v1"`, true)
+	f("MatchAny", http.MethodHead, "If-None-Match", `*`, `"v1"`, true)
+	f("NoMatch", http.MethodGet, "If-None-Match", `"v2"`, `"v1"`, false)
+	f("NotSafeMethod", http.MethodPost, "If-None-Match", `"v1"`, `"v1"`, false)
+	f("ModifiedSinceEqual", http.MethodGet, "If-Modified-Since", lastModified.Format(http.TimeFormat), "", true)
+	f("ModifiedSinceBefore", http.MethodGet, "If-Modified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat), "", false)
+	f("ModifiedSinceInvalid", http.MethodGet, "If-Modified-Since", "yesterday", "", false)
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	f := func(name, header, value, etag string, want error) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", http.NoBody)
+			r.Header.Set(header, value)
+
+			td.Cmp(t, CheckPreconditions(r, etag, lastModified), want)
+		})
+	}
+
+	f("NoHeaders", "X-Test", "", `"v1"`, nil)
+	f("Match", "If-Match", `"v1"`, `"v1"`, nil)
+	f("MatchAny", "If-Match", `*`, `"v1"`, nil)
+	f("Mismatch", "If-Match", `"v0"`, `"v1"`, errkit.ErrPreconditionFailed)
+	f("WeakNeverMatch", "If-Match", `W/"v1"`, `W/"v1"`, errkit.ErrPreconditionFailed)
+	f("UnmodifiedSince", "If-Unmodified-Since", lastModified.Format(http.TimeFormat), "", nil)
+	f("ModifiedAfter", "If-Unmodified-Since", lastModified.Add(-time.Hour).Format(http.TimeFormat), "", errkit.ErrPreconditionFailed)
+}
+
+func TestJSON_conditional(t *testing.T) {
+	body := map[string]string{"hello": "world"}
+
+	w := httptest.NewRecorder()
+	JSON(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody), body,
+		WithContentETag(),
+		WithCacheControl(CacheControl{Private: true, NoCache: true}),
+	)
+
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Header().Get("Cache-Control"), "private, no-cache")
+
+	etag := w.Header().Get("ETag")
+	td.Cmp(t, etag, ContentETag([]byte(`{"hello":"world"}`+"\n")))
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("If-None-Match", etag)
+
+	w = httptest.NewRecorder()
+	JSON(w, r, body, WithContentETag())
+
+	td.Cmp(t, w.Code, http.StatusNotModified)
+	td.Cmp(t, w.Body.Len(), 0)
+	td.Cmp(t, w.Header().Get("ETag"), etag)
+	td.Cmp(t, w.Header().Get("Content-Type"), "")
+}
+
+func TestWithETag(t *testing.T) {
+	f := func(input, want string) {
+		t.Helper()
+
+		o := NewResponseOptions(httptest.NewRecorder(), WithETag(input))
+		td.Cmp(t, o.etag, want)
+	}
+
+	f("v1", `"v1"`)
+	f(`"v1"`, `"v1"`)
+	f(`W/"v1"`, `W/"v1"`)
+	f(`"v1" trailing`, `"v1 trailing"`)
+}
@@ -339,6 +339,14 @@ func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		bodyReader io.ReadSeeker
 	)
 
+	// Forward the remaining time budget of the request context to the server.
+	if deadline, ok := req.Context().Deadline(); ok && req.Header.Get(HeaderRequestTimeout) == "" {
+		if budget := time.Until(deadline); budget > 0 {
+			req = req.Clone(req.Context())
+			req.Header.Set(HeaderRequestTimeout, FormatRequestTimeout(budget))
+		}
+	}
+
 	if req.Body != nil {
 		body, readBodyErr := io.ReadAll(req.Body)
 		if readBodyErr != nil {
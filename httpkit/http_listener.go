@@ -3,13 +3,17 @@ package httpkit
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
@@ -25,15 +29,9 @@ import (
 )
 
 const (
-	// readTimeout represents default read timeout for the http.Server.
-	readTimeout = 2 * time.Second
-
 	// readHeaderTimeout represents default read header timeout for the http.Server.
 	readHeaderTimeout = 10 * time.Second
 
-	// writeTimeout represents default write timeout for the http.Server.
-	writeTimeout = 3 * time.Second
-
 	// idleTimeout represents default idle timeout for the http.Server.
 	idleTimeout = 10 * time.Second
 
@@ -72,6 +70,27 @@ func WithTLS(cert, key string) ListenerOption[ListenerConfig] {
 	}
 }
 
+// WithProxyProtocol enables the PROXY protocol v1 and v2 on the listener for the connections
+// from the given trusted proxies, e.g. the load balancer, so the r.RemoteAddr is the client address
+// from the header. Connections from the trusted proxies without the header are rejected, while the
+// other connections are served as is. The header must be received within the read header timeout.
+func WithProxyProtocol(trusted ...netip.Prefix) ListenerOption[ListenerConfig] {
+	return func(s *ListenerConfig) { s.proxyProtocol = append(s.proxyProtocol, trusted...) }
+}
+
+// WithMaintenance applies the Maintenance to each listener endpoint after the global middlewares.
+// The health, metrics and profiler routes stay reachable regardless of the maintenance mode.
+func WithMaintenance(m *Maintenance) ListenerOption[ListenerConfig] {
+	return func(s *ListenerConfig) { s.maintenance = m }
+}
+
+// WithCapture applies the Capture to each listener endpoint after the global middlewares, so the
+// request ID and the client IP are captured. The health, metrics and profiler routes are not captured.
+// The Capture.Handler is not mounted, mount it behind the authentication to view the exchanges.
+func WithCapture(c *Capture) ListenerOption[ListenerConfig] {
+	return func(s *ListenerConfig) { s.capture = c }
+}
+
 // WithGlobalMiddlewares sets given middlewares as router-wide middlewares.
 // Means that they will be applied to each server endpoint.
 func WithGlobalMiddlewares(middlewares ...Middleware) ListenerOption[ListenerConfig] {
@@ -80,12 +99,27 @@ func WithGlobalMiddlewares(middlewares ...Middleware) ListenerOption[ListenerCon
 	}
 }
 
+// WithErrorResponder sets the responder which is used by the ErrorHTTP for the requests
+// of the listener instead of the default one. See ErrorResponderMiddleware.
+func WithErrorResponder(responder HTTPErrorResponder) ListenerOption[ListenerConfig] {
+	return func(s *ListenerConfig) { s.errResponder = responder }
+}
+
+// WithHTMLTemplater sets the templater which is used by the TemplateHTML for the requests
+// of the listener instead of the default one. See TemplaterMiddleware.
+func WithHTMLTemplater(templater HTMLTemplateProvider) ListenerOption[ListenerConfig] {
+	return func(s *ListenerConfig) { s.templater = templater }
+}
+
 // WithHTTPServerTimeouts configures the HTTP listener TimeoutsConfig.
 // Receives the following option to configure the endpoint:
 // - HTTPServerReadHeaderTimeout - sets the http.Server ReadHeaderTimeout.
 // - HTTPServerReadTimeout - sets the http.Server ReadTimeout.
 // - HTTPServerWriteTimeout - sets the http.Server WriteTimeout.
 // - HTTPServerIdleTimeout - sets the http.Server IdleTimeout.
+//
+// By default, the ReadHeaderTimeout and the IdleTimeout are 10 seconds, and the ReadTimeout
+// and the WriteTimeout are not set, so the long uploads, downloads and streams are not cut.
 func WithHTTPServerTimeouts(options ...ListenerOption[TimeoutsConfig]) ListenerOption[ListenerConfig] {
 	return func(s *ListenerConfig) {
 		for _, opt := range options {
@@ -234,6 +268,22 @@ type ListenerHTTP struct {
 
 	router chi.Router
 	server *http.Server
+
+	// proxyProtocol holds the trusted proxies which
+	// connections carry the PROXY protocol header.
+	proxyProtocol []netip.Prefix
+
+	// hosts holds the virtual hosts, see Host.
+	hosts []*virtualHost
+
+	// proxies holds the mounted reverse proxies,
+	// which health checks run along with the listener.
+	proxies []*ReverseProxy
+
+	// shutdown is closed when the listener starts to shut down
+	// to notify long-lived handlers like streams. See ShutdownSignal.
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
 }
 
 // NewListenerHTTP creates a new ListenerHTTP with the specified address and options.
@@ -248,23 +298,79 @@ func NewListenerHTTP(addr string, options ...ListenerOption[ListenerConfig]) (*L
 			Addr:    addr,
 			Handler: router,
 		},
+		shutdown: make(chan struct{}),
+	}
+
+	// Notify long-lived handlers about the shutdown, because
+	// http.Server.Shutdown does not cancel contexts of active requests.
+	l.server.BaseContext = func(net.Listener) context.Context {
+		return withShutdownSignal(context.Background(), l.shutdown)
 	}
 
+	l.server.RegisterOnShutdown(func() {
+		l.shutdownOnce.Do(func() { close(l.shutdown) })
+	})
+
 	// Apply all option to the default applyOptionsHTTP.
 	cfg := applyOptionsHTTP(options...)
 
 	// Set listener logger.
 	l.logger = cfg.logger
+	l.proxyProtocol = cfg.proxyProtocol
+
+	// Set http.Server timeouts.
+	l.server.ReadHeaderTimeout = cfg.timeouts.readHeaderTimeout
+	l.server.ReadTimeout = cfg.timeouts.readTimeout
+	l.server.WriteTimeout = cfg.timeouts.writeTimeout
+	l.server.IdleTimeout = cfg.timeouts.idleTimeout
 
-	if l.enableTLS {
+	if cfg.cert != "" || cfg.key != "" {
 		if err := l.configureTLS(cfg); err != nil {
 			return nil, fmt.Errorf("configure TLS: %w", err)
 		}
 	}
 
+	// Set the listener responder and templater before
+	// the global middlewares, so they are able to use them.
+	if cfg.errResponder != nil {
+		l.router.Use(ErrorResponderMiddleware(cfg.errResponder))
+	}
+
+	if cfg.templater != nil {
+		l.router.Use(TemplaterMiddleware(cfg.templater))
+	}
+
 	// Use global middlewares.
 	l.router.Use(cfg.globalMiddlewares...)
 
+	// The health, metrics and profiler routes are served regardless
+	// of the maintenance mode and the virtual hosts.
+	var systemRoutes []string
+
+	for _, route := range []struct {
+		enable bool
+		route  string
+	}{
+		{enable: cfg.health.enable, route: cfg.health.route},
+		{enable: cfg.metrics.enable, route: cfg.metrics.route},
+		{enable: cfg.profiler.enable, route: cfg.profiler.route},
+	} {
+		if route.enable {
+			systemRoutes = append(systemRoutes, route.route)
+		}
+	}
+
+	if cfg.maintenance != nil {
+		l.router.Use(cfg.maintenance.middleware(systemRoutes...))
+	}
+
+	if cfg.capture != nil {
+		l.router.Use(cfg.capture.middleware(systemRoutes...))
+	}
+
+	// Route the requests of the virtual hosts, see Host.
+	l.router.Use(l.hostMiddleware(systemRoutes))
+
 	if err := l.configureHealth(cfg); err != nil {
 		return nil, fmt.Errorf("configure health: %w", err)
 	}
@@ -291,6 +397,18 @@ func (l *ListenerHTTP) Mount(route string, handler http.Handler, middlewares ...
 	})
 }
 
+// MountProxy mounts the reverse proxy on the given route with the given middlewares.
+// The health checks of the proxy upstreams run while the listener serves.
+func (l *ListenerHTTP) MountProxy(route string, proxy *ReverseProxy, middlewares ...Middleware) {
+	l.Mount(route, proxy, middlewares...)
+	l.proxies = append(l.proxies, proxy)
+}
+
+// MountVersions mounts the VersionRouter on the given route with the given middlewares.
+func (l *ListenerHTTP) MountVersions(route string, versions *VersionRouter, middlewares ...Middleware) {
+	l.Mount(route, versions, middlewares...)
+}
+
 func (l *ListenerHTTP) Serve(ctx context.Context) error {
 	if l.server.Addr == "" {
 		return fmt.Errorf("invalid listener address: %s", l.server.Addr)
@@ -301,8 +419,13 @@ func (l *ListenerHTTP) Serve(ctx context.Context) error {
 	// Handle shutdown signal in the background.
 	g.Go(func() error { return l.handleShutdown(serveCtx) })
 
+	// Run the health checks of the mounted proxies in the background.
+	for _, proxy := range l.proxies {
+		g.Go(func() error { return proxy.Run(serveCtx) })
+	}
+
 	g.Go(func() error {
-		protocol := tern.OP(l.enableTLS, "HTTPS", "HTTP")
+		protocol := tern.OP(l.tlsEnabled(), "HTTPS", "HTTP")
 
 		l.logger.Info(protocol+" listener started to listen",
 			slog.String("address", l.server.Addr),
@@ -335,12 +458,37 @@ func (l *ListenerHTTP) Serve(ctx context.Context) error {
 }
 
 func (l *ListenerHTTP) serveFunc() error {
+	// Select the certificates of the virtual hosts by the SNI.
+	if l.hostCertificates() {
+		l.server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: l.hostCertificate,
+		}
+	}
+
+	if len(l.proxyProtocol) == 0 {
+		switch {
+		case l.tlsEnabled():
+			return l.server.ListenAndServeTLS(l.cert, l.key)
+
+		default:
+			return l.server.ListenAndServe()
+		}
+	}
+
+	ln, err := net.Listen("tcp", l.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	ln = newProxyProtoListener(ln, l.proxyProtocol, l.server.ReadHeaderTimeout)
+
 	switch {
-	case l.enableTLS:
-		return l.server.ListenAndServeTLS(l.cert, l.key)
+	case l.tlsEnabled():
+		return l.server.ServeTLS(ln, l.cert, l.key)
 
 	default:
-		return l.server.ListenAndServe()
+		return l.server.Serve(ln)
 	}
 }
 
@@ -463,6 +611,23 @@ func (l *ListenerHTTP) handleShutdown(ctx context.Context) error {
 	return servekit.ErrGracefullyShutdown
 }
 
+// shutdownSignalKey represents a context key by which
+// the listener shutdown signal can be received from the context.
+const shutdownSignalKey ctxkit.Key = "ctx.httpkit.shutdown-signal"
+
+// withShutdownSignal sets the listener shutdown signal to the context.
+func withShutdownSignal(ctx context.Context, shutdown <-chan struct{}) context.Context {
+	return ctxkit.Set(ctx, shutdownSignalKey, shutdown)
+}
+
+// ShutdownSignal returns a channel which is closed when the ListenerHTTP serving the request
+// starts to shut down. Long-lived handlers like streams should return when it is closed,
+// otherwise they block the graceful shutdown until its timeout.
+// Returns nil channel, which is never closed, if the request is not served by ListenerHTTP.
+func ShutdownSignal(ctx context.Context) <-chan struct{} {
+	return ctxkit.Get[<-chan struct{}](ctx, shutdownSignalKey)
+}
+
 // ListenerConfig holds ListenerHTTP configuration.
 type ListenerConfig struct {
 	cert, key string
@@ -477,6 +642,24 @@ type ListenerConfig struct {
 	// which are applied to each endpoint.
 	globalMiddlewares []Middleware
 
+	// proxyProtocol holds the trusted proxies which
+	// connections carry the PROXY protocol header.
+	proxyProtocol []netip.Prefix
+
+	// maintenance holds the Maintenance of the listener.
+	maintenance *Maintenance
+
+	// capture holds the Capture of the listener.
+	capture *Capture
+
+	// errResponder holds the HTTPErrorResponder of the listener.
+	// If nil, the default one is used.
+	errResponder HTTPErrorResponder
+
+	// templater holds the HTMLTemplateProvider of the listener.
+	// If nil, the default one is used.
+	templater HTMLTemplateProvider
+
 	// health holds configuration of health endpoint.
 	health HealthConfig
 
@@ -493,8 +676,6 @@ func applyOptionsHTTP(options ...ListenerOption[ListenerConfig]) ListenerConfig
 
 		timeouts: TimeoutsConfig{
 			readHeaderTimeout: readHeaderTimeout,
-			readTimeout:       readTimeout,
-			writeTimeout:      writeTimeout,
 			idleTimeout:       idleTimeout,
 		},
 
@@ -1 +1,25 @@
 package httpkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestNewListenerHTTP_timeouts(t *testing.T) {
+	l, err := NewListenerHTTP(":0")
+	td.Require(t).CmpNoError(err)
+
+	// The requests and the responses are not cut by default.
+	td.Cmp(t, l.server.ReadHeaderTimeout, 10*time.Second)
+	td.Cmp(t, l.server.ReadTimeout, time.Duration(0))
+	td.Cmp(t, l.server.WriteTimeout, time.Duration(0))
+	td.Cmp(t, l.server.IdleTimeout, 10*time.Second)
+
+	l, err = NewListenerHTTP(":0", WithHTTPServerTimeouts(HTTPServerReadTimeout(time.Minute), HTTPServerWriteTimeout(time.Hour)))
+	td.Require(t).CmpNoError(err)
+
+	td.Cmp(t, l.server.ReadTimeout, time.Minute)
+	td.Cmp(t, l.server.WriteTimeout, time.Hour)
+}
@@ -0,0 +1,313 @@
+// Package idempotency implements the HTTP middleware which makes non-idempotent
+// requests safe to retry by following the Idempotency-Key HTTP header field draft:
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header
+package idempotency
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+const (
+	// HeaderKey represents the request header which carries the idempotency key.
+	HeaderKey = "Idempotency-Key"
+
+	// HeaderReplayed represents the response header which is set
+	// to "true" when the response has been replayed from the Store.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// defaultTTL represents default time after which the stored response expires.
+	defaultTTL = 24 * time.Hour
+
+	// defaultLockTTL represents default time after which the lock
+	// of the key expires if the first request has not been completed.
+	defaultLockTTL = time.Minute
+
+	// defaultMaxKeyLength represents default max length of the idempotency key.
+	defaultMaxKeyLength = 255
+
+	// defaultMaxBodySize represents default max size of the request body which is fingerprinted.
+	defaultMaxBodySize = 1 << 20
+
+	// defaultMaxResponseSize represents default max size of the response body which is stored.
+	defaultMaxResponseSize = 1 << 20
+)
+
+const (
+	// ErrKeyRequired indicates that the request has no idempotency key while it is required.
+	ErrKeyRequired Error = "idempotency key is required"
+
+	// ErrKeyInvalid indicates that the idempotency key is malformed.
+	ErrKeyInvalid Error = "idempotency key is invalid"
+
+	// ErrKeyInProgress indicates that the request with the same
+	// idempotency key is still being processed.
+	ErrKeyInProgress Error = "request with the same idempotency key is in progress"
+
+	// ErrKeyReused indicates that the idempotency key
+	// has been already used with a different request payload.
+	ErrKeyReused Error = "idempotency key is reused with a different request payload"
+)
+
+// Error represents package level errors.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Option represents a function which configures the middleware Options.
+type Option func(o *Options)
+
+// Options represents the configuration of the idempotency middleware.
+type Options struct {
+	ttl          time.Duration
+	lockTTL      time.Duration
+	maxKeyLength int
+	maxBodySize  int64
+	maxRespSize  int64
+	required     bool
+	methods      []string
+	scope        func(r *http.Request) string
+}
+
+// WithTTL sets the time after which the stored response expires,
+// and the key can be used again.
+func WithTTL(ttl time.Duration) Option { return func(o *Options) { o.ttl = ttl } }
+
+// WithLockTTL sets the time after which the lock of the key expires
+// if the first request has not been completed, e.g. the process crashed.
+func WithLockTTL(ttl time.Duration) Option { return func(o *Options) { o.lockTTL = ttl } }
+
+// WithRequired makes the idempotency key mandatory.
+// Requests without the key will be rejected with 400 Bad Request.
+func WithRequired() Option { return func(o *Options) { o.required = true } }
+
+// WithMethods sets the HTTP methods to which the middleware is applied.
+// By default, the middleware is applied to POST and PATCH requests.
+func WithMethods(methods ...string) Option { return func(o *Options) { o.methods = methods } }
+
+// WithMaxKeyLength sets the max length of the idempotency key.
+func WithMaxKeyLength(n int) Option { return func(o *Options) { o.maxKeyLength = n } }
+
+// WithMaxBodySize sets the max size of the request body, which is read to fingerprint the request.
+// Requests with the larger body are rejected with 413 Request Entity Too Large. Default is 1 MiB.
+func WithMaxBodySize(n int64) Option { return func(o *Options) { o.maxBodySize = n } }
+
+// WithMaxResponseSize sets the max size of the response body which is stored for the replay.
+// The larger responses are not stored, and the key is released, so the retries are processed
+// again as for the responses with 5xx status codes. Default is 1 MiB.
+func WithMaxResponseSize(n int64) Option { return func(o *Options) { o.maxRespSize = n } }
+
+// WithScope sets the function which returns the scope of the key, for example,
+// an authenticated user or tenant ID, so different clients can not collide.
+func WithScope(scope func(r *http.Request) string) Option {
+	return func(o *Options) { o.scope = scope }
+}
+
+// Middleware returns the HTTP middleware which locks the idempotency key, stores the first
+// response status, headers and body, and replays it for retries with the same request payload.
+//
+// Requests which reuse the key with a different payload are rejected with 422 Unprocessable Entity,
+// and concurrent requests with the key which is still in progress are rejected with 409 Conflict.
+// Responses with 5xx status codes and the responses larger than the max response size
+// are not stored, so the client can retry the request.
+func Middleware(store Store, options ...Option) httpkit.Middleware {
+	o := Options{
+		ttl:          defaultTTL,
+		lockTTL:      defaultLockTTL,
+		maxKeyLength: defaultMaxKeyLength,
+		maxBodySize:  defaultMaxBodySize,
+		maxRespSize:  defaultMaxResponseSize,
+		methods:      []string{http.MethodPost, http.MethodPatch},
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(o.methods, r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.Header.Get(HeaderKey)
+
+			if key == "" {
+				if o.required {
+					httpkit.ErrorHTTP(w, r, fmt.Errorf("%w: %w", errkit.ErrInvalidArgument, ErrKeyRequired))
+					return
+				}
+
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			if len(key) > o.maxKeyLength {
+				httpkit.ErrorHTTP(w, r, fmt.Errorf("%w: %w", errkit.ErrInvalidArgument, ErrKeyInvalid))
+				return
+			}
+
+			body, readErr := io.ReadAll(http.MaxBytesReader(w, r.Body, o.maxBodySize))
+			if readErr != nil {
+				var options []httpkit.ResponseOption
+
+				if maxBytesErr := new(http.MaxBytesError); errors.As(readErr, &maxBytesErr) {
+					options = append(options, httpkit.WithStatus(http.StatusRequestEntityTooLarge))
+				}
+
+				httpkit.ErrorHTTP(w, r, fmt.Errorf("%w: read request body: %w", errkit.ErrInvalidArgument, readErr), options...)
+
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if o.scope != nil {
+				key = o.scope(r) + ":" + key
+			}
+
+			fingerprint := requestFingerprint(r, body)
+
+			record, locked, lockErr := store.Lock(r.Context(), key, fingerprint, o.lockTTL)
+			if lockErr != nil {
+				httpkit.ErrorHTTP(w, r, fmt.Errorf("lock idempotency key: %w", lockErr))
+				return
+			}
+
+			if !locked {
+				replay(w, r, record, fingerprint)
+				return
+			}
+
+			buf := limitedBuffer{limit: o.maxRespSize}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			var (
+				completed = false
+				storeCtx  = context.WithoutCancel(r.Context())
+			)
+
+			// Release the lock if the handler panics, so the client can retry.
+			defer func() {
+				if !completed {
+					if err := store.Unlock(storeCtx, key); err != nil {
+						setLogErr(r, fmt.Errorf("unlock idempotency key: %w", err))
+					}
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			if ww.Status() >= http.StatusInternalServerError || buf.exceeded {
+				return
+			}
+
+			completed = true
+
+			// The http.Server responds with 200 if the handler has written nothing.
+			status := cmp.Or(ww.Status(), http.StatusOK)
+
+			result := Record{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				StatusCode:  status,
+				Header:      ww.Header().Clone(),
+				Body:        buf.Bytes(),
+			}
+
+			if err := store.Save(storeCtx, key, &result, o.ttl); err != nil {
+				setLogErr(r, fmt.Errorf("save idempotency record: %w", err))
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// replay writes the stored response of the given record
+// or rejects the request if it can not be replayed.
+func replay(w http.ResponseWriter, r *http.Request, record *Record, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		httpkit.ErrorHTTP(w, r, ErrKeyReused, httpkit.WithStatus(http.StatusUnprocessableEntity))
+		return
+	}
+
+	if !record.Completed {
+		httpkit.ErrorHTTP(w, r, fmt.Errorf("%w: %w", errkit.ErrAlreadyExists, ErrKeyInProgress),
+			httpkit.WithStatus(http.StatusConflict),
+		)
+
+		return
+	}
+
+	for key, values := range record.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+
+	w.Header().Set(HeaderReplayed, strconv.FormatBool(true))
+	w.WriteHeader(record.StatusCode)
+
+	if _, err := w.Write(record.Body); err != nil {
+		setLogErr(r, fmt.Errorf("write replayed response: %w", err))
+	}
+}
+
+// requestFingerprint returns a hash of the request method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setLogErr sets the error which will be logged along with access log line.
+func setLogErr(r *http.Request, err error) {
+	if hook := ctxkit.GetLogErrHook(r.Context()); hook != nil {
+		hook(err)
+	}
+}
+
+// limitedBuffer represents the buffer which drops its content once more than the limit is written.
+// The writes never fail, so the response is not affected.
+type limitedBuffer struct {
+	bytes.Buffer
+
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.exceeded || int64(b.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		b.Reset()
+
+		return len(p), nil
+	}
+
+	return b.Buffer.Write(p)
+}
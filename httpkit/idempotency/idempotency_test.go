@@ -0,0 +1,228 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int32
+
+	handler := Middleware(NewMemoryStore())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+
+		if r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("X-Call", strings.Repeat("1", int(n)))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	}))
+
+	do := func(method, key, body string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/payments", strings.NewReader(body))
+
+		if key != "" {
+			r.Header.Set(HeaderKey, key)
+		}
+
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	t.Run("FirstRequest", func(t *testing.T) {
+		w := do(http.MethodPost, "key-1", `{"amount":1}`)
+		td.Cmp(t, w.Code, http.StatusCreated)
+		td.Cmp(t, w.Body.String(), "created")
+		td.Cmp(t, w.Header().Get(HeaderReplayed), "")
+		td.Cmp(t, calls.Load(), int32(1))
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		w := do(http.MethodPost, "key-1", `{"amount":1}`)
+		td.Cmp(t, w.Code, http.StatusCreated)
+		td.Cmp(t, w.Body.String(), "created")
+		td.Cmp(t, w.Header().Get("X-Call"), "1")
+		td.Cmp(t, w.Header().Get(HeaderReplayed), "true")
+		td.Cmp(t, calls.Load(), int32(1))
+	})
+
+	t.Run("PayloadMismatch", func(t *testing.T) {
+		w := do(http.MethodPost, "key-1", `{"amount":2}`)
+		td.Cmp(t, w.Code, http.StatusUnprocessableEntity)
+		td.Cmp(t, calls.Load(), int32(1))
+	})
+
+	t.Run("ServerErrorIsNotStored", func(t *testing.T) {
+		w := do(http.MethodPost, "key-2", `{}`, "X-Fail", "1")
+		td.Cmp(t, w.Code, http.StatusInternalServerError)
+
+		w = do(http.MethodPost, "key-2", `{}`)
+		td.Cmp(t, w.Code, http.StatusCreated)
+		td.Cmp(t, w.Header().Get(HeaderReplayed), "")
+	})
+
+	t.Run("NoKey", func(t *testing.T) {
+		before := calls.Load()
+
+		td.Cmp(t, do(http.MethodPost, "", `{}`).Code, http.StatusCreated)
+		td.Cmp(t, do(http.MethodPost, "", `{}`).Code, http.StatusCreated)
+		td.Cmp(t, calls.Load(), before+2)
+	})
+
+	t.Run("SkippedMethod", func(t *testing.T) {
+		before := calls.Load()
+
+		td.Cmp(t, do(http.MethodGet, "key-1", "").Code, http.StatusCreated)
+		td.Cmp(t, calls.Load(), before+1)
+	})
+}
+
+func TestMiddleware_emptyResponse(t *testing.T) {
+	handler := Middleware(NewMemoryStore())(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, replayed := range []string{"", "true"} {
+		r := httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+		r.Header.Set(HeaderKey, "key")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, http.StatusOK)
+		td.Cmp(t, w.Header().Get(HeaderReplayed), replayed)
+	}
+}
+
+func TestMiddleware_bodyTooLarge(t *testing.T) {
+	handler := Middleware(NewMemoryStore(), WithMaxBodySize(4))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload"))
+	r.Header.Set(HeaderKey, "key")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	td.Cmp(t, w.Code, http.StatusRequestEntityTooLarge)
+}
+
+func TestMiddleware_responseTooLarge(t *testing.T) {
+	var calls int
+
+	handler := Middleware(NewMemoryStore(), WithMaxResponseSize(4))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		_, _ = w.Write([]byte("payload"))
+	}))
+
+	// The response is not stored, so the retry is processed again.
+	for range 2 {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		r.Header.Set(HeaderKey, "key")
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, http.StatusOK)
+		td.Cmp(t, w.Body.String(), "payload")
+		td.Cmp(t, w.Header().Get(HeaderReplayed), "")
+	}
+
+	td.Cmp(t, calls, 2)
+}
+
+func TestMiddleware_required(t *testing.T) {
+	handler := Middleware(NewMemoryStore(), WithRequired())(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+
+	td.Cmp(t, w.Code, http.StatusBadRequest)
+}
+
+func TestMiddleware_inProgress(t *testing.T) {
+	store := NewMemoryStore()
+
+	_, locked, err := store.Lock(t.Context(), "key", requestFingerprint(
+		httptest.NewRequest(http.MethodPost, "/", http.NoBody), []byte{},
+	), time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	handler := Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+	r.Header.Set(HeaderKey, "key")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	td.Cmp(t, w.Code, http.StatusConflict)
+}
+
+func TestMemoryStore_expiration(t *testing.T) {
+	now := time.Now()
+
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	_, locked, err := store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	record, locked, err := store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpFalse(t, locked)
+	td.Cmp(t, record, &Record{Fingerprint: "fp"})
+
+	now = now.Add(time.Minute)
+
+	_, locked, err = store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+}
+
+func TestMemoryStore_evict(t *testing.T) {
+	now := time.Now()
+
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		_, locked, err := store.Lock(t.Context(), key, "fp", time.Minute)
+		td.CmpNoError(t, err)
+		td.CmpTrue(t, locked)
+	}
+
+	// The saved record outlives its lock, the unlocked one is gone.
+	td.CmpNoError(t, store.Save(t.Context(), "a", &Record{Fingerprint: "fp", Completed: true}, time.Hour))
+	td.CmpNoError(t, store.Unlock(t.Context(), "b"))
+
+	now = now.Add(time.Minute)
+
+	_, locked, err := store.Lock(t.Context(), "d", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	td.Cmp(t, store.records, td.ContainsKey("a"))
+	td.Cmp(t, store.records, td.Len(2))
+	td.Cmp(t, store.expiry, td.Len(2))
+}
@@ -0,0 +1,153 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/plainq/servekit/dbkit/pgkit"
+)
+
+// Compilation time check that PostgresStore implements the Store.
+var _ Store = (*PostgresStore)(nil)
+
+const postgresSchema = `
+create table if not exists idempotency_keys
+(
+    key         text        not null primary key,
+    fingerprint text        not null,
+    completed   boolean     not null default false,
+    status_code integer     not null default 0,
+    header      jsonb,
+    body        bytea,
+    expires_at  timestamptz not null
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);`
+
+// PostgresStore implements Store interface on top of the pgkit.Conn.
+// The expired records are skipped on access and deleted by the DeleteExpired.
+type PostgresStore struct {
+	conn *pgkit.Conn
+	now  func() time.Time
+}
+
+// NewPostgresStore returns a pointer to a new instance of PostgresStore.
+// Creates the idempotency_keys table if it does not exist.
+func NewPostgresStore(conn *pgkit.Conn) (*PostgresStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, postgresSchema); err != nil {
+		return nil, fmt.Errorf("postgres: create idempotency_keys table: %w", err)
+	}
+
+	s := PostgresStore{
+		conn: conn,
+		now:  time.Now,
+	}
+
+	return &s, nil
+}
+
+func (s *PostgresStore) Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	const query = `
+		insert into idempotency_keys (key, fingerprint, expires_at)
+		values ($1, $2, $3)
+		on conflict (key) do update
+		    set fingerprint = excluded.fingerprint,
+		        completed   = false,
+		        status_code = 0,
+		        header      = null,
+		        body        = null,
+		        expires_at  = excluded.expires_at
+		where idempotency_keys.expires_at <= $4;`
+
+	now := s.now()
+
+	tag, execErr := s.conn.Exec(ctx, query, key, fingerprint, now.Add(ttl), now)
+	if execErr != nil {
+		return nil, false, fmt.Errorf("postgres: lock key: %w", execErr)
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil, true, nil
+	}
+
+	record, getErr := s.get(ctx, key)
+	if getErr != nil {
+		return nil, false, getErr
+	}
+
+	// The key has been unlocked concurrently, the client should retry.
+	if record == nil {
+		return &Record{Fingerprint: fingerprint}, false, nil
+	}
+
+	return record, false, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	const query = `
+		update idempotency_keys
+		set completed   = $1,
+		    status_code = $2,
+		    header      = $3,
+		    body        = $4,
+		    expires_at  = $5
+		where key = $6;`
+
+	if _, err := s.conn.Exec(ctx, query,
+		record.Completed, record.StatusCode, record.Header, record.Body, s.now().Add(ttl), key,
+	); err != nil {
+		return fmt.Errorf("postgres: save record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresStore) Unlock(ctx context.Context, key string) error {
+	const query = `delete from idempotency_keys where key = $1;`
+
+	if _, err := s.conn.Exec(ctx, query, key); err != nil {
+		return fmt.Errorf("postgres: unlock key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes all the expired records. It should be called periodically,
+// otherwise the table grows by a record per idempotency key.
+func (s *PostgresStore) DeleteExpired(ctx context.Context) error {
+	const query = `delete from idempotency_keys where expires_at <= $1;`
+
+	if _, err := s.conn.Exec(ctx, query, s.now()); err != nil {
+		return fmt.Errorf("postgres: delete expired records: %w", err)
+	}
+
+	return nil
+}
+
+// get returns the record by the given key or nil if there is no such record.
+func (s *PostgresStore) get(ctx context.Context, key string) (*Record, error) {
+	const query = `
+		select fingerprint, completed, status_code, header, body
+		from idempotency_keys
+		where key = $1;`
+
+	var record Record
+
+	if err := s.conn.QueryRow(ctx, query, key).Scan(
+		&record.Fingerprint, &record.Completed, &record.StatusCode, &record.Header, &record.Body,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("postgres: get record: %w", err)
+	}
+
+	return &record, nil
+}
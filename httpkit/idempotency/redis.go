@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	redisconn "github.com/plainq/servekit/dbkit/rediskit"
+	"github.com/redis/go-redis/v9"
+)
+
+// Compilation time check that RedisStore implements the Store.
+var _ Store = (*RedisStore)(nil)
+
+// redisKeyPrefix represents the prefix of the idempotency keys in Redis.
+const redisKeyPrefix = "idempotency:"
+
+// RedisStore implements Store interface on top of the rediskit.Conn.
+// Records expiration relies on Redis keys TTL.
+type RedisStore struct{ conn *redisconn.Conn }
+
+// NewRedisStore returns a pointer to a new instance of RedisStore.
+func NewRedisStore(conn *redisconn.Conn) *RedisStore { return &RedisStore{conn: conn} }
+
+func (s *RedisStore) Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	value, marshalErr := json.Marshal(Record{Fingerprint: fingerprint})
+	if marshalErr != nil {
+		return nil, false, fmt.Errorf("redis: marshal record: %w", marshalErr)
+	}
+
+	locked, lockErr := s.conn.SetNX(ctx, redisKeyPrefix+key, value, ttl).Result()
+	if lockErr != nil {
+		return nil, false, fmt.Errorf("redis: lock key: %w", lockErr)
+	}
+
+	if locked {
+		return nil, true, nil
+	}
+
+	stored, getErr := s.conn.Get(ctx, redisKeyPrefix+key).Bytes()
+	if getErr != nil {
+		// The key has been unlocked concurrently, the client should retry.
+		if errors.Is(getErr, redis.Nil) {
+			return &Record{Fingerprint: fingerprint}, false, nil
+		}
+
+		return nil, false, fmt.Errorf("redis: get record: %w", getErr)
+	}
+
+	var record Record
+
+	if err := json.Unmarshal(stored, &record); err != nil {
+		return nil, false, fmt.Errorf("redis: unmarshal record: %w", err)
+	}
+
+	return &record, false, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	value, marshalErr := json.Marshal(record)
+	if marshalErr != nil {
+		return fmt.Errorf("redis: marshal record: %w", marshalErr)
+	}
+
+	if err := s.conn.Set(ctx, redisKeyPrefix+key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis: save record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStore) Unlock(ctx context.Context, key string) error {
+	if err := s.conn.Del(ctx, redisKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("redis: unlock key: %w", err)
+	}
+
+	return nil
+}
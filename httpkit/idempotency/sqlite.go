@@ -0,0 +1,173 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/plainq/servekit/dbkit/litekit"
+)
+
+// Compilation time check that SQLiteStore implements the Store.
+var _ Store = (*SQLiteStore)(nil)
+
+const sqliteSchema = `
+create table if not exists idempotency_keys
+(
+    key         text    not null primary key,
+    fingerprint text    not null,
+    completed   integer not null default 0,
+    status_code integer not null default 0,
+    header      text,
+    body        blob,
+    expires_at  integer not null
+);
+
+create index if not exists idempotency_keys_expires_at_idx on idempotency_keys (expires_at);`
+
+// SQLiteStore implements Store interface on top of the litekit.Conn.
+// The expired records are skipped on access and deleted by the DeleteExpired.
+type SQLiteStore struct {
+	conn *litekit.Conn
+	now  func() time.Time
+}
+
+// NewSQLiteStore returns a pointer to a new instance of SQLiteStore.
+// Creates the idempotency_keys table if it does not exist.
+func NewSQLiteStore(conn *litekit.Conn) (*SQLiteStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, fmt.Errorf("sqlite: create idempotency_keys table: %w", err)
+	}
+
+	s := SQLiteStore{
+		conn: conn,
+		now:  time.Now,
+	}
+
+	return &s, nil
+}
+
+func (s *SQLiteStore) Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	const query = `
+		insert into idempotency_keys (key, fingerprint, expires_at)
+		values (?, ?, ?)
+		on conflict (key) do update
+		    set fingerprint = excluded.fingerprint,
+		        completed   = 0,
+		        status_code = 0,
+		        header      = null,
+		        body        = null,
+		        expires_at  = excluded.expires_at
+		where idempotency_keys.expires_at <= ?;`
+
+	now := s.now()
+
+	result, execErr := s.conn.ExecContext(ctx, query, key, fingerprint, now.Add(ttl).UnixNano(), now.UnixNano())
+	if execErr != nil {
+		return nil, false, fmt.Errorf("sqlite: lock key: %w", execErr)
+	}
+
+	affected, affectedErr := result.RowsAffected()
+	if affectedErr != nil {
+		return nil, false, fmt.Errorf("sqlite: lock key: %w", affectedErr)
+	}
+
+	if affected > 0 {
+		return nil, true, nil
+	}
+
+	record, getErr := s.get(ctx, key)
+	if getErr != nil {
+		return nil, false, getErr
+	}
+
+	// The key has been unlocked concurrently, the client should retry.
+	if record == nil {
+		return &Record{Fingerprint: fingerprint}, false, nil
+	}
+
+	return record, false, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	const query = `
+		update idempotency_keys
+		set completed   = ?,
+		    status_code = ?,
+		    header      = ?,
+		    body        = ?,
+		    expires_at  = ?
+		where key = ?;`
+
+	header, marshalErr := json.Marshal(record.Header)
+	if marshalErr != nil {
+		return fmt.Errorf("sqlite: marshal header: %w", marshalErr)
+	}
+
+	if _, err := s.conn.ExecContext(ctx, query,
+		record.Completed, record.StatusCode, string(header), record.Body, s.now().Add(ttl).UnixNano(), key,
+	); err != nil {
+		return fmt.Errorf("sqlite: save record: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SQLiteStore) Unlock(ctx context.Context, key string) error {
+	const query = `delete from idempotency_keys where key = ?;`
+
+	if _, err := s.conn.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("sqlite: unlock key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes all the expired records. It should be called periodically,
+// otherwise the table grows by a record per idempotency key.
+func (s *SQLiteStore) DeleteExpired(ctx context.Context) error {
+	const query = `delete from idempotency_keys where expires_at <= ?;`
+
+	if _, err := s.conn.ExecContext(ctx, query, s.now().UnixNano()); err != nil {
+		return fmt.Errorf("sqlite: delete expired records: %w", err)
+	}
+
+	return nil
+}
+
+// get returns the record by the given key or nil if there is no such record.
+func (s *SQLiteStore) get(ctx context.Context, key string) (*Record, error) {
+	const query = `
+		select fingerprint, completed, status_code, header, body
+		from idempotency_keys
+		where key = ?;`
+
+	var (
+		record Record
+		header sql.NullString
+	)
+
+	if err := s.conn.QueryRowContext(ctx, query, key).Scan(
+		&record.Fingerprint, &record.Completed, &record.StatusCode, &header, &record.Body,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("sqlite: get record: %w", err)
+	}
+
+	if header.Valid {
+		if err := json.Unmarshal([]byte(header.String), &record.Header); err != nil {
+			return nil, fmt.Errorf("sqlite: unmarshal header: %w", err)
+		}
+	}
+
+	return &record, nil
+}
@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/dbkit/litekit"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	conn, err := litekit.New(filepath.Join(t.TempDir(), "test.db"))
+	td.Require(t).CmpNoError(err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	store, err := NewSQLiteStore(conn)
+	td.Require(t).CmpNoError(err)
+
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	_, locked, err := store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	record, locked, err := store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpFalse(t, locked)
+	td.Cmp(t, record, &Record{Fingerprint: "fp"})
+
+	saved := Record{
+		Fingerprint: "fp",
+		Completed:   true,
+		StatusCode:  http.StatusCreated,
+		Header:      http.Header{"X-Test": []string{"1"}},
+		Body:        []byte("created"),
+	}
+
+	td.CmpNoError(t, store.Save(t.Context(), "key", &saved, time.Hour))
+
+	record, locked, err = store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpFalse(t, locked)
+	td.Cmp(t, record, &saved)
+
+	now = now.Add(time.Hour)
+
+	_, locked, err = store.Lock(t.Context(), "key", "fp", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	td.CmpNoError(t, store.Unlock(t.Context(), "key"))
+
+	_, locked, err = store.Lock(t.Context(), "key", "other", time.Minute)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	_, locked, err = store.Lock(t.Context(), "long", "fp", time.Hour)
+	td.CmpNoError(t, err)
+	td.CmpTrue(t, locked)
+
+	now = now.Add(time.Minute)
+
+	td.CmpNoError(t, store.DeleteExpired(t.Context()))
+
+	record, err = store.get(t.Context(), "key")
+	td.CmpNoError(t, err)
+	td.CmpNil(t, record)
+
+	record, err = store.get(t.Context(), "long")
+	td.CmpNoError(t, err)
+	td.Cmp(t, record, &Record{Fingerprint: "fp"})
+}
@@ -0,0 +1,159 @@
+package idempotency
+
+import (
+	"container/heap"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Compilation time check that MemoryStore implements the Store.
+var _ Store = (*MemoryStore)(nil)
+
+// Record represents a stored state of the request identified by the idempotency key.
+type Record struct {
+	// Fingerprint represents a hash of the request payload
+	// which was used with the key for the first time.
+	Fingerprint string `json:"fingerprint"`
+
+	// Completed indicates that the first request has been processed
+	// and the response is available for replay. Until then, the key is locked.
+	Completed bool `json:"completed"`
+
+	// StatusCode represents the status code of the first response.
+	StatusCode int `json:"status_code,omitempty"`
+
+	// Header represents the headers of the first response.
+	Header http.Header `json:"header,omitempty"`
+
+	// Body represents the body of the first response.
+	Body []byte `json:"body,omitempty"`
+}
+
+// Store holds the logic of storing idempotency records.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Lock atomically creates a locked record for the key with the given fingerprint
+	// if there is no record for the key yet. Returns true if the lock has been acquired,
+	// otherwise returns false and the existing record.
+	Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error)
+
+	// Save saves the completed record for the locked key.
+	// The record expires after the given ttl.
+	Save(ctx context.Context, key string, record *Record, ttl time.Duration) error
+
+	// Unlock removes the locked record for the key, so the request can be retried.
+	Unlock(ctx context.Context, key string) error
+}
+
+// MemoryStore implements Store interface keeping records in memory.
+// Suitable for tests and single-instance deployments.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+	expiry  expiryHeap
+	now     func() time.Time
+}
+
+type memoryRecord struct {
+	record    Record
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a pointer to a new instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	s := MemoryStore{
+		records: make(map[string]memoryRecord),
+		now:     time.Now,
+	}
+
+	return &s
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	s.evict(now)
+
+	if r, ok := s.records[key]; ok {
+		record := r.record
+		return &record, false, nil
+	}
+
+	s.set(key, memoryRecord{
+		record:    Record{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	})
+
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, memoryRecord{
+		record:    *record,
+		expiresAt: s.now().Add(ttl),
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// set stores the record and schedules its expiration. Must be called under the lock.
+func (s *MemoryStore) set(key string, r memoryRecord) {
+	s.records[key] = r
+	heap.Push(&s.expiry, expiryItem{key: key, expiresAt: r.expiresAt})
+}
+
+// evict removes expired records in the order of their expiration. The items of the records
+// which have been saved again or unlocked since are skipped. Must be called under the lock.
+func (s *MemoryStore) evict(now time.Time) {
+	for len(s.expiry) > 0 && !now.Before(s.expiry[0].expiresAt) {
+		item, _ := heap.Pop(&s.expiry).(expiryItem)
+
+		if r, ok := s.records[item.key]; ok && r.expiresAt.Equal(item.expiresAt) {
+			delete(s.records, item.key)
+		}
+	}
+}
+
+// expiryItem represents the scheduled expiration of the record.
+type expiryItem struct {
+	key       string
+	expiresAt time.Time
+}
+
+// expiryHeap implements heap.Interface ordering the items by the expiration time.
+type expiryHeap []expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x any) {
+	if item, ok := x.(expiryItem); ok {
+		*h = append(*h, item)
+	}
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return item
+}
@@ -0,0 +1,92 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plainq/servekit/limitkit"
+)
+
+// ConcurrencyLimitOptions represents the options of the ConcurrencyLimitMiddleware.
+type ConcurrencyLimitOptions struct {
+	priority      func(r *http.Request) limitkit.Priority
+	criticalPaths []string
+	retryAfter    time.Duration
+}
+
+// ConcurrencyLimitOption represents a function type that modifies ConcurrencyLimitOptions.
+type ConcurrencyLimitOption func(o *ConcurrencyLimitOptions)
+
+// WithConcurrencyPriority sets the function which returns the priority class of the request.
+// Default is limitkit.PriorityNormal for all requests.
+func WithConcurrencyPriority(fn func(r *http.Request) limitkit.Priority) ConcurrencyLimitOption {
+	return func(o *ConcurrencyLimitOptions) { o.priority = fn }
+}
+
+// WithConcurrencyCriticalPaths sets the path prefixes of the requests which are never shed,
+// e.g. the health check and admin routes.
+func WithConcurrencyCriticalPaths(paths ...string) ConcurrencyLimitOption {
+	return func(o *ConcurrencyLimitOptions) { o.criticalPaths = append(o.criticalPaths, paths...) }
+}
+
+// WithConcurrencyRetryAfter sets the Retry-After of the shed requests. Default is 1 second.
+func WithConcurrencyRetryAfter(d time.Duration) ConcurrencyLimitOption {
+	return func(o *ConcurrencyLimitOptions) { o.retryAfter = d }
+}
+
+// ConcurrencyLimitMiddleware returns the middleware which caps the number of in-flight requests
+// by the adaptive limit of the limitkit.Limiter. Shed requests are responded by the ErrorHTTP with
+// the limitkit.ErrLimitExceeded error, which is 503 by default, and the Retry-After header.
+// Responses with 503 or 504 status and requests which exceeded the context deadline are reported
+// to the limiter as dropped.
+func ConcurrencyLimitMiddleware(l *limitkit.Limiter, options ...ConcurrencyLimitOption) Middleware {
+	o := ConcurrencyLimitOptions{
+		priority:   func(*http.Request) limitkit.Priority { return limitkit.PriorityNormal },
+		retryAfter: time.Second,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	priority := func(r *http.Request) limitkit.Priority {
+		for _, path := range o.criticalPaths {
+			if strings.HasPrefix(r.URL.Path, path) {
+				return limitkit.PriorityCritical
+			}
+		}
+
+		return o.priority(r)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token, ok := l.Acquire(priority(r))
+			if !ok {
+				w.Header().Set("Retry-After", limitkit.RetryAfter(o.retryAfter))
+				ErrorHTTP(w, r, limitkit.ErrLimitExceeded)
+
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+
+				token.Release(status == http.StatusServiceUnavailable ||
+					status == http.StatusGatewayTimeout ||
+					errors.Is(r.Context().Err(), context.DeadlineExceeded),
+				)
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
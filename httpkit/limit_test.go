@@ -0,0 +1,36 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/limitkit"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	l := limitkit.NewLimiter("httpkit_test", limitkit.WithInitialLimit(1))
+
+	// Hold the only slot.
+	token, ok := l.Acquire(limitkit.PriorityNormal)
+	td.Require(t).True(ok)
+
+	t.Cleanup(func() { token.Release(false) })
+
+	handler := ConcurrencyLimitMiddleware(l, WithConcurrencyCriticalPaths("/health"))(
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }),
+	)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+
+	td.Cmp(t, w.Code, http.StatusServiceUnavailable)
+	td.Cmp(t, w.Header().Get("Retry-After"), "1")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, l.Inflight(), 1)
+}
@@ -0,0 +1,309 @@
+package httpkit
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/plainq/servekit/ctxkit"
+)
+
+// redacted represents the value which replaces the redacted values in the access log.
+const redacted = "[REDACTED]"
+
+// LogFormat represents the format of the access log line.
+type LogFormat uint8
+
+const (
+	// LogFormatStructured writes the access log line by the slog.Logger.
+	LogFormatStructured LogFormat = iota
+
+	// LogFormatCommon writes the access log line in the Common Log Format.
+	LogFormatCommon
+
+	// LogFormatCombined writes the access log line in the Combined Log Format,
+	// which is the Common Log Format with the referer and the user agent.
+	LogFormatCombined
+)
+
+// LoggingOptions represents the options of the LoggingMiddleware.
+type LoggingOptions struct {
+	redactQuery     map[string]struct{}
+	redactHeaders   map[string]struct{}
+	requestHeaders  []string
+	responseHeaders []string
+	sampleRate      float64
+	slowThreshold   time.Duration
+	slowLevel       slog.Level
+	format          LogFormat
+	output          io.Writer
+}
+
+// LoggingOption represents a function type that modifies LoggingOptions.
+type LoggingOption func(o *LoggingOptions)
+
+// WithLogRedactQuery sets the query parameters which values are redacted in the logged URI.
+func WithLogRedactQuery(params ...string) LoggingOption {
+	return func(o *LoggingOptions) {
+		for _, param := range params {
+			o.redactQuery[param] = struct{}{}
+		}
+	}
+}
+
+// WithLogRedactHeaders sets the headers which values are redacted in the logged headers.
+// The Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are redacted by default.
+func WithLogRedactHeaders(headers ...string) LoggingOption {
+	return func(o *LoggingOptions) {
+		for _, header := range headers {
+			o.redactHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+		}
+	}
+}
+
+// WithLogRequestHeaders sets the request headers to log.
+func WithLogRequestHeaders(headers ...string) LoggingOption {
+	return func(o *LoggingOptions) { o.requestHeaders = append(o.requestHeaders, headers...) }
+}
+
+// WithLogResponseHeaders sets the response headers to log.
+func WithLogResponseHeaders(headers ...string) LoggingOption {
+	return func(o *LoggingOptions) { o.responseHeaders = append(o.responseHeaders, headers...) }
+}
+
+// WithLogSampling sets the share of the successful requests to log, from 0 to 1.
+// Requests which have failed with the 4xx or 5xx status, or are slow, are always logged.
+// Default is 1, so all requests are logged.
+func WithLogSampling(rate float64) LoggingOption {
+	return func(o *LoggingOptions) { o.sampleRate = rate }
+}
+
+// WithLogSlowThreshold sets the duration after which the request is logged with the given level,
+// unless the status requires the higher one. Zero threshold disables the check, which is the default.
+func WithLogSlowThreshold(threshold time.Duration, level slog.Level) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.slowThreshold = threshold
+		o.slowLevel = level
+	}
+}
+
+// WithLogFormat sets the format of the access log line. The lines of the Common and
+// the Combined formats are written to the given writer instead of the slog.Logger.
+// The writer must be safe for concurrent use.
+func WithLogFormat(format LogFormat, w io.Writer) LoggingOption {
+	return func(o *LoggingOptions) {
+		o.format = format
+		o.output = w
+	}
+}
+
+// LoggingMiddleware represents logging middleware.
+// By default, it logs every request by the logger with the method, status, route pattern, URI,
+// remote address, user agent, response size and duration. Requests which have failed with the 5xx
+// status are logged with the error level.
+func LoggingMiddleware(logger *slog.Logger, options ...LoggingOption) Middleware {
+	o := LoggingOptions{
+		redactQuery: make(map[string]struct{}),
+		redactHeaders: map[string]struct{}{
+			"Authorization":       {},
+			"Proxy-Authorization": {},
+			"Cookie":              {},
+			"Set-Cookie":          {},
+		},
+		sampleRate: 1,
+		slowLevel:  slog.LevelWarn,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now().UTC()
+
+			var (
+				reqErr   error
+				reqAttrs []any
+			)
+
+			ctx := ctxkit.SetLogErrHook(r.Context(), func(err error) { reqErr = err })
+			ctx = ctxkit.SetLogAttrHook(ctx, func(attrs ...slog.Attr) {
+				for _, attr := range attrs {
+					reqAttrs = append(reqAttrs, attr)
+				}
+			})
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			duration := time.Since(start)
+
+			// The http.Server responds with 200 if the handler has written nothing.
+			status := cmp.Or(ww.Status(), http.StatusOK)
+			slow := o.slowThreshold > 0 && duration >= o.slowThreshold
+
+			sampled := o.sampleRate >= 1 || rand.Float64() < o.sampleRate //nolint:gosec // Sampling does not need the secure random.
+			if status < http.StatusBadRequest && !slow && !sampled {
+				return
+			}
+
+			uri := redactURI(r.URL, o.redactQuery)
+
+			if o.format != LogFormatStructured {
+				o.writeLine(r, start, uri, status, ww.BytesWritten())
+				return
+			}
+
+			var route string
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+
+			attrs := []any{
+				slog.String("method", r.Method),
+				slog.String("status", strconv.Itoa(status)),
+				slog.String("route", route),
+				slog.String("uri", uri),
+				slog.String("remote", remoteAddr(r)),
+				slog.String("user_agent", r.UserAgent()),
+				slog.Int("size", ww.BytesWritten()),
+				slog.Duration("duration", duration),
+			}
+
+			if len(o.requestHeaders) > 0 {
+				attrs = append(attrs, o.headersGroup("request_headers", r.Header, o.requestHeaders))
+			}
+
+			if len(o.responseHeaders) > 0 {
+				attrs = append(attrs, o.headersGroup("response_headers", ww.Header(), o.responseHeaders))
+			}
+
+			if slow {
+				attrs = append(attrs, slog.Bool("slow", true))
+			}
+
+			mwLogger := logger.With(attrs...).With(reqAttrs...)
+
+			level := slog.LevelInfo
+			if slow {
+				level = o.slowLevel
+			}
+
+			if status >= http.StatusInternalServerError {
+				level = max(level, slog.LevelError)
+
+				if reqErr != nil {
+					mwLogger.Log(ctx, level, strconv.Itoa(status)+" "+http.StatusText(status),
+						slog.String("error", reqErr.Error()),
+					)
+
+					return
+				}
+			}
+
+			mwLogger.Log(ctx, level, strconv.Itoa(status)+" "+http.StatusText(status))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// remoteAddr returns the client IP resolved by the RealIPMiddleware or the peer address.
+func remoteAddr(r *http.Request) string {
+	if ip := ctxkit.GetClientIP(r.Context()); ip.IsValid() {
+		return ip.String()
+	}
+
+	return r.RemoteAddr
+}
+
+// redactURI returns the request URI with the values of the given query parameters replaced.
+func redactURI(u *url.URL, params map[string]struct{}) string {
+	if len(params) == 0 || u.RawQuery == "" {
+		return u.RequestURI()
+	}
+
+	query := u.Query()
+	changed := false
+
+	for param, values := range query {
+		if _, ok := params[param]; ok {
+			for i := range values {
+				values[i] = redacted
+			}
+
+			changed = true
+		}
+	}
+
+	if !changed {
+		return u.RequestURI()
+	}
+
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+
+	return redactedURL.RequestURI()
+}
+
+// headersGroup returns the log attribute group with the given headers.
+func (o *LoggingOptions) headersGroup(name string, header http.Header, keys []string) slog.Attr {
+	attrs := make([]any, 0, len(keys))
+
+	for _, key := range keys {
+		key = http.CanonicalHeaderKey(key)
+
+		values := header.Values(key)
+		if len(values) == 0 {
+			continue
+		}
+
+		value := strings.Join(values, ", ")
+		if _, ok := o.redactHeaders[key]; ok {
+			value = redacted
+		}
+
+		attrs = append(attrs, slog.String(key, value))
+	}
+
+	return slog.Group(name, attrs...)
+}
+
+// writeLine writes the access log line in the Common or the Combined Log Format.
+func (o *LoggingOptions) writeLine(r *http.Request, start time.Time, uri string, status, size int) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if ip := ctxkit.GetClientIP(r.Context()); ip.IsValid() {
+		host = ip.String()
+	}
+
+	user := "-"
+	if r.URL.User != nil && r.URL.User.Username() != "" {
+		user = r.URL.User.Username()
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %q %d %d",
+		host, user, start.Format("02/Jan/2006:15:04:05 -0700"), r.Method+" "+uri+" "+r.Proto, status, size,
+	)
+
+	if o.format == LogFormatCombined {
+		line += fmt.Sprintf(" %q %q", r.Referer(), r.UserAgent())
+	}
+
+	_, _ = io.WriteString(o.output, line+"\n")
+}
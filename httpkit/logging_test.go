@@ -0,0 +1,101 @@
+package httpkit
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+	router := chi.NewRouter()
+	router.Use(LoggingMiddleware(logger,
+		WithLogRedactQuery("token"),
+		WithLogRequestHeaders("Authorization", "X-Trace"),
+		WithLogResponseHeaders("Content-Type"),
+	))
+	router.Get("/items/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/items/1?token=secret&page=2", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("X-Trace", "abc")
+	r.Header.Set("User-Agent", "test")
+
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	td.Cmp(t, buf.String(), td.All(
+		td.Contains(`level=INFO msg="200 OK"`),
+		td.Contains(`route=/items/{id}`),
+		td.Contains(`uri="/items/1?page=2&token=%5BREDACTED%5D"`),
+		td.Contains(`user_agent=test`),
+		td.Contains(`size=5`),
+		td.Contains(`request_headers.Authorization=[REDACTED]`),
+		td.Contains(`request_headers.X-Trace=abc`),
+		td.Contains(`response_headers.Content-Type=text/plain`),
+		td.Not(td.Contains("secret")),
+	))
+}
+
+func TestLoggingMiddleware_sampling(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := LoggingMiddleware(slog.New(slog.NewTextHandler(&buf, nil)),
+		WithLogSampling(0),
+		WithLogSlowThreshold(10*time.Millisecond, slog.LevelWarn),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadGateway)
+
+		case "/slow":
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+	td.Cmp(t, buf.String(), "", "successful requests are sampled out")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	td.Cmp(t, buf.String(), td.Contains(`level=ERROR msg="502 Bad Gateway"`))
+
+	buf.Reset()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+	td.Cmp(t, buf.String(), td.All(td.Contains(`level=WARN msg="200 OK"`), td.Contains("slow=true")))
+}
+
+func TestLoggingMiddleware_combinedFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := LoggingMiddleware(slog.Default(),
+		WithLogFormat(LogFormatCombined, &buf),
+		WithLogRedactQuery("token"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{}"))
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/items?token=secret", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("Referer", "https://example.com/")
+	r.Header.Set("User-Agent", "test")
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	td.Cmp(t, buf.String(), td.Re(
+		`^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} \+0000\] `+
+			`"POST /items\?token=%5BREDACTED%5D HTTP/1\.1" 201 2 "https://example\.com/" "test"\n$`,
+	))
+}
@@ -0,0 +1,269 @@
+package httpkit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/limitkit"
+)
+
+// MaintenanceMode represents the mode of the Maintenance switch.
+type MaintenanceMode uint32
+
+const (
+	// MaintenanceOff serves all requests.
+	MaintenanceOff MaintenanceMode = iota
+
+	// MaintenanceReadOnly serves only the requests of the safe methods, e.g. GET.
+	MaintenanceReadOnly
+
+	// MaintenanceOn serves no requests but the exempt ones.
+	MaintenanceOn
+)
+
+func (m MaintenanceMode) String() string {
+	switch m {
+	case MaintenanceOff:
+		return "off"
+
+	case MaintenanceReadOnly:
+		return "read-only"
+
+	case MaintenanceOn:
+		return "on"
+
+	default:
+		return "unknown"
+	}
+}
+
+// ParseMaintenanceMode parses the mode from its string representation: off, read-only or on.
+func ParseMaintenanceMode(s string) (MaintenanceMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "off":
+		return MaintenanceOff, nil
+
+	case "read-only":
+		return MaintenanceReadOnly, nil
+
+	case "on":
+		return MaintenanceOn, nil
+
+	default:
+		return MaintenanceOff, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("unknown maintenance mode: %q", s))
+	}
+}
+
+// MaintenanceOptions represents the options of the Maintenance.
+type MaintenanceOptions struct {
+	retryAfter time.Duration
+	exempt     []string
+	html       []byte
+	json       any
+	logger     *slog.Logger
+}
+
+// MaintenanceOption represents a function type that modifies MaintenanceOptions.
+type MaintenanceOption func(o *MaintenanceOptions)
+
+// WithMaintenanceRetryAfter sets the duration of the Retry-After header of the responses. Default is 1 minute.
+func WithMaintenanceRetryAfter(d time.Duration) MaintenanceOption {
+	return func(o *MaintenanceOptions) { o.retryAfter = d }
+}
+
+// WithMaintenanceExempt sets the routes which are served regardless of the mode, e.g. the admin
+// endpoint of the Maintenance. Each route matches the path itself and the paths under it.
+func WithMaintenanceExempt(routes ...string) MaintenanceOption {
+	return func(o *MaintenanceOptions) { o.exempt = append(o.exempt, routes...) }
+}
+
+// WithMaintenanceHTML sets the page which is responded to the clients which accept the HTML.
+func WithMaintenanceHTML(page []byte) MaintenanceOption {
+	return func(o *MaintenanceOptions) { o.html = page }
+}
+
+// WithMaintenanceJSON sets the value which is responded as the JSON to the other clients.
+func WithMaintenanceJSON(v any) MaintenanceOption {
+	return func(o *MaintenanceOptions) { o.json = v }
+}
+
+// WithMaintenanceLogger sets the logger of the mode changes. Default is slog.Default.
+func WithMaintenanceLogger(logger *slog.Logger) MaintenanceOption {
+	return func(o *MaintenanceOptions) { o.logger = logger }
+}
+
+// Maintenance represents the switch which puts the service into the read-only or the maintenance mode
+// at runtime. The requests which are not allowed in the current mode are responded with 503 status,
+// the Retry-After header, and the configured HTML page or JSON, or the ErrorHTTP response otherwise.
+// The mode can be changed from code by the Set, by the admin endpoint, see Handler, and by the signals,
+// see ToggleOnSignal. Use the WithMaintenance to apply it to the ListenerHTTP.
+type Maintenance struct {
+	mode    atomic.Uint32
+	options MaintenanceOptions
+}
+
+// NewMaintenance returns a pointer to a new instance of Maintenance in the MaintenanceOff mode.
+func NewMaintenance(options ...MaintenanceOption) *Maintenance {
+	o := MaintenanceOptions{
+		retryAfter: time.Minute,
+		logger:     slog.Default(),
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &Maintenance{options: o}
+}
+
+// Mode returns the current mode.
+func (m *Maintenance) Mode() MaintenanceMode { return MaintenanceMode(m.mode.Load()) }
+
+// Set sets the current mode.
+func (m *Maintenance) Set(mode MaintenanceMode) {
+	if prev := MaintenanceMode(m.mode.Swap(uint32(mode))); prev != mode {
+		m.options.logger.Info("Maintenance mode changed",
+			slog.String("from", prev.String()),
+			slog.String("to", mode.String()),
+		)
+	}
+}
+
+// ToggleOnSignal toggles the mode between the MaintenanceOff and the given one
+// on each of the given signals, e.g. syscall.SIGUSR1, until the context is done.
+// It does nothing without the signals, rather than toggling on every incoming one.
+func (m *Maintenance) ToggleOnSignal(ctx context.Context, mode MaintenanceMode, signals ...os.Signal) {
+	if len(signals) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ch:
+				if m.Mode() == MaintenanceOff {
+					m.Set(mode)
+				} else {
+					m.Set(MaintenanceOff)
+				}
+			}
+		}
+	}()
+}
+
+// maintenanceState represents the body of the admin endpoint.
+type maintenanceState struct {
+	Mode string `json:"mode"`
+}
+
+// Handler returns the admin endpoint which responds with the current mode on GET, e.g. {"mode":"off"},
+// and sets the mode from the same body on PUT or POST. The endpoint must be protected by the
+// authentication middleware and exempt by the WithMaintenanceExempt to be reachable in the MaintenanceOn mode.
+func (m *Maintenance) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+
+		case http.MethodPut, http.MethodPost:
+			var state maintenanceState
+
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&state); err != nil {
+				ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("decode maintenance state: %w", err)))
+				return
+			}
+
+			mode, err := ParseMaintenanceMode(state.Mode)
+			if err != nil {
+				ErrorHTTP(w, r, err)
+				return
+			}
+
+			m.Set(mode)
+
+		default:
+			ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, errors.New("method not allowed")),
+				WithStatus(http.StatusMethodNotAllowed),
+			)
+
+			return
+		}
+
+		JSON(w, r, maintenanceState{Mode: m.Mode().String()})
+	})
+}
+
+// Middleware returns the middleware which responds to the requests which are not allowed in the current mode.
+func (m *Maintenance) Middleware() Middleware {
+	return m.middleware()
+}
+
+// middleware returns the middleware with the given routes exempt in addition to the configured ones.
+func (m *Maintenance) middleware(exempt ...string) Middleware {
+	exempt = append(exempt, m.options.exempt...)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch m.Mode() {
+			case MaintenanceOff:
+				next.ServeHTTP(w, r)
+				return
+
+			case MaintenanceReadOnly:
+				switch r.Method {
+				case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			for _, route := range exempt {
+				if route = strings.TrimSuffix(route, "/"); r.URL.Path == route || strings.HasPrefix(r.URL.Path, route+"/") {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			m.respond(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// respond responds with 503 status and the configured page.
+func (m *Maintenance) respond(w http.ResponseWriter, r *http.Request) {
+	options := []ResponseOption{
+		WithStatus(http.StatusServiceUnavailable),
+		WithHeader("Retry-After", limitkit.RetryAfter(m.options.retryAfter)),
+		WithHeader("Cache-Control", "no-store"),
+	}
+
+	switch {
+	case m.options.html != nil && strings.Contains(r.Header.Get("Accept"), "text/html"):
+		HTML(w, r, m.options.html, options...)
+
+	case m.options.json != nil:
+		JSON(w, r, m.options.json, options...)
+
+	default:
+		ErrorHTTP(w, r, errors.Join(errkit.ErrUnavailable, fmt.Errorf("maintenance mode is %s", m.Mode())), options...)
+	}
+}
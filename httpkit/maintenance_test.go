@@ -0,0 +1,107 @@
+package httpkit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestMaintenance(t *testing.T) {
+	m := NewMaintenance(
+		WithMaintenanceRetryAfter(2*time.Minute),
+		WithMaintenanceExempt("/admin/maintenance"),
+		WithMaintenanceHTML([]byte("<h1>Maintenance</h1>")),
+		WithMaintenanceLogger(slog.New(slog.DiscardHandler)),
+	)
+
+	l, err := NewListenerHTTP(":0", WithHealthCheck(), WithMaintenance(m))
+	td.CmpNoError(t, err)
+
+	l.Mount("/admin/maintenance", m.Handler())
+	l.MountGroup("/items", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+		r.Post("/", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) })
+	})
+
+	f := func(method, target, body string, header http.Header, wantStatus int) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for key, values := range header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		l.router.ServeHTTP(w, r)
+		td.Cmp(t, w.Code, wantStatus, "%s %s", method, target)
+
+		return w
+	}
+
+	f(http.MethodPost, "/items", "", nil, http.StatusCreated)
+
+	f(http.MethodPut, "/admin/maintenance", `{"mode":"read-only"}`, nil, http.StatusOK)
+	td.Cmp(t, m.Mode(), MaintenanceReadOnly)
+
+	f(http.MethodGet, "/items", "", nil, http.StatusOK)
+	w := f(http.MethodPost, "/items", "", nil, http.StatusServiceUnavailable)
+	td.Cmp(t, w.Header().Get("Retry-After"), "120")
+
+	m.Set(MaintenanceOn)
+
+	f(http.MethodGet, "/items", "", nil, http.StatusServiceUnavailable)
+	w = f(http.MethodGet, "/items", "", http.Header{"Accept": {"text/html"}}, http.StatusServiceUnavailable)
+	td.Cmp(t, w.Body.String(), "<h1>Maintenance</h1>")
+
+	f(http.MethodGet, "/health", "", nil, http.StatusOK)
+
+	w = f(http.MethodGet, "/admin/maintenance", "", nil, http.StatusOK)
+	td.Cmp(t, w.Body.String(), "{\"mode\":\"on\"}\n")
+
+	f(http.MethodPost, "/admin/maintenance", `{"mode":"offline"}`, nil, http.StatusBadRequest)
+	f(http.MethodPost, "/admin/maintenance", `{"mode":"off"}`, nil, http.StatusOK)
+	f(http.MethodPost, "/items", "", nil, http.StatusCreated)
+}
+
+func TestMaintenance_ToggleOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMaintenance()
+
+	// Without the signals the mode is not toggled by any of them.
+	m.ToggleOnSignal(ctx, MaintenanceOn)
+	m.ToggleOnSignal(ctx, MaintenanceReadOnly, os.Interrupt)
+
+	p, err := os.FindProcess(os.Getpid())
+	td.CmpNoError(t, err)
+
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("send signal: %v", err)
+	}
+
+	td.Cmp(t, waitMaintenanceMode(m, MaintenanceReadOnly), MaintenanceReadOnly)
+
+	time.Sleep(50 * time.Millisecond)
+	td.Cmp(t, m.Mode(), MaintenanceReadOnly)
+}
+
+func waitMaintenanceMode(m *Maintenance, mode MaintenanceMode) MaintenanceMode {
+	for range 100 {
+		if m.Mode() == mode {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return m.Mode()
+}
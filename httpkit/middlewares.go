@@ -1,9 +1,13 @@
 package httpkit
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/VictoriaMetrics/metrics"
@@ -11,6 +15,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
 )
 
 // Middleware represents a function type that serves as a middleware in an HTTP server.
@@ -18,48 +23,78 @@ import (
 // The middleware function is responsible for intercepting and processing HTTP requests and responses.
 type Middleware = func(next http.Handler) http.Handler
 
-func RecoveryMiddleware() Middleware           { return middleware.Recoverer }
 func RedirectSlashesMiddleware() Middleware    { return middleware.RedirectSlashes }
 func ProfilerMiddleware() http.Handler         { return middleware.Profiler() }
 func CORSMiddleware(o cors.Options) Middleware { return cors.Handler(o) }
 
-// LoggingMiddleware represents logging middleware.
-func LoggingMiddleware(logger *slog.Logger) Middleware {
-	return func(next http.Handler) http.Handler {
-		fn := func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now().UTC()
+// RecoveryOptions represents the options of the RecoveryMiddleware.
+type RecoveryOptions struct {
+	logger *slog.Logger
+}
 
-			var reqErr error
+// RecoveryOption represents a function type that modifies RecoveryOptions.
+type RecoveryOption func(o *RecoveryOptions)
 
-			ctx := ctxkit.SetLogErrHook(r.Context(), func(err error) { reqErr = err })
+// WithRecoveryLogger sets the logger of the recovered panics. Default is slog.Default.
+func WithRecoveryLogger(logger *slog.Logger) RecoveryOption {
+	return func(o *RecoveryOptions) { o.logger = logger }
+}
 
+// RecoveryMiddleware returns the middleware which recovers from panics in the handlers.
+// The panic is logged with the stack trace, the request ID and the route, reported by the
+// errkit.ErrorReporter as the errkit.PanicError, and responded by the ErrorHTTP with 500 status,
+// unless the response has been already written. The http.ErrAbortHandler panics are propagated
+// to abort the response.
+func RecoveryMiddleware(options ...RecoveryOption) Middleware {
+	o := RecoveryOptions{
+		logger: slog.Default(),
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
-			next.ServeHTTP(ww, r.WithContext(ctx))
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
 
-			status := ww.Status()
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
 
-			mwLogger := logger.With(
-				slog.String("method", r.Method),
-				slog.String("status", strconv.Itoa(status)),
-				slog.String("route", r.RequestURI),
-				slog.String("remote", r.RemoteAddr),
-				slog.Duration("duration", time.Since(start)),
-			)
+				err := errkit.NewPanicError(rec)
+				ctx := r.Context()
 
-			if status >= http.StatusInternalServerError {
-				if reqErr != nil {
-					mwLogger.Error(strconv.Itoa(status)+" "+http.StatusText(status),
-						slog.String("error", reqErr.Error()),
-					)
+				var route string
+				if rctx := chi.RouteContext(ctx); rctx != nil {
+					route = rctx.RoutePattern()
+				}
 
+				o.logger.ErrorContext(ctx, "Panic recovered",
+					slog.String("request_id", cmp.Or(ctxkit.GetRequestID(ctx), middleware.GetReqID(ctx))),
+					slog.String("method", r.Method),
+					slog.String("route", route),
+					slog.String("panic", fmt.Sprint(rec)),
+					slog.String("stack", string(err.Stack)),
+				)
+
+				// The connection of the upgraded protocol is hijacked, and the
+				// committed response can not be replaced, so nothing can be written.
+				if HeaderContainsToken(r.Header, "Connection", "upgrade") || ww.Status() != 0 {
+					errkit.Report(err)
 					return
 				}
 
-				mwLogger.Error(strconv.Itoa(status) + " " + http.StatusText(status))
-			} else {
-				mwLogger.Info(strconv.Itoa(status) + " " + http.StatusText(status))
-			}
+				ErrorHTTP(ww, r, err, WithStatus(http.StatusInternalServerError), WithErrorReport())
+			}()
+
+			next.ServeHTTP(ww, r)
 		}
 
 		return http.HandlerFunc(fn)
@@ -67,6 +102,8 @@ func LoggingMiddleware(logger *slog.Logger) Middleware {
 }
 
 // MetricsMiddleware represents HTTP metrics collecting middlewares.
+// The labels added by the ctxkit.MetricLabel, e.g. the API version
+// of the VersionRouter, are appended to the metrics labels.
 func MetricsMiddleware() Middleware {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
@@ -74,13 +111,17 @@ func MetricsMiddleware() Middleware {
 			ctx := chi.RouteContext(r.Context())
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 
-			next.ServeHTTP(ww, r)
+			var labels strings.Builder
+
+			next.ServeHTTP(ww, r.WithContext(ctxkit.SetMetricLabelHook(r.Context(), func(name, value string) {
+				labels.WriteString(ctxkit.FormatMetricLabel(name, value))
+			})))
 
 			status := strconv.Itoa(ww.Status())
 			route := ctx.RoutePattern()
 
-			httpReqDur := httpReqDurationStr(r.Method, route, status)
-			httpReqTotal := httpReqTotalStr(r.Method, route, status)
+			httpReqDur := httpReqDurationStr(r.Method, route, status, labels.String())
+			httpReqTotal := httpReqTotalStr(r.Method, route, status, labels.String())
 
 			metrics.GetOrCreateSummaryExt(httpReqDur, 5*time.Minute, []float64{0.95, 0.99}).
 				UpdateDuration(start)
@@ -93,10 +134,24 @@ func MetricsMiddleware() Middleware {
 	}
 }
 
-func httpReqDurationStr(method, route, status string) string {
-	return `http_request_duration{method="` + method + `", route="` + route + `", code="` + status + `"}`
+// HeaderContainsToken reports whether the comma-separated list header, e.g. Connection: keep-alive, Upgrade,
+// contains the token. The tokens are compared case-insensitively.
+func HeaderContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func httpReqDurationStr(method, route, status, labels string) string {
+	return `http_request_duration{method="` + method + `", route="` + route + `", code="` + status + `"` + labels + `}`
 }
 
-func httpReqTotalStr(method, route, status string) string {
-	return `http_requests_total{method="` + method + `", route="` + route + `", code="` + status + `"}`
+func httpReqTotalStr(method, route, status, labels string) string {
+	return `http_requests_total{method="` + method + `", route="` + route + `", code="` + status + `"` + labels + `}`
 }
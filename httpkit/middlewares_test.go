@@ -1 +1,109 @@
 package httpkit
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	router := chi.NewRouter()
+	router.Use(RecoveryMiddleware(WithRecoveryLogger(slog.New(slog.NewTextHandler(&buf, nil)))))
+	router.Get("/items/{id}", func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+
+	td.Cmp(t, w.Code, http.StatusInternalServerError)
+	td.Cmp(t, buf.String(), td.All(
+		td.Contains(`msg="Panic recovered"`),
+		td.Contains(`route=/items/{id}`),
+		td.Contains(`panic=boom`),
+		td.Contains(`middlewares_test.go`),
+	))
+}
+
+func TestRecoveryMiddleware_abort(t *testing.T) {
+	handler := RecoveryMiddleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	td.CmpPanic(t, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}, http.ErrAbortHandler)
+}
+
+func TestRecoveryMiddleware_committed(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+
+	f := func(name string, header http.Header, handler http.HandlerFunc, wantCode int, wantBody string) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for key, values := range header {
+				r.Header[key] = values
+			}
+
+			w := httptest.NewRecorder()
+			RecoveryMiddleware(WithRecoveryLogger(logger))(handler).ServeHTTP(w, r)
+
+			td.Cmp(t, w.Code, wantCode)
+			td.Cmp(t, w.Body.String(), wantBody)
+		})
+	}
+
+	f("Committed", nil, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("partial"))
+		panic("boom")
+	}, http.StatusAccepted, "partial")
+
+	f("Upgrade", http.Header{"Connection": {"keep-alive, Upgrade"}}, func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}, http.StatusOK, "")
+}
+
+func TestErrorResponderMiddleware(t *testing.T) {
+	teapot := func(w http.ResponseWriter, _ error, _ ...ResponseOption) {
+		http.Error(w, "teapot", http.StatusTeapot)
+	}
+
+	router := chi.NewRouter()
+	router.Get("/default", func(w http.ResponseWriter, r *http.Request) {
+		ErrorHTTP(w, r, errkit.ErrNotFound)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(ErrorResponderMiddleware(teapot))
+		r.Get("/group", func(w http.ResponseWriter, r *http.Request) {
+			ErrorHTTP(w, r, errkit.ErrNotFound)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/default", nil))
+	td.Cmp(t, w.Code, http.StatusNotFound)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/group", nil))
+	td.Cmp(t, w.Code, http.StatusTeapot)
+	td.Cmp(t, w.Body.String(), "teapot\n")
+}
+
+func TestHeaderContainsToken(t *testing.T) {
+	h := http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"h2c", "WebSocket"}}
+
+	td.CmpTrue(t, HeaderContainsToken(h, "Connection", "upgrade"))
+	td.CmpTrue(t, HeaderContainsToken(h, "Upgrade", "websocket"))
+	td.CmpFalse(t, HeaderContainsToken(h, "Connection", "close"))
+	td.CmpFalse(t, HeaderContainsToken(h, "Connection", "keep"))
+	td.CmpFalse(t, HeaderContainsToken(h, "Te", "trailers"))
+}
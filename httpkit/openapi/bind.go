@@ -0,0 +1,284 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/plainq/servekit/errkit"
+)
+
+// Parameter locations.
+const (
+	inPath   = "path"
+	inQuery  = "query"
+	inHeader = "header"
+)
+
+// bodyField represents the name of the request field which receives the decoded JSON body.
+const bodyField = "Body"
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// boundField represents the request field bound to the parameter or the body.
+type boundField struct {
+	field    reflect.StructField
+	name     string
+	in       string
+	required bool
+}
+
+// binder binds the HTTP request to the request struct.
+type binder struct {
+	params []boundField
+	body   *boundField
+}
+
+// newBinder returns the binder of the request struct type.
+func newBinder(t reflect.Type) (*binder, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("openapi: request type %s is not a struct", t)
+	}
+
+	var b binder
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		tagged := false
+
+		for _, in := range []string{inPath, inQuery, inHeader} {
+			tag, ok := f.Tag.Lookup(in)
+			if !ok {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = f.Name
+			}
+
+			if !bindable(f.Type, in == inQuery) {
+				return nil, fmt.Errorf("openapi: %s parameter %q has unsupported type %s", in, name, f.Type)
+			}
+
+			b.params = append(b.params, boundField{
+				field:    f,
+				name:     name,
+				in:       in,
+				required: in == inPath || opts == "required",
+			})
+
+			tagged = true
+		}
+
+		if !tagged && f.Name == bodyField {
+			b.body = &boundField{field: f, in: "body", required: f.Type.Kind() != reflect.Pointer}
+		}
+	}
+
+	return &b, nil
+}
+
+// bind binds the request to the value of the request struct.
+func (b *binder) bind(w http.ResponseWriter, r *http.Request, v reflect.Value, maxBodySize int64) error {
+	var query map[string][]string
+
+	for _, p := range b.params {
+		var values []string
+
+		switch p.in {
+		case inPath:
+			if value := chi.URLParam(r, p.name); value != "" {
+				values = []string{value}
+			}
+
+		case inQuery:
+			if query == nil {
+				query = r.URL.Query()
+			}
+
+			values = query[p.name]
+
+		case inHeader:
+			values = r.Header.Values(p.name)
+		}
+
+		if len(values) == 0 {
+			if p.required {
+				return errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("%s parameter %q is required", p.in, p.name))
+			}
+
+			continue
+		}
+
+		field, ok := fieldByIndex(v, p.field.Index)
+		if !ok {
+			continue
+		}
+
+		if err := setValues(field, values); err != nil {
+			return errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("%s parameter %q: %w", p.in, p.name, err))
+		}
+	}
+
+	if b.body == nil {
+		return nil
+	}
+
+	field, ok := fieldByIndex(v, b.body.field.Index)
+	if !ok {
+		return nil
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(field.Addr().Interface()); err != nil {
+		if errors.Is(err, io.EOF) {
+			if b.body.required {
+				return errors.Join(errkit.ErrInvalidArgument, errors.New("request body is required"))
+			}
+
+			return nil
+		}
+
+		return errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("decode request body: %w", err))
+	}
+
+	return nil
+}
+
+// fieldByIndex returns the nested field by the index, allocating the nil embedded struct pointers
+// on the way. It returns false if the nil pointer can not be allocated, e.g. it is unexported.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// bindable reports whether the parameter of the type can be bound.
+func bindable(t reflect.Type, multiple bool) bool {
+	if multiple && t.Kind() == reflect.Slice {
+		return bindable(t.Elem(), false)
+	}
+
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// setValues sets the parameter values to the field.
+func setValues(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return setValue(v, values[0])
+	}
+
+	slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+
+	for i, value := range values {
+		if err := setValue(slice.Index(i), value); err != nil {
+			return err
+		}
+	}
+
+	v.Set(slice)
+
+	return nil
+}
+
+// setValue parses the value into the scalar field.
+func setValue(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+
+		v.Set(ptr)
+
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+
+		v.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
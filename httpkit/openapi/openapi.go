@@ -0,0 +1,466 @@
+// Package openapi implements the typed handlers, which bind requests into Go structs and respond
+// with Go values, and reflects the registered routes into the OpenAPI 3.1 document, so the spec
+// can not drift from the routes.
+//
+// The request type is a struct, which fields are bound by the tags:
+//   - path:"name" binds the path parameter of the chi route pattern, it is always required;
+//   - query:"name" binds the query parameter, use slices for repeated parameters;
+//   - header:"Name" binds the request header;
+//   - the field named Body receives the decoded JSON body, it is optional if it is a pointer.
+//
+// Add ",required" to the query and header tags to make the parameter required. The doc tag sets
+// the description and the enum tag sets the comma-separated allowed values of the schema.
+// If the request implements the Validate() error method, it is called after the binding.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+// Version represents the version of the OpenAPI specification of the document.
+const Version = "3.1.0"
+
+var (
+	//go:embed docs.html
+	assets   embed.FS
+	docsPage = template.Must(template.ParseFS(assets, "docs.html"))
+
+	// routeRegexp matches the regular expressions of the chi route parameters.
+	routeRegexp = regexp.MustCompile(`\{([^}:]+):[^}]*}`)
+)
+
+// Document represents the OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components *Components                      `json:"components,omitempty"`
+}
+
+// Info represents the metadata of the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server represents the server of the API.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components represents the reusable schemas of the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Operation represents the API operation on the path.
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter represents the parameter of the operation.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody represents the request body of the operation.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response represents the response of the operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType represents the schema of the content.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Options represents the options of the API.
+type Options struct {
+	info        Info
+	servers     []Server
+	specRoute   string
+	docsRoute   string
+	maxBodySize int64
+}
+
+// Option represents a function type that modifies Options.
+type Option func(o *Options)
+
+// WithInfo sets the title, version and description of the API. Default title is "API" and version is "0.0.0".
+func WithInfo(title, version, description string) Option {
+	return func(o *Options) { o.info = Info{Title: title, Version: version, Description: description} }
+}
+
+// WithServers sets the URLs of the servers of the API, e.g. the path the API is mounted on.
+func WithServers(urls ...string) Option {
+	return func(o *Options) {
+		for _, url := range urls {
+			o.servers = append(o.servers, Server{URL: url})
+		}
+	}
+}
+
+// WithSpecRoute sets the route of the OpenAPI document. Default is "/openapi.json".
+// Empty route disables the document endpoint.
+func WithSpecRoute(route string) Option {
+	return func(o *Options) { o.specRoute = route }
+}
+
+// WithDocs enables the API reference UI on the given route, e.g. "/docs".
+// The UI is loaded from the CDN, and its script has the CSP nonce of the request.
+func WithDocs(route string) Option {
+	return func(o *Options) { o.docsRoute = route }
+}
+
+// WithMaxBodySize sets the max size of the request body. Default is 1 MiB.
+func WithMaxBodySize(size int64) Option {
+	return func(o *Options) { o.maxBodySize = size }
+}
+
+// API represents the set of typed routes described by the OpenAPI document.
+// It implements the http.Handler, so it can be mounted on the httpkit.ListenerHTTP.
+type API struct {
+	opts   Options
+	router chi.Router
+
+	// docsOnce guards the registration of the document routes, which is deferred
+	// to the first route, because chi does not allow middlewares after routes.
+	docsOnce sync.Once
+
+	mu         sync.RWMutex
+	operations []operation
+}
+
+// operation represents the registered operation.
+type operation struct {
+	method   string
+	pattern  string
+	spec     Operation
+	status   int
+	request  *binder
+	response reflect.Type
+}
+
+// New returns a pointer to a new instance of API.
+func New(options ...Option) *API {
+	o := Options{
+		info:        Info{Title: "API", Version: "0.0.0"},
+		specRoute:   "/openapi.json",
+		maxBodySize: 1 << 20,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	a := API{
+		opts:   o,
+		router: chi.NewRouter(),
+	}
+
+	return &a
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.docsOnce.Do(a.registerDocs)
+	a.router.ServeHTTP(w, r)
+}
+
+// Use appends the middlewares to the API routes. It must be called before the routes are registered.
+func (a *API) Use(middlewares ...httpkit.Middleware) {
+	a.router.Use(middlewares...)
+}
+
+// Document returns the OpenAPI document of the registered routes.
+func (a *API) Document() *Document {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	registry := newSchemaRegistry()
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    a.opts.info,
+		Servers: a.opts.servers,
+		Paths:   make(map[string]map[string]*Operation),
+	}
+
+	for _, op := range a.operations {
+		spec := op.spec
+		spec.Responses = make(map[string]*Response)
+		spec.Parameters = nil
+
+		for _, p := range op.request.params {
+			spec.Parameters = append(spec.Parameters, &Parameter{
+				Name:        p.name,
+				In:          p.in,
+				Description: p.field.Tag.Get("doc"),
+				Required:    p.required,
+				Schema:      registry.schema(p.field.Type),
+			})
+		}
+
+		if b := op.request.body; b != nil {
+			spec.RequestBody = &RequestBody{
+				Required: b.required,
+				Content:  map[string]MediaType{"application/json": {Schema: registry.schema(b.field.Type)}},
+			}
+		}
+
+		response := Response{Description: http.StatusText(op.status)}
+		if !noContent(op.response) {
+			response.Content = map[string]MediaType{"application/json": {Schema: registry.schema(op.response)}}
+		}
+
+		spec.Responses[strconv.Itoa(op.status)] = &response
+		spec.Responses["default"] = &Response{Description: "Error"}
+
+		path := routeRegexp.ReplaceAllString(op.pattern, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*Operation)
+		}
+
+		doc.Paths[path][strings.ToLower(op.method)] = &spec
+	}
+
+	if len(registry.schemas) > 0 {
+		doc.Components = &Components{Schemas: registry.schemas}
+	}
+
+	return &doc
+}
+
+// registerDocs registers the document and the docs UI routes.
+func (a *API) registerDocs() {
+	if a.opts.specRoute != "" {
+		a.router.Get(a.opts.specRoute, func(w http.ResponseWriter, r *http.Request) {
+			httpkit.JSON(w, r, a.Document())
+		})
+	}
+
+	if a.opts.docsRoute != "" {
+		a.router.Get(a.opts.docsRoute, a.docsHandler)
+	}
+}
+
+// docsHandler renders the API reference UI.
+func (a *API) docsHandler(w http.ResponseWriter, r *http.Request) {
+	// The spec URL is relative to the docs route, so the API can be mounted on any path.
+	depth := strings.Count(strings.Trim(a.opts.docsRoute, "/"), "/")
+	specURL := strings.Repeat("../", depth) + strings.TrimPrefix(a.opts.specRoute, "/")
+
+	var buf bytes.Buffer
+
+	if err := docsPage.Execute(&buf, map[string]string{
+		"Title":   a.opts.info.Title,
+		"SpecURL": specURL,
+		"Nonce":   httpkit.CSPNonce(r.Context()),
+	}); err != nil {
+		httpkit.ErrorHTTP(w, r, err)
+		return
+	}
+
+	httpkit.HTML(w, r, buf.Bytes())
+}
+
+// HandlerFunc represents the typed handler of the request.
+type HandlerFunc[Req, Resp any] func(ctx context.Context, req Req) (Resp, error)
+
+// OperationOptions represents the options of the operation.
+type OperationOptions struct {
+	spec        Operation
+	status      int
+	middlewares []httpkit.Middleware
+}
+
+// OperationOption represents a function type that modifies OperationOptions.
+type OperationOption func(o *OperationOptions)
+
+// WithSummary sets the summary of the operation.
+func WithSummary(summary string) OperationOption {
+	return func(o *OperationOptions) { o.spec.Summary = summary }
+}
+
+// WithDescription sets the description of the operation.
+func WithDescription(description string) OperationOption {
+	return func(o *OperationOptions) { o.spec.Description = description }
+}
+
+// WithTags sets the tags of the operation.
+func WithTags(tags ...string) OperationOption {
+	return func(o *OperationOptions) { o.spec.Tags = append(o.spec.Tags, tags...) }
+}
+
+// WithOperationID sets the operation ID. Default is built of the method and the path,
+// e.g. "getItemsId" for the GET /items/{id}.
+func WithOperationID(id string) OperationOption {
+	return func(o *OperationOptions) { o.spec.OperationID = id }
+}
+
+// WithDeprecated marks the operation as deprecated.
+func WithDeprecated() OperationOption {
+	return func(o *OperationOptions) { o.spec.Deprecated = true }
+}
+
+// WithStatus sets the status of the successful response.
+// Default is 200, or 204 if the response type is the empty struct.
+func WithStatus(code int) OperationOption {
+	return func(o *OperationOptions) { o.status = code }
+}
+
+// WithMiddlewares sets the middlewares of the route, e.g. the authorization.
+func WithMiddlewares(middlewares ...httpkit.Middleware) OperationOption {
+	return func(o *OperationOptions) { o.middlewares = append(o.middlewares, middlewares...) }
+}
+
+// validator represents the request which validates itself after the binding.
+type validator interface {
+	Validate() error
+}
+
+// Handle registers the typed handler on the method and the chi route pattern, and adds the operation
+// to the document. The request is bound into the Req, and the Resp is written by the httpkit.JSON.
+// Errors of the binding and the handler are written by the httpkit.ErrorHTTP. It panics if the Req
+// is not a struct or has the parameter of an unsupported type, like chi does on invalid patterns.
+func Handle[Req, Resp any](a *API, method, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	request, err := newBinder(reflect.TypeFor[Req]())
+	if err != nil {
+		panic(err)
+	}
+
+	response := reflect.TypeFor[Resp]()
+
+	o := OperationOptions{
+		spec:   Operation{OperationID: operationID(method, pattern)},
+		status: http.StatusOK,
+	}
+
+	if noContent(response) {
+		o.status = http.StatusNoContent
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	a.docsOnce.Do(a.registerDocs)
+
+	a.mu.Lock()
+	a.operations = append(a.operations, operation{
+		method:   method,
+		pattern:  pattern,
+		spec:     o.spec,
+		status:   o.status,
+		request:  request,
+		response: response,
+	})
+	a.mu.Unlock()
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var req Req
+
+		if err := request.bind(w, r, reflect.ValueOf(&req).Elem(), a.opts.maxBodySize); err != nil {
+			httpkit.ErrorHTTP(w, r, err)
+			return
+		}
+
+		if v, ok := any(&req).(validator); ok {
+			if err := v.Validate(); err != nil {
+				httpkit.ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, err))
+				return
+			}
+		}
+
+		resp, err := handler(r.Context(), req)
+		if err != nil {
+			httpkit.ErrorHTTP(w, r, err)
+			return
+		}
+
+		if noContent(response) {
+			httpkit.Status(w, r, o.status)
+			return
+		}
+
+		httpkit.JSON(w, r, resp, httpkit.WithStatus(o.status))
+	}
+
+	a.router.With(o.middlewares...).Method(method, pattern, http.HandlerFunc(fn))
+}
+
+// Get registers the typed handler of the GET method, see Handle.
+func Get[Req, Resp any](a *API, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	Handle(a, http.MethodGet, pattern, handler, options...)
+}
+
+// Post registers the typed handler of the POST method, see Handle.
+func Post[Req, Resp any](a *API, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	Handle(a, http.MethodPost, pattern, handler, options...)
+}
+
+// Put registers the typed handler of the PUT method, see Handle.
+func Put[Req, Resp any](a *API, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	Handle(a, http.MethodPut, pattern, handler, options...)
+}
+
+// Patch registers the typed handler of the PATCH method, see Handle.
+func Patch[Req, Resp any](a *API, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	Handle(a, http.MethodPatch, pattern, handler, options...)
+}
+
+// Delete registers the typed handler of the DELETE method, see Handle.
+func Delete[Req, Resp any](a *API, pattern string, handler HandlerFunc[Req, Resp], options ...OperationOption) {
+	Handle(a, http.MethodDelete, pattern, handler, options...)
+}
+
+// noContent reports whether the response type is the empty struct.
+func noContent(t reflect.Type) bool { return t.Kind() == reflect.Struct && t.NumField() == 0 }
+
+// operationID returns the operation ID built of the method and the path.
+func operationID(method, pattern string) string {
+	pattern = routeRegexp.ReplaceAllString(pattern, "{$1}")
+
+	id := strings.ToLower(method)
+
+	for _, segment := range strings.FieldsFunc(pattern, func(r rune) bool {
+		return slices.Contains([]rune("/{}-_.*"), r)
+	}) {
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+
+	return id
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+)
+
+type item struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name" doc:"Name of the item."`
+	Status    string    `json:"status" enum:"active,archived"`
+	Tags      []string  `json:"tags,omitempty"`
+	Parent    *item     `json:"parent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type getItemRequest struct {
+	ID     string   `path:"id"`
+	Fields []string `query:"fields"`
+	Trace  string   `header:"X-Trace,required"`
+}
+
+type createItemRequest struct {
+	Body struct {
+		Name string `json:"name"`
+	}
+}
+
+func (r *createItemRequest) Validate() error {
+	if r.Body.Name == "" {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+func newTestAPI() *API {
+	api := New(WithInfo("Items", "1.0.0", ""), WithServers("/api"), WithDocs("/docs"))
+
+	Get(api, "/items/{id:[A-Z0-9]+}", func(_ context.Context, req getItemRequest) (item, error) {
+		if req.ID == "MISSING" {
+			return item{}, errkit.ErrNotFound
+		}
+
+		return item{ID: req.ID, Name: strings.Join(req.Fields, ","), Status: req.Trace}, nil
+	}, WithTags("items"), WithSummary("Get the item."))
+
+	Post(api, "/items", func(_ context.Context, req createItemRequest) (item, error) {
+		return item{ID: "NEW", Name: req.Body.Name}, nil
+	}, WithStatus(http.StatusCreated))
+
+	Delete(api, "/items/{id}", func(context.Context, struct {
+		ID string `path:"id"`
+	}) (struct{}, error) {
+		return struct{}{}, nil
+	})
+
+	return api
+}
+
+func TestHandle(t *testing.T) {
+	api := newTestAPI()
+
+	f := func(method, target, body string, header http.Header, wantStatus int, wantBody any) {
+		t.Helper()
+
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for key, values := range header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, wantStatus, method+" "+target)
+
+		if wantBody != nil {
+			td.Cmp(t, json.RawMessage(w.Body.Bytes()), td.JSONPointer("", wantBody), method+" "+target)
+		}
+	}
+
+	trace := http.Header{"X-Trace": {"t1"}}
+
+	f(http.MethodGet, "/items/A1?fields=a&fields=b", "", trace, http.StatusOK,
+		td.SuperMapOf(map[string]any{"id": "A1", "name": "a,b", "status": "t1"}, nil))
+	f(http.MethodGet, "/items/A1", "", nil, http.StatusBadRequest, nil)
+	f(http.MethodGet, "/items/MISSING", "", trace, http.StatusNotFound, nil)
+	f(http.MethodPost, "/items", `{"name":"box"}`, nil, http.StatusCreated,
+		td.SuperMapOf(map[string]any{"id": "NEW", "name": "box"}, nil))
+	f(http.MethodPost, "/items", `{"name":""}`, nil, http.StatusBadRequest, nil)
+	f(http.MethodPost, "/items", ``, nil, http.StatusBadRequest, nil)
+	f(http.MethodPost, "/items", `{`, nil, http.StatusBadRequest, nil)
+	f(http.MethodDelete, "/items/A1", "", nil, http.StatusNoContent, nil)
+}
+
+func TestAPI_Document(t *testing.T) {
+	api := newTestAPI()
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, json.RawMessage(w.Body.Bytes()), td.All(
+		td.JSONPointer("/openapi", "3.1.0"),
+		td.JSONPointer("/info", map[string]any{"title": "Items", "version": "1.0.0"}),
+		td.JSONPointer("/servers/0/url", "/api"),
+		td.JSONPointer("/paths/~1items~1{id}/get", td.SuperMapOf(map[string]any{
+			"operationId": "getItemsId",
+			"summary":     "Get the item.",
+			"tags":        []any{"items"},
+			"parameters": []any{
+				map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "string"}},
+				map[string]any{"name": "fields", "in": "query", "schema": map[string]any{
+					"type": "array", "items": map[string]any{"type": "string"},
+				}},
+				map[string]any{"name": "X-Trace", "in": "header", "required": true, "schema": map[string]any{"type": "string"}},
+			},
+		}, nil)),
+		td.JSONPointer("/paths/~1items~1{id}/get/responses/200/content/application~1json/schema/$ref",
+			"#/components/schemas/item"),
+		td.JSONPointer("/paths/~1items~1{id}/delete/responses", td.ContainsKey("204")),
+		td.JSONPointer("/paths/~1items/post/requestBody/required", true),
+		td.JSONPointer("/paths/~1items/post/responses", td.ContainsKey("201")),
+		td.JSONPointer("/components/schemas/item", map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id":         map[string]any{"type": "string"},
+				"name":       map[string]any{"type": "string", "description": "Name of the item."},
+				"status":     map[string]any{"type": "string", "enum": []any{"active", "archived"}},
+				"tags":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"parent":     map[string]any{"$ref": "#/components/schemas/item"},
+				"created_at": map[string]any{"type": "string", "format": "date-time"},
+			},
+			"required": []any{"id", "name", "status", "created_at"},
+		}),
+	))
+
+	w = httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Body.String(), td.Contains(`data-url="openapi.json"`))
+}
+
+func TestHandle_invalidRequest(t *testing.T) {
+	td.CmpPanic(t, func() {
+		Get(New(), "/", func(context.Context, string) (struct{}, error) { return struct{}{}, nil })
+	}, td.Isa((*error)(nil)))
+
+	td.CmpPanic(t, func() {
+		Get(New(), "/", func(context.Context, struct {
+			Filter map[string]string `query:"filter"`
+		}) (struct{}, error) {
+			return struct{}{}, nil
+		})
+	}, td.Isa((*error)(nil)))
+}
+
+type Paging struct {
+	Limit int `query:"limit"`
+}
+
+type paging struct {
+	Cursor string `query:"cursor"`
+}
+
+type listItemsRequest struct {
+	*Paging
+	*paging
+}
+
+func TestBinder_embeddedPointer(t *testing.T) {
+	b, err := newBinder(reflect.TypeFor[listItemsRequest]())
+	td.Require(t).CmpNoError(err)
+
+	var req listItemsRequest
+
+	r := httptest.NewRequest(http.MethodGet, "/items?limit=10&cursor=abc", http.NoBody)
+	td.CmpNoError(t, b.bind(httptest.NewRecorder(), r, reflect.ValueOf(&req).Elem(), 1<<10))
+
+	// The exported pointer is allocated, while the unexported one can not be set.
+	td.Cmp(t, req.Paging, &Paging{Limit: 10})
+	td.CmpNil(t, req.paging)
+}
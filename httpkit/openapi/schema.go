@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// Schema represents the JSON Schema of the OpenAPI 3.1 document.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// schemaRegistry reflects Go types into schemas. Named struct types
+// are placed into the components and referenced by the $ref.
+type schemaRegistry struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// newSchemaRegistry returns a pointer to a new instance of schemaRegistry.
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schema returns the schema of the type.
+//
+//nolint:revive // cyclomatic is acceptable here.
+func (s *schemaRegistry) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(float64)}
+
+	case reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+
+		name, ok := s.names[t]
+		if !ok {
+			name = s.uniqueName(t)
+			s.names[t] = name
+
+			// Register the placeholder first to stop the recursion of self-referencing types.
+			s.schemas[name] = &Schema{}
+			*s.schemas[name] = *s.object(t)
+		}
+
+		return &Schema{Ref: "#/components/schemas/" + name}
+
+	default:
+		return &Schema{}
+	}
+}
+
+// object returns the schema of the struct type by the encoding/json rules.
+func (s *schemaRegistry) object(t reflect.Type) *Schema {
+	schema := Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for _, f := range reflect.VisibleFields(t) {
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// The fields of the embedded struct are promoted.
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		property := s.schema(f.Type)
+
+		if doc := f.Tag.Get("doc"); doc != "" {
+			property.Description = doc
+		}
+
+		if enum := f.Tag.Get("enum"); enum != "" {
+			property.Enum = strings.Split(enum, ",")
+		}
+
+		schema.Properties[name] = property
+
+		omit := strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero")
+		if !omit && f.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return &schema
+}
+
+// uniqueName returns the component name of the type which is unique within the registry.
+func (s *schemaRegistry) uniqueName(t reflect.Type) string {
+	name := t.Name()
+
+	// Shorten the type arguments of generic types,
+	// e.g. Page[github.com/org/pkg.Item] to Page_Item.
+	if base, args, ok := strings.Cut(name, "["); ok {
+		parts := strings.Split(strings.TrimSuffix(args, "]"), ",")
+
+		for i, part := range parts {
+			parts[i] = part[strings.LastIndexAny(part, "./")+1:]
+		}
+
+		name = base + "_" + strings.Join(parts, "_")
+	}
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+
+		default:
+			return '_'
+		}
+	}, name)
+
+	unique := name
+
+	for i := 2; ; i++ {
+		if _, ok := s.schemas[unique]; !ok {
+			return unique
+		}
+
+		unique = name + strconv.Itoa(i)
+	}
+}
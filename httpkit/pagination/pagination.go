@@ -0,0 +1,279 @@
+// Package pagination implements the cursor-based (keyset) pagination for list endpoints.
+// The opaque cursor carries the sort keys of the page boundary row, so the next page is
+// selected by the keys instead of the offset, which is stable under concurrent inserts and
+// does not degrade on deep pages. It works naturally with the idkit ULIDs, which are sortable.
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+var (
+	// ErrCursorInvalid indicates that the cursor is malformed or its signature does not match.
+	ErrCursorInvalid = errors.Join(errkit.ErrInvalidArgument, errors.New("pagination: invalid cursor"))
+
+	// ErrLimitInvalid indicates that the page size is not a positive integer.
+	ErrLimitInvalid = errors.Join(errkit.ErrInvalidArgument, errors.New("pagination: invalid limit"))
+)
+
+// Cursor represents the position in the sorted list.
+type Cursor struct {
+	// Keys represents the sort keys of the boundary row, in the order of the sort columns.
+	Keys []string `json:"k"`
+
+	// Backward reports whether the page precedes the boundary row.
+	Backward bool `json:"b,omitempty"`
+}
+
+// Options represents the options of the Paginator.
+type Options struct {
+	key          []byte
+	defaultLimit int
+	maxLimit     int
+	limitParam   string
+	cursorParam  string
+}
+
+// Option represents a function type that modifies Options.
+type Option func(o *Options)
+
+// WithSigningKey sets the key to sign the cursors with HMAC-SHA256, so clients can not forge them.
+// Cursors are not signed by default.
+func WithSigningKey(key []byte) Option {
+	return func(o *Options) { o.key = key }
+}
+
+// WithLimits sets the default and the max page size. Default is 20 and 100.
+func WithLimits(defaultLimit, maxLimit int) Option {
+	return func(o *Options) {
+		o.defaultLimit = defaultLimit
+		o.maxLimit = max(defaultLimit, maxLimit)
+	}
+}
+
+// WithParams sets the names of the page size and the cursor query parameters.
+// Default is "limit" and "cursor".
+func WithParams(limit, cursor string) Option {
+	return func(o *Options) {
+		o.limitParam = limit
+		o.cursorParam = cursor
+	}
+}
+
+// Paginator parses the pagination parameters of requests and builds the pages.
+type Paginator struct {
+	opts Options
+}
+
+// New returns a pointer to a new instance of Paginator.
+func New(options ...Option) *Paginator {
+	o := Options{
+		defaultLimit: 20,
+		maxLimit:     100,
+		limitParam:   "limit",
+		cursorParam:  "cursor",
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &Paginator{opts: o}
+}
+
+// Params represents the pagination parameters of the request.
+type Params struct {
+	// Limit represents the page size.
+	Limit int
+
+	// Cursor represents the position of the page, nil for the first page.
+	Cursor *Cursor
+}
+
+// Parse returns the pagination parameters from the query of the request. The page size is
+// capped by the max limit. Returns the ErrLimitInvalid or the ErrCursorInvalid, which are
+// responded with 400 by the httpkit.ErrorHTTP.
+func (p *Paginator) Parse(r *http.Request) (Params, error) {
+	query := r.URL.Query()
+	params := Params{Limit: p.opts.defaultLimit}
+
+	if value := query.Get(p.opts.limitParam); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return Params{}, ErrLimitInvalid
+		}
+
+		params.Limit = min(limit, p.opts.maxLimit)
+	}
+
+	if value := query.Get(p.opts.cursorParam); value != "" {
+		cursor, err := p.Decode(value)
+		if err != nil {
+			return Params{}, err
+		}
+
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+// Encode returns the opaque token of the cursor.
+func (p *Paginator) Encode(c Cursor) string {
+	// Marshaling of the strings and bools never fails.
+	payload, _ := json.Marshal(c)
+
+	token := base64.RawURLEncoding.EncodeToString(payload)
+	if len(p.opts.key) == 0 {
+		return token
+	}
+
+	return token + "." + base64.RawURLEncoding.EncodeToString(p.sign(payload))
+}
+
+// Decode returns the cursor of the opaque token.
+func (p *Paginator) Decode(token string) (Cursor, error) {
+	encoded, signature, signed := strings.Cut(token, ".")
+	if signed != (len(p.opts.key) > 0) {
+		return Cursor{}, ErrCursorInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrCursorInvalid
+	}
+
+	if signed {
+		mac, err := base64.RawURLEncoding.DecodeString(signature)
+		if err != nil || !hmac.Equal(mac, p.sign(payload)) {
+			return Cursor{}, ErrCursorInvalid
+		}
+	}
+
+	var c Cursor
+
+	if err := json.Unmarshal(payload, &c); err != nil || len(c.Keys) == 0 {
+		return Cursor{}, ErrCursorInvalid
+	}
+
+	return c, nil
+}
+
+// sign returns the HMAC-SHA256 of the payload.
+func (p *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, p.opts.key)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// Page represents the response envelope of the list endpoint.
+type Page[T any] struct {
+	// Items represents the items of the page.
+	Items []T `json:"items"`
+
+	// NextCursor represents the cursor of the next page, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+
+	// PrevCursor represents the cursor of the previous page, empty on the first page.
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	// Next represents the URI of the next page.
+	Next string `json:"next,omitempty"`
+
+	// Prev represents the URI of the previous page.
+	Prev string `json:"prev,omitempty"`
+}
+
+// NewPage returns the page of the rows, which must be selected by the Params.Keyset query,
+// so there is one extra row to detect whether there are more rows. The keys function returns
+// the sort keys of the row in the order of the sort columns.
+func NewPage[T any](p *Paginator, r *http.Request, params Params, rows []T, keys func(T) []string) Page[T] {
+	more := len(rows) > params.Limit
+	if more {
+		rows = rows[:params.Limit]
+	}
+
+	backward := params.Cursor != nil && params.Cursor.Backward
+	if backward {
+		// The rows of the previous page are selected in the reverse order.
+		rows = slices.Clone(rows)
+		slices.Reverse(rows)
+	}
+
+	page := Page[T]{Items: rows}
+
+	if len(rows) == 0 {
+		// Let clients go back from the empty page past the end.
+		if params.Cursor != nil && !backward {
+			page.PrevCursor = p.Encode(Cursor{Keys: params.Cursor.Keys, Backward: true})
+		}
+	} else {
+		if (backward && params.Cursor != nil) || (!backward && more) {
+			page.NextCursor = p.Encode(Cursor{Keys: keys(rows[len(rows)-1])})
+		}
+
+		if (!backward && params.Cursor != nil) || (backward && more) {
+			page.PrevCursor = p.Encode(Cursor{Keys: keys(rows[0]), Backward: true})
+		}
+	}
+
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	page.Next = p.link(r, params, page.NextCursor)
+	page.Prev = p.link(r, params, page.PrevCursor)
+
+	return page
+}
+
+// link returns the URI of the request with the given cursor.
+func (p *Paginator) link(r *http.Request, params Params, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+
+	u := *r.URL
+	query := u.Query()
+	query.Set(p.opts.cursorParam, cursor)
+	query.Set(p.opts.limitParam, strconv.Itoa(params.Limit))
+	u.RawQuery = query.Encode()
+
+	return u.RequestURI()
+}
+
+// LinkHeader returns the value of the Link header with the next and the prev relations.
+func (pg *Page[T]) LinkHeader() string {
+	var links []string
+
+	if pg.Next != "" {
+		links = append(links, `<`+pg.Next+`>; rel="next"`)
+	}
+
+	if pg.Prev != "" {
+		links = append(links, `<`+pg.Prev+`>; rel="prev"`)
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// JSON writes the page as the JSON response with the Link header.
+func (pg *Page[T]) JSON(w http.ResponseWriter, r *http.Request, options ...httpkit.ResponseOption) {
+	if link := pg.LinkHeader(); link != "" {
+		w.Header().Set("Link", link)
+	}
+
+	httpkit.JSON(w, r, pg, options...)
+}
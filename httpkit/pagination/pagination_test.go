@@ -0,0 +1,173 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/dbkit/litekit"
+)
+
+func TestPaginator_Decode(t *testing.T) {
+	signed := New(WithSigningKey([]byte("secret")))
+	unsigned := New()
+
+	cursor := Cursor{Keys: []string{"1700000000", "01HZX"}, Backward: true}
+
+	got, err := signed.Decode(signed.Encode(cursor))
+	td.CmpNoError(t, err)
+	td.Cmp(t, got, cursor)
+
+	got, err = unsigned.Decode(unsigned.Encode(cursor))
+	td.CmpNoError(t, err)
+	td.Cmp(t, got, cursor)
+
+	_, err = signed.Decode(unsigned.Encode(cursor))
+	td.CmpErrorIs(t, err, ErrCursorInvalid, "unsigned cursor")
+
+	_, err = New(WithSigningKey([]byte("other"))).Decode(signed.Encode(cursor))
+	td.CmpErrorIs(t, err, ErrCursorInvalid, "forged cursor")
+
+	_, err = unsigned.Decode("!!!")
+	td.CmpErrorIs(t, err, ErrCursorInvalid, "malformed cursor")
+}
+
+func TestPaginator_Parse(t *testing.T) {
+	p := New(WithLimits(10, 50))
+
+	f := func(query string, want Params, wantErr error) {
+		t.Helper()
+
+		got, err := p.Parse(httptest.NewRequest(http.MethodGet, "/items?"+query, nil))
+		td.CmpErrorIs(t, err, wantErr, query)
+		td.Cmp(t, got, want, query)
+	}
+
+	f("", Params{Limit: 10}, nil)
+	f("limit=30", Params{Limit: 30}, nil)
+	f("limit=500", Params{Limit: 50}, nil)
+	f("limit=0", Params{}, ErrLimitInvalid)
+	f("limit=abc", Params{}, ErrLimitInvalid)
+	f("cursor=abc", Params{}, ErrCursorInvalid)
+	f("cursor="+p.Encode(Cursor{Keys: []string{"a"}}), Params{Limit: 10, Cursor: &Cursor{Keys: []string{"a"}}}, nil)
+}
+
+func TestParams_Keyset(t *testing.T) {
+	ks, err := Params{Limit: 10}.Keyset(Postgres, Desc, 1, "created_at", "id")
+	td.CmpNoError(t, err)
+	td.Cmp(t, ks, Keyset{Where: "1=1", OrderBy: "created_at DESC, id DESC", Limit: 11})
+
+	ks, err = Params{Limit: 10, Cursor: &Cursor{Keys: []string{"1", "a"}}}.Keyset(Postgres, Desc, 1, "created_at", "id")
+	td.CmpNoError(t, err)
+	td.Cmp(t, ks, Keyset{
+		Where:   "(created_at, id) < ($2, $3)",
+		OrderBy: "created_at DESC, id DESC",
+		Limit:   11,
+		Args:    []any{"1", "a"},
+	})
+
+	ks, err = Params{Limit: 10, Cursor: &Cursor{Keys: []string{"a"}, Backward: true}}.Keyset(SQLite, Asc, 0, "id")
+	td.CmpNoError(t, err)
+	td.Cmp(t, ks, Keyset{Where: "(id) < (?)", OrderBy: "id DESC", Limit: 11, Args: []any{"a"}})
+
+	_, err = Params{Limit: 10, Cursor: &Cursor{Keys: []string{"a"}}}.Keyset(SQLite, Asc, 0, "created_at", "id")
+	td.CmpErrorIs(t, err, ErrCursorInvalid)
+}
+
+func TestNewPage_sqlite(t *testing.T) {
+	conn, err := litekit.New(filepath.Join(t.TempDir(), "test.db"))
+	td.Require(t).CmpNoError(err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = conn.ExecContext(t.Context(), `CREATE TABLE items (id TEXT PRIMARY KEY, created_at INTEGER NOT NULL)`)
+	td.Require(t).CmpNoError(err)
+
+	// Items 1 and 2 share the same created_at to check the tie-breaking by the id.
+	for i := 1; i <= 7; i++ {
+		_, err = conn.ExecContext(t.Context(), `INSERT INTO items (id, created_at) VALUES (?, ?)`,
+			fmt.Sprintf("ID%02d", i), 100*max(2, i))
+		td.Require(t).CmpNoError(err)
+	}
+
+	type item struct {
+		ID        string
+		CreatedAt int64
+	}
+
+	p := New(WithSigningKey([]byte("secret")))
+
+	list := func(uri string) Page[item] {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, uri, nil)
+
+		params, err := p.Parse(r)
+		td.Require(t).CmpNoError(err)
+
+		ks, err := params.Keyset(SQLite, Desc, 0, "created_at", "id")
+		td.Require(t).CmpNoError(err)
+
+		rows, err := conn.QueryContext(t.Context(),
+			`SELECT id, created_at FROM items WHERE `+ks.Where+` ORDER BY `+ks.OrderBy+` LIMIT `+strconv.Itoa(ks.Limit),
+			ks.Args...,
+		)
+		td.Require(t).CmpNoError(err)
+
+		defer func() { _ = rows.Close() }()
+
+		var items []item
+
+		for rows.Next() {
+			var it item
+
+			td.Require(t).CmpNoError(rows.Scan(&it.ID, &it.CreatedAt))
+
+			items = append(items, it)
+		}
+
+		td.Require(t).CmpNoError(rows.Err())
+
+		return NewPage(p, r, params, items, func(it item) []string {
+			return []string{strconv.FormatInt(it.CreatedAt, 10), it.ID}
+		})
+	}
+
+	ids := func(page Page[item]) []string {
+		out := make([]string, 0, len(page.Items))
+		for _, it := range page.Items {
+			out = append(out, it.ID)
+		}
+
+		return out
+	}
+
+	first := list("/items?limit=3")
+	td.Cmp(t, ids(first), []string{"ID07", "ID06", "ID05"})
+	td.Cmp(t, first.Prev, "")
+	td.Cmp(t, first.LinkHeader(), `<`+first.Next+`>; rel="next"`)
+
+	second := list(first.Next)
+	td.Cmp(t, ids(second), []string{"ID04", "ID03", "ID02"})
+
+	last := list(second.Next)
+	td.Cmp(t, ids(last), []string{"ID01"})
+	td.Cmp(t, last.Next, "")
+
+	back := list(last.Prev)
+	td.Cmp(t, ids(back), []string{"ID04", "ID03", "ID02"})
+
+	back = list(back.Prev)
+	td.Cmp(t, ids(back), []string{"ID07", "ID06", "ID05"})
+	td.Cmp(t, back.Prev, "")
+	td.CmpNot(t, back.Next, "")
+
+	u, err := url.Parse(back.Next)
+	td.Require(t).CmpNoError(err)
+	td.Cmp(t, u.Query().Get("limit"), "3")
+}
@@ -0,0 +1,103 @@
+package pagination
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect represents the SQL dialect of the keyset query.
+type Dialect uint8
+
+const (
+	// SQLite represents the SQLite dialect with the "?" placeholders, see litekit.
+	SQLite Dialect = iota
+
+	// Postgres represents the PostgreSQL dialect with the "$n" placeholders, see pgkit.
+	Postgres
+)
+
+// Order represents the sort order of the list.
+type Order uint8
+
+const (
+	// Asc represents the ascending order.
+	Asc Order = iota
+
+	// Desc represents the descending order.
+	Desc
+)
+
+// Keyset represents the SQL fragments of the keyset pagination query.
+type Keyset struct {
+	// Where represents the condition which selects the rows after the cursor, or "1=1" for the first page.
+	Where string
+
+	// OrderBy represents the ordering of the rows, without the ORDER BY keywords.
+	OrderBy string
+
+	// Limit represents the number of rows to select, which is one more
+	// than the page size to detect whether there are more rows.
+	Limit int
+
+	// Args represents the arguments of the Where placeholders.
+	Args []any
+}
+
+// Keyset returns the SQL fragments of the keyset query for the given sort columns, which must
+// make the order unique, e.g. "created_at", "id". The columns are not escaped and must not come
+// from the user input. The argN is the number of the arguments which precede the Where ones in the
+// query, it matters for the Postgres placeholders. The rows are compared by the row values, which
+// are supported by SQLite since 3.15 and Postgres:
+//
+//	ks, err := params.Keyset(pagination.Postgres, pagination.Desc, 1, "created_at", "id")
+//	query := `SELECT id, created_at FROM items WHERE owner = $1 AND ` + ks.Where +
+//		` ORDER BY ` + ks.OrderBy + ` LIMIT ` + strconv.Itoa(ks.Limit)
+//	rows, err := conn.Query(ctx, query, append([]any{owner}, ks.Args...)...)
+//
+// Returns the ErrCursorInvalid if the cursor has a different number of keys than the columns.
+func (p Params) Keyset(dialect Dialect, order Order, argN int, columns ...string) (Keyset, error) {
+	// The previous page is selected in the reverse order next to the cursor.
+	if p.Cursor != nil && p.Cursor.Backward {
+		order = map[Order]Order{Asc: Desc, Desc: Asc}[order]
+	}
+
+	direction, operator := " ASC", " > "
+	if order == Desc {
+		direction, operator = " DESC", " < "
+	}
+
+	orderBy := make([]string, len(columns))
+	for i, column := range columns {
+		orderBy[i] = column + direction
+	}
+
+	ks := Keyset{
+		Where:   "1=1",
+		OrderBy: strings.Join(orderBy, ", "),
+		Limit:   p.Limit + 1,
+	}
+
+	if p.Cursor == nil {
+		return ks, nil
+	}
+
+	if len(p.Cursor.Keys) != len(columns) {
+		return Keyset{}, ErrCursorInvalid
+	}
+
+	placeholders := make([]string, len(columns))
+	ks.Args = make([]any, len(columns))
+
+	for i, key := range p.Cursor.Keys {
+		placeholders[i] = "?"
+		if dialect == Postgres {
+			placeholders[i] = "$" + strconv.Itoa(argN+i+1)
+		}
+
+		ks.Args[i] = key
+	}
+
+	ks.Where = "(" + strings.Join(columns, ", ") + ")" + operator + "(" + strings.Join(placeholders, ", ") + ")"
+
+	return ks, nil
+}
@@ -0,0 +1,643 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/retry"
+)
+
+// errUpstreamStatus indicates that the upstream has responded with the status which can be retried.
+var errUpstreamStatus = errors.New("upstream responded with retryable status")
+
+// ProxyBalancer represents the load balancing strategy of the ReverseProxy.
+type ProxyBalancer uint8
+
+const (
+	// ProxyRoundRobin picks the healthy upstreams in turn.
+	ProxyRoundRobin ProxyBalancer = iota
+
+	// ProxyLeastConnections picks the healthy upstream with the fewest in-flight requests.
+	ProxyLeastConnections
+)
+
+// ProxyOptions represents the options of the ReverseProxy.
+type ProxyOptions struct {
+	balancer     ProxyBalancer
+	transport    http.RoundTripper
+	logger       *slog.Logger
+	stripPrefix  string
+	rewrite      func(path string) string
+	preserveHost bool
+
+	healthPath     string
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+
+	requestHeaders        http.Header
+	responseHeaders       http.Header
+	removeRequestHeaders  []string
+	removeResponseHeaders []string
+
+	retry        bool
+	retryOptions []retry.Option
+}
+
+// ProxyOption represents a function type that modifies ProxyOptions.
+type ProxyOption func(o *ProxyOptions)
+
+// WithProxyBalancer sets the load balancing strategy. Default is ProxyRoundRobin.
+func WithProxyBalancer(balancer ProxyBalancer) ProxyOption {
+	return func(o *ProxyOptions) { o.balancer = balancer }
+}
+
+// WithProxyTransport sets the transport of the requests to the upstreams. Default is http.DefaultTransport.
+func WithProxyTransport(transport http.RoundTripper) ProxyOption {
+	return func(o *ProxyOptions) { o.transport = transport }
+}
+
+// WithProxyLogger sets the logger of the upstream health changes. Default is slog.Default.
+func WithProxyLogger(logger *slog.Logger) ProxyOption {
+	return func(o *ProxyOptions) { o.logger = logger }
+}
+
+// WithProxyStripPrefix sets the prefix which is removed from the request path before
+// it is proxied, usually the route the ReverseProxy is mounted on. The paths which do not
+// start with the whole prefix segments, e.g. /apix for the /api prefix, are proxied as is.
+func WithProxyStripPrefix(prefix string) ProxyOption {
+	return func(o *ProxyOptions) { o.stripPrefix = strings.TrimSuffix(prefix, "/") }
+}
+
+// WithProxyRewrite sets the function which rewrites the request path before it is proxied.
+// It is called after the prefix is stripped. The path of the upstream URL is prepended to the result.
+func WithProxyRewrite(rewrite func(path string) string) ProxyOption {
+	return func(o *ProxyOptions) { o.rewrite = rewrite }
+}
+
+// WithProxyPreserveHost keeps the Host header of the incoming request,
+// otherwise the host of the upstream URL is used.
+func WithProxyPreserveHost() ProxyOption {
+	return func(o *ProxyOptions) { o.preserveHost = true }
+}
+
+// WithProxyHealthCheck enables the active health checks of the upstreams. Every interval each
+// upstream is requested by the GET method on the given path, and it is considered healthy while
+// it responds with the 2xx status within the timeout. Unhealthy upstreams get no requests.
+func WithProxyHealthCheck(path string, interval, timeout time.Duration) ProxyOption {
+	return func(o *ProxyOptions) {
+		o.healthPath = path
+		o.healthInterval = interval
+		o.healthTimeout = timeout
+	}
+}
+
+// WithProxyRequestHeader sets the header of the proxied request.
+func WithProxyRequestHeader(key, value string) ProxyOption {
+	return func(o *ProxyOptions) { o.requestHeaders.Set(key, value) }
+}
+
+// WithProxyRemoveRequestHeaders removes the headers from the proxied request.
+func WithProxyRemoveRequestHeaders(keys ...string) ProxyOption {
+	return func(o *ProxyOptions) { o.removeRequestHeaders = append(o.removeRequestHeaders, keys...) }
+}
+
+// WithProxyResponseHeader sets the header of the upstream response.
+func WithProxyResponseHeader(key, value string) ProxyOption {
+	return func(o *ProxyOptions) { o.responseHeaders.Set(key, value) }
+}
+
+// WithProxyRemoveResponseHeaders removes the headers from the upstream response.
+func WithProxyRemoveResponseHeaders(keys ...string) ProxyOption {
+	return func(o *ProxyOptions) { o.removeResponseHeaders = append(o.removeResponseHeaders, keys...) }
+}
+
+// WithProxyRetry enables the retries of the requests which have failed to reach the upstream
+// or have got the 502, 503 or 504 status, by the retry.Do with the given options. Each retry
+// prefers the upstream which has not been tried yet. Only the requests of the idempotent
+// methods without the body are retried, and the protocol upgrades are never retried.
+func WithProxyRetry(options ...retry.Option) ProxyOption {
+	return func(o *ProxyOptions) {
+		o.retry = true
+		o.retryOptions = append(o.retryOptions, options...)
+	}
+}
+
+// proxyUpstream represents the upstream of the ReverseProxy.
+type proxyUpstream struct {
+	url      *url.URL
+	up       atomic.Bool
+	inflight atomic.Int64
+
+	upGauge       *metrics.Gauge
+	inflightGauge *metrics.Gauge
+}
+
+// acquire counts the request to the upstream as in-flight.
+func (u *proxyUpstream) acquire() {
+	u.inflightGauge.Set(float64(u.inflight.Add(1)))
+}
+
+// release counts the request to the upstream as completed.
+func (u *proxyUpstream) release() {
+	u.inflightGauge.Set(float64(u.inflight.Add(-1)))
+}
+
+// ReverseProxy represents the handler which proxies the requests to one of the upstreams,
+// picked by the load balancing strategy among the healthy ones. The protocol upgrades,
+// e.g. the websocket, are passed through, and the server-sent events are flushed immediately.
+// The connection deadlines set by the server timeouts are cleared for the upgrades and the
+// server-sent events, so the long-lived streams are not cut. It exports the http_proxy_requests_total,
+// http_proxy_request_duration, http_proxy_upstream_up and http_proxy_upstream_inflight metrics
+// labeled by the name of the proxy and the upstream host.
+type ReverseProxy struct {
+	name      string
+	options   ProxyOptions
+	upstreams []*proxyUpstream
+	next      atomic.Uint64
+	proxy     *httputil.ReverseProxy
+}
+
+// NewReverseProxy returns a pointer to a new instance of ReverseProxy to the given upstream URLs,
+// e.g. http://10.0.0.1:8080/api. The name is used as the label of the metrics and must be unique.
+// Use the ListenerHTTP.MountProxy to mount it, so the health checks run along with the listener.
+func NewReverseProxy(name string, upstreams []string, options ...ProxyOption) (*ReverseProxy, error) {
+	if len(upstreams) == 0 {
+		return nil, errors.Join(errkit.ErrInvalidArgument, errors.New("proxy: no upstreams"))
+	}
+
+	o := ProxyOptions{
+		balancer:        ProxyRoundRobin,
+		transport:       http.DefaultTransport,
+		logger:          slog.Default(),
+		requestHeaders:  make(http.Header),
+		responseHeaders: make(http.Header),
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	p := ReverseProxy{
+		name:      name,
+		options:   o,
+		upstreams: make([]*proxyUpstream, 0, len(upstreams)),
+	}
+
+	for _, raw := range upstreams {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("proxy: parse upstream %q: %w", raw, err))
+		}
+
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("proxy: upstream %q must be an absolute URL", raw))
+		}
+
+		upstream := proxyUpstream{
+			url:           u,
+			upGauge:       metrics.GetOrCreateGauge(proxyUpstreamStr("http_proxy_upstream_up", name, u.Host), nil),
+			inflightGauge: metrics.GetOrCreateGauge(proxyUpstreamStr("http_proxy_upstream_inflight", name, u.Host), nil),
+		}
+
+		upstream.up.Store(true)
+		upstream.upGauge.Set(1)
+
+		p.upstreams = append(p.upstreams, &upstream)
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &proxyTransport{proxy: &p},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   p.errorHandler,
+	}
+
+	return &p, nil
+}
+
+// ServeHTTP implements http.Handler interface.
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pw := proxyResponseWriter{ResponseWriter: w, rc: http.NewResponseController(w), logger: p.options.logger}
+
+	if HeaderContainsToken(r.Header, "Connection", "upgrade") {
+		pw.clearDeadlines()
+	}
+
+	p.proxy.ServeHTTP(&pw, r)
+}
+
+// Run runs the active health checks of the upstreams until the context is done.
+// It returns immediately if the health checks are disabled.
+func (p *ReverseProxy) Run(ctx context.Context) error {
+	if p.options.healthPath == "" || p.options.healthInterval <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(p.options.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		p.checkHealth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth checks the health of each upstream concurrently.
+func (p *ReverseProxy) checkHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	for _, upstream := range p.upstreams {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := p.probe(ctx, upstream)
+			if ctx.Err() != nil {
+				return
+			}
+
+			healthy := err == nil
+			if upstream.up.Swap(healthy) == healthy {
+				return
+			}
+
+			if healthy {
+				upstream.upGauge.Set(1)
+				p.options.logger.Info("Proxy upstream is healthy",
+					slog.String("proxy", p.name),
+					slog.String("upstream", upstream.url.Host),
+				)
+
+				return
+			}
+
+			upstream.upGauge.Set(0)
+			p.options.logger.Warn("Proxy upstream is unhealthy",
+				slog.String("proxy", p.name),
+				slog.String("upstream", upstream.url.Host),
+				slog.String("error", err.Error()),
+			)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// probe requests the health check path of the upstream.
+func (p *ReverseProxy) probe(ctx context.Context, upstream *proxyUpstream) error {
+	if p.options.healthTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, p.options.healthTimeout)
+		defer cancel()
+	}
+
+	target := *upstream.url
+	target.Path = strings.TrimSuffix(target.Path, "/") + p.options.healthPath
+	target.RawPath = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), http.NoBody)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := p.options.transport.RoundTrip(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// pick returns the healthy upstream by the load balancing strategy.
+// The upstreams which have not been tried yet are preferred.
+func (p *ReverseProxy) pick(tried map[*proxyUpstream]struct{}) (*proxyUpstream, error) {
+	healthy := make([]*proxyUpstream, 0, len(p.upstreams))
+	candidates := make([]*proxyUpstream, 0, len(p.upstreams))
+
+	for _, upstream := range p.upstreams {
+		if !upstream.up.Load() {
+			continue
+		}
+
+		healthy = append(healthy, upstream)
+
+		if _, ok := tried[upstream]; !ok {
+			candidates = append(candidates, upstream)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil, errors.Join(errkit.ErrUnavailable, fmt.Errorf("proxy %s: no healthy upstreams", p.name))
+	}
+
+	if len(candidates) == 0 {
+		candidates = healthy
+	}
+
+	start := int(p.next.Add(1) - 1)
+
+	if p.options.balancer != ProxyLeastConnections {
+		return candidates[start%len(candidates)], nil
+	}
+
+	// Start the scan from the next upstream, so the ties are broken in turn.
+	picked := candidates[start%len(candidates)]
+
+	for i := 1; i < len(candidates); i++ {
+		if upstream := candidates[(start+i)%len(candidates)]; upstream.inflight.Load() < picked.inflight.Load() {
+			picked = upstream
+		}
+	}
+
+	return picked, nil
+}
+
+// rewrite rewrites the outgoing request. The upstream URL is set by the proxyTransport.
+func (p *ReverseProxy) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetXForwarded()
+
+	// The prefix is stripped only at the path segment boundary, so /api does not match /apix.
+	reqPath := pr.In.URL.Path
+	if prefix := p.options.stripPrefix; prefix != "" && (reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")) {
+		reqPath = strings.TrimPrefix(reqPath, prefix)
+		if reqPath == "" {
+			reqPath = "/"
+		}
+	}
+
+	if p.options.rewrite != nil {
+		reqPath = p.options.rewrite(reqPath)
+	}
+
+	pr.Out.URL.Path = reqPath
+	pr.Out.URL.RawPath = ""
+
+	for key, values := range p.options.requestHeaders {
+		pr.Out.Header[key] = append([]string(nil), values...)
+	}
+
+	for _, key := range p.options.removeRequestHeaders {
+		pr.Out.Header.Del(key)
+	}
+}
+
+// modifyResponse modifies the headers of the upstream response.
+func (p *ReverseProxy) modifyResponse(resp *http.Response) error {
+	for key, values := range p.options.responseHeaders {
+		resp.Header[key] = append([]string(nil), values...)
+	}
+
+	for _, key := range p.options.removeResponseHeaders {
+		resp.Header.Del(key)
+	}
+
+	return nil
+}
+
+// errorHandler responds by the ErrorHTTP with the 503 status if there are no healthy upstreams,
+// 504 if the upstream has timed out, and 502 otherwise.
+func (*ReverseProxy) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.Canceled) && r.Context().Err() != nil:
+		// The client has gone, nobody reads the response.
+		if hook := ctxkit.GetLogErrHook(r.Context()); hook != nil {
+			hook(err)
+		}
+
+	case errors.Is(err, errkit.ErrUnavailable):
+		ErrorHTTP(w, r, err, WithStatus(http.StatusServiceUnavailable))
+
+	case errors.Is(err, context.DeadlineExceeded):
+		ErrorHTTP(w, r, err, WithStatus(http.StatusGatewayTimeout))
+
+	default:
+		ErrorHTTP(w, r, err, WithStatus(http.StatusBadGateway))
+	}
+}
+
+// proxyTransport implements http.RoundTripper interface which sends
+// the request to the upstream picked by the ReverseProxy and retries it.
+type proxyTransport struct {
+	proxy *ReverseProxy
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[*proxyUpstream]struct{}, len(t.proxy.upstreams))
+
+	if !t.retryable(req) {
+		return t.send(req.Context(), req, tried)
+	}
+
+	var (
+		last    *http.Response
+		lastErr error
+	)
+
+	err := retry.Do(req.Context(), func(ctx context.Context) error {
+		resp, err := t.send(ctx, req, tried)
+		if err != nil {
+			if errors.Is(err, errkit.ErrUnavailable) {
+				return err
+			}
+
+			lastErr = err
+
+			return retry.MarkRetryable(err)
+		}
+
+		if last != nil {
+			_ = last.Body.Close()
+		}
+
+		last = resp
+
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return retry.MarkRetryable(errUpstreamStatus)
+
+		default:
+			return nil
+		}
+	}, t.proxy.options.retryOptions...)
+
+	// Respond with the last upstream response if it has been retried to no avail.
+	if last != nil && (err == nil || errors.Is(err, retry.ErrRetryLimitReached)) {
+		return last, nil
+	}
+
+	if last != nil {
+		_ = last.Body.Close()
+	}
+
+	if errors.Is(err, retry.ErrRetryLimitReached) && lastErr != nil {
+		return nil, fmt.Errorf("%w: %w", err, lastErr)
+	}
+
+	return nil, err
+}
+
+// retryable reports whether the request can be retried.
+func (t *proxyTransport) retryable(req *http.Request) bool {
+	if !t.proxy.options.retry || req.Header.Get("Upgrade") != "" {
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// send sends the request to the picked upstream and collects the metrics.
+func (t *proxyTransport) send(ctx context.Context, req *http.Request, tried map[*proxyUpstream]struct{}) (*http.Response, error) {
+	upstream, err := t.proxy.pick(tried)
+	if err != nil {
+		return nil, err
+	}
+
+	tried[upstream] = struct{}{}
+
+	out := req.Clone(ctx)
+	out.URL.Scheme = upstream.url.Scheme
+	out.URL.Host = upstream.url.Host
+	out.URL.Path = strings.TrimSuffix(upstream.url.Path, "/") + req.URL.Path
+	out.URL.RawPath = ""
+
+	if !t.proxy.options.preserveHost {
+		out.Host = ""
+	}
+
+	start := time.Now()
+
+	upstream.acquire()
+
+	resp, err := t.proxy.options.transport.RoundTrip(out)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+
+	metrics.GetOrCreateSummaryExt(proxyUpstreamStr("http_proxy_request_duration", t.proxy.name, upstream.url.Host),
+		5*time.Minute, []float64{0.95, 0.99},
+	).UpdateDuration(start)
+
+	metrics.GetOrCreateCounter(proxyRequestsTotalStr(t.proxy.name, upstream.url.Host, code)).
+		Inc()
+
+	if err != nil {
+		upstream.release()
+		return nil, err
+	}
+
+	// The request is in-flight until the body is closed, which may take long for the streams.
+	// The body of the upgraded connection must stay writable for the ReverseProxy.
+	var once sync.Once
+
+	release := func() { once.Do(upstream.release) }
+
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok {
+		resp.Body = &proxyUpgradeBody{ReadWriteCloser: rwc, release: release}
+	} else {
+		resp.Body = &proxyBody{ReadCloser: resp.Body, release: release}
+	}
+
+	return resp, nil
+}
+
+// proxyBody releases the upstream when the response body is closed.
+type proxyBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *proxyBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// proxyUpgradeBody releases the upstream when the upgraded connection is closed.
+type proxyUpgradeBody struct {
+	io.ReadWriteCloser
+	release func()
+}
+
+func (b *proxyUpgradeBody) Close() error {
+	defer b.release()
+	return b.ReadWriteCloser.Close()
+}
+
+// proxyResponseWriter clears the connection deadlines when the upstream starts the stream.
+type proxyResponseWriter struct {
+	http.ResponseWriter
+
+	rc     *http.ResponseController
+	logger *slog.Logger
+}
+
+func (w *proxyResponseWriter) WriteHeader(code int) {
+	if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType == "text/event-stream" {
+		w.clearDeadlines()
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the underlying http.ResponseWriter for the http.ResponseController.
+func (w *proxyResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// clearDeadlines clears the read and write deadlines of the connection.
+func (w *proxyResponseWriter) clearDeadlines() {
+	if err := w.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		w.logger.Error("Proxy failed to reset write deadline", slog.String("error", err.Error()))
+	}
+
+	if err := w.rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		w.logger.Error("Proxy failed to reset read deadline", slog.String("error", err.Error()))
+	}
+}
+
+func proxyUpstreamStr(metric, name, upstream string) string {
+	return metric + `{proxy="` + name + `", upstream="` + upstream + `"}`
+}
+
+func proxyRequestsTotalStr(name, upstream, code string) string {
+	return `http_proxy_requests_total{proxy="` + name + `", upstream="` + upstream + `", code="` + code + `"}`
+}
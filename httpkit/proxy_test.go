@@ -0,0 +1,288 @@
+package httpkit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/retry"
+)
+
+func newProxyUpstream(t *testing.T, name string, status int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Gateway", r.Header.Get("X-Gateway"))
+		w.Header().Set("X-Secret", "secret")
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func proxyGet(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	return w
+}
+
+func TestReverseProxy(t *testing.T) {
+	a := newProxyUpstream(t, "a", http.StatusOK)
+	b := newProxyUpstream(t, "b", http.StatusOK)
+
+	proxy, err := NewReverseProxy("test-rr", []string{a.URL + "/v1", b.URL + "/v1"},
+		WithProxyStripPrefix("/api"),
+		WithProxyRequestHeader("X-Gateway", "servekit"),
+		WithProxyRemoveResponseHeaders("X-Secret"),
+	)
+	td.CmpNoError(t, err)
+
+	router := chi.NewRouter()
+	router.Mount("/api", proxy)
+
+	w := proxyGet(t, router, "/api/items/1?q=1")
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Header().Get("X-Upstream"), "a")
+	td.Cmp(t, w.Header().Get("X-Path"), "/v1/items/1")
+	td.Cmp(t, w.Header().Get("X-Gateway"), "servekit")
+	td.Cmp(t, w.Header().Get("X-Secret"), "")
+
+	td.Cmp(t, proxyGet(t, router, "/api/items/1").Header().Get("X-Upstream"), "b")
+	td.Cmp(t, proxyGet(t, router, "/api/items/1").Header().Get("X-Upstream"), "a")
+
+	// The prefix is stripped at the path segment boundary only.
+	td.Cmp(t, proxyGet(t, proxy, "/api").Header().Get("X-Path"), "/v1/")
+	td.Cmp(t, proxyGet(t, proxy, "/apix/foo").Header().Get("X-Path"), "/v1/apix/foo")
+
+	_, err = NewReverseProxy("test-invalid", []string{"localhost"})
+	td.CmpError(t, err)
+}
+
+func TestReverseProxy_retry(t *testing.T) {
+	a := newProxyUpstream(t, "a", http.StatusServiceUnavailable)
+	b := newProxyUpstream(t, "b", http.StatusOK)
+
+	proxy, err := NewReverseProxy("test-retry", []string{a.URL, b.URL}, WithProxyRetry(retry.WithMaxAttempts(2)))
+	td.CmpNoError(t, err)
+
+	for range 4 {
+		w := proxyGet(t, proxy, "/")
+		td.Cmp(t, w.Code, http.StatusOK)
+		td.Cmp(t, w.Header().Get("X-Upstream"), "b")
+	}
+
+	// The last upstream response is returned when the retries are exhausted.
+	proxy, err = NewReverseProxy("test-retry-exhausted", []string{a.URL}, WithProxyRetry(retry.WithMaxAttempts(2)))
+	td.CmpNoError(t, err)
+	td.Cmp(t, proxyGet(t, proxy, "/").Code, http.StatusServiceUnavailable)
+}
+
+func TestReverseProxy_healthCheck(t *testing.T) {
+	a := newProxyUpstream(t, "a", http.StatusInternalServerError)
+	b := newProxyUpstream(t, "b", http.StatusOK)
+
+	proxy, err := NewReverseProxy("test-health", []string{a.URL, b.URL},
+		WithProxyHealthCheck("/health", 0, 0),
+		WithProxyLogger(slog.New(slog.DiscardHandler)),
+	)
+	td.CmpNoError(t, err)
+
+	proxy.checkHealth(context.Background())
+
+	for range 3 {
+		td.Cmp(t, proxyGet(t, proxy, "/").Header().Get("X-Upstream"), "b")
+	}
+
+	b.Close()
+	proxy.checkHealth(context.Background())
+
+	td.Cmp(t, proxyGet(t, proxy, "/").Code, http.StatusServiceUnavailable)
+}
+
+func TestReverseProxy_leastConnections(t *testing.T) {
+	proxy, err := NewReverseProxy("test-lc", []string{"http://a", "http://b"}, WithProxyBalancer(ProxyLeastConnections))
+	td.CmpNoError(t, err)
+
+	proxy.upstreams[0].acquire()
+	defer proxy.upstreams[0].release()
+
+	for range 3 {
+		upstream, err := proxy.pick(nil)
+		td.CmpNoError(t, err)
+		td.Cmp(t, upstream.url.Host, "b")
+	}
+}
+
+func TestReverseProxy_upgrade(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = rw.Flush()
+
+		_, _ = io.Copy(conn, rw)
+	}))
+	defer upstream.Close()
+
+	proxy, err := NewReverseProxy("test-upgrade", []string{upstream.URL})
+	td.CmpNoError(t, err)
+
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	td.CmpNoError(t, err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	td.CmpNoError(t, err)
+
+	reader := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(reader, nil)
+	td.CmpNoError(t, err)
+	td.Cmp(t, resp.StatusCode, http.StatusSwitchingProtocols)
+
+	_, err = io.WriteString(conn, "ping")
+	td.CmpNoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(reader, buf)
+	td.CmpNoError(t, err)
+	td.Cmp(t, string(buf), "ping")
+}
+
+// serveListenerHTTP serves the listener on a free local port until the end of the test and returns its address.
+func serveListenerHTTP(t *testing.T, mount func(l *ListenerHTTP), options ...ListenerOption[ListenerConfig]) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	td.CmpNoError(t, err)
+
+	addr := ln.Addr().String()
+	td.CmpNoError(t, ln.Close())
+
+	l, err := NewListenerHTTP(addr, options...)
+	td.CmpNoError(t, err)
+
+	mount(l)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		_ = l.Serve(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	for range 100 {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			_ = conn.Close()
+			return addr
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("listener has not started on %s", addr)
+
+	return ""
+}
+
+func TestReverseProxy_streamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			conn, rw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			_ = rw.Flush()
+
+			_, _ = io.Copy(conn, rw)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		for i := range 4 {
+			_, _ = fmt.Fprintf(w, "data: %d\n\n", i)
+			_ = http.NewResponseController(w).Flush()
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	proxy, err := NewReverseProxy("test-stream-timeout", []string{upstream.URL})
+	td.CmpNoError(t, err)
+
+	// The streams outlive the server timeouts.
+	addr := serveListenerHTTP(t, func(l *ListenerHTTP) { l.MountProxy("/", proxy) },
+		WithHTTPServerTimeouts(HTTPServerReadTimeout(150*time.Millisecond), HTTPServerWriteTimeout(150*time.Millisecond)),
+	)
+
+	t.Run("events", func(t *testing.T) {
+		resp, err := http.Get("http://" + addr + "/events")
+		td.CmpNoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		td.CmpNoError(t, err)
+		td.Cmp(t, string(body), "data: 0\n\ndata: 1\n\ndata: 2\n\ndata: 3\n\n")
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		td.CmpNoError(t, err)
+		defer conn.Close()
+
+		_, err = io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		td.CmpNoError(t, err)
+
+		reader := bufio.NewReader(conn)
+
+		resp, err := http.ReadResponse(reader, nil)
+		td.CmpNoError(t, err)
+		td.Cmp(t, resp.StatusCode, http.StatusSwitchingProtocols)
+
+		time.Sleep(300 * time.Millisecond)
+
+		_, err = io.WriteString(conn, "ping")
+		td.CmpNoError(t, err)
+
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(reader, buf)
+		td.CmpNoError(t, err)
+		td.Cmp(t, string(buf), "ping")
+	})
+}
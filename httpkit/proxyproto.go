@@ -0,0 +1,286 @@
+package httpkit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtoSignature represents the signature of the PROXY protocol v2 header.
+var proxyProtoSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtoV1MaxLen represents the max length of the PROXY protocol v1 header line.
+const proxyProtoV1MaxLen = 107
+
+// proxyProtoListener implements net.Listener interface which reads the PROXY protocol v1 or v2
+// header of the connections from the trusted proxies and reports the source address of the
+// header as the remote address of the connection. Connections from the trusted proxies without
+// the header are rejected, while the other connections are passed as is. The headers are read
+// in background, so the slow peers do not block the accept loop.
+type proxyProtoListener struct {
+	net.Listener
+
+	trusted []netip.Prefix
+	timeout time.Duration
+
+	conns chan net.Conn
+	errs  chan error
+	done  chan struct{}
+	once  sync.Once
+}
+
+// newProxyProtoListener returns a pointer to a new instance of proxyProtoListener
+// which accepts the connections of the given listener.
+func newProxyProtoListener(l net.Listener, trusted []netip.Prefix, timeout time.Duration) *proxyProtoListener {
+	pl := proxyProtoListener{
+		Listener: l,
+		trusted:  trusted,
+		timeout:  timeout,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+
+	go pl.acceptLoop()
+
+	return &pl
+}
+
+// Accept waits for and returns the next connection with the read header.
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+
+	case err := <-l.errs:
+		return nil, err
+
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener.
+func (l *proxyProtoListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+func (l *proxyProtoListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			continue
+		}
+
+		go l.handshake(conn)
+	}
+}
+
+// handshake reads the header of the connection from the trusted proxy and passes the connection to the Accept.
+func (l *proxyProtoListener) handshake(conn net.Conn) {
+	if peer := parseHostIP(conn.RemoteAddr().String()); peer.IsValid() && l.isTrusted(peer) {
+		wrapped, err := l.readHeader(conn)
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		conn = wrapped
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close()
+	}
+}
+
+func (l *proxyProtoListener) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range l.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readHeader reads the PROXY protocol header within the timeout.
+func (l *proxyProtoListener) readHeader(conn net.Conn) (net.Conn, error) {
+	if l.timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(l.timeout)); err != nil {
+			return nil, fmt.Errorf("set read deadline: %w", err)
+		}
+	}
+
+	reader := bufio.NewReader(conn)
+
+	source, err := readProxyHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("reset read deadline: %w", err)
+	}
+
+	return &proxyProtoConn{Conn: conn, reader: reader, remote: source}, nil
+}
+
+// proxyProtoConn represents the connection which remote address is read from the PROXY protocol header.
+type proxyProtoConn struct {
+	net.Conn
+
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+// Read reads the data buffered while the header has been read first.
+func (c *proxyProtoConn) Read(b []byte) (int, error) { return c.reader.Read(b) }
+
+// RemoteAddr returns the source address of the header, or the peer
+// address if the header does not carry it, e.g. for the health checks.
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	if c.remote != nil {
+		return c.remote
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads the PROXY protocol v1 or v2 header and returns the source address.
+// The nil address is returned for the connections which are not proxied, e.g. LOCAL and UNKNOWN,
+// so the peer address is used.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	prefix, err := r.Peek(len(proxyProtoSignature))
+	if err != nil {
+		return nil, fmt.Errorf("read proxy protocol header: %w", err)
+	}
+
+	if bytes.Equal(prefix, proxyProtoSignature) {
+		return readProxyHeaderV2(r)
+	}
+
+	if bytes.HasPrefix(prefix, []byte("PROXY ")) {
+		return readProxyHeaderV1(r)
+	}
+
+	return nil, errors.New("proxy protocol header is missing")
+}
+
+// readProxyHeaderV1 reads the text header, e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+
+	for len(line) < proxyProtoV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("read proxy protocol v1 header: %w", err)
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+	}
+
+	header, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("proxy protocol v1 header is too long")
+	}
+
+	fields := strings.Split(header, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol v1 header: %q", header)
+	}
+
+	addr, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy protocol v1 source address: %w", err)
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy protocol v1 source port: %w", err)
+	}
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(port))), nil
+}
+
+// readProxyHeaderV2 reads the binary header.
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("read proxy protocol v2 header: %w", err)
+	}
+
+	verCmd, family := header[12], header[13]
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("read proxy protocol v2 addresses: %w", err)
+	}
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version: %d", verCmd>>4)
+	}
+
+	switch verCmd & 0x0F {
+	case 0x00: // LOCAL, e.g. the health check of the proxy itself.
+		return nil, nil
+
+	case 0x01: // PROXY.
+
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol v2 command: %d", verCmd&0x0F)
+	}
+
+	var size int
+
+	switch family >> 4 {
+	case 0x1: // AF_INET.
+		size = 4
+
+	case 0x2: // AF_INET6.
+		size = 16
+
+	default:
+		// The UNIX sockets and the unspecified family carry no IP address.
+		return nil, nil
+	}
+
+	// The source and destination addresses are followed by the source and destination ports.
+	if len(payload) < 2*size+4 {
+		return nil, errors.New("proxy protocol v2 addresses are truncated")
+	}
+
+	addr, _ := netip.AddrFromSlice(payload[:size])
+	port := binary.BigEndian.Uint16(payload[2*size : 2*size+2])
+
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr.Unmap(), port)), nil
+}
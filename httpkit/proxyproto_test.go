@@ -0,0 +1,100 @@
+package httpkit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	v2 := func(cmd, family byte, addrs []byte) string {
+		header := append([]byte{}, proxyProtoSignature...)
+		header = append(header, 0x20|cmd, family)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+
+		return string(append(header, addrs...))
+	}
+
+	tests := map[string]struct {
+		header  string
+		want    string
+		wantErr bool
+	}{
+		"v1 tcp4":    {header: "PROXY TCP4 198.51.100.1 10.0.0.1 56324 443\r\n", want: "198.51.100.1:56324"},
+		"v1 tcp6":    {header: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", want: "[2001:db8::1]:56324"},
+		"v1 unknown": {header: "PROXY UNKNOWN\r\n", want: "<nil>"},
+		"v1 invalid": {header: "PROXY TCP4 198.51.100.1\r\n", wantErr: true},
+		"v1 long":    {header: "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n", wantErr: true},
+		"v2 tcp4": {
+			header: v2(0x1, 0x11, []byte{198, 51, 100, 1, 10, 0, 0, 1, 0xDC, 0x04, 0x01, 0xBB}),
+			want:   "198.51.100.1:56324",
+		},
+		"v2 local":  {header: v2(0x0, 0x00, nil), want: "<nil>"},
+		"v2 short":  {header: v2(0x1, 0x11, []byte{198, 51, 100, 1}), wantErr: true},
+		"no header": {header: "GET / HTTP/1.1\r\n\r\n", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			addr, err := readProxyHeader(bufio.NewReader(strings.NewReader(tt.header + "rest")))
+			if tt.wantErr {
+				td.CmpError(t, err)
+				return
+			}
+
+			td.CmpNoError(t, err)
+
+			if addr == nil {
+				td.Cmp(t, "<nil>", tt.want)
+				return
+			}
+
+			td.Cmp(t, addr.String(), tt.want)
+		})
+	}
+}
+
+func TestProxyProtoListener(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	td.CmpNoError(t, err)
+
+	ln := newProxyProtoListener(inner, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, time.Second)
+	defer ln.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	td.CmpNoError(t, err)
+	defer client.Close()
+
+	_, err = io.WriteString(client, "PROXY TCP4 198.51.100.1 10.0.0.1 56324 443\r\nping")
+	td.CmpNoError(t, err)
+
+	conn, err := ln.Accept()
+	td.CmpNoError(t, err)
+	defer conn.Close()
+
+	td.Cmp(t, conn.RemoteAddr().String(), "198.51.100.1:56324")
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(conn, buf)
+	td.CmpNoError(t, err)
+	td.Cmp(t, string(buf), "ping")
+
+	// The connection from the trusted proxy without the header is rejected.
+	rejected, err := net.Dial("tcp", ln.Addr().String())
+	td.CmpNoError(t, err)
+	defer rejected.Close()
+
+	_, err = io.WriteString(rejected, "GET / HTTP/1.1\r\n\r\n")
+	td.CmpNoError(t, err)
+
+	_ = rejected.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = rejected.Read(buf)
+	td.Cmp(t, err, io.EOF)
+}
@@ -0,0 +1,168 @@
+package httpkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// ParseTrustedProxies parses the CIDRs of the trusted proxies, e.g. 10.0.0.0/8.
+// The single IP addresses are parsed as the prefixes of the full length.
+func ParseTrustedProxies(cidrs ...string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			addr, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("parse trusted proxy %q: %w", cidr, err))
+			}
+
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("parse trusted proxy %q: %w", cidr, err))
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// RealIPOptions represents the options of the RealIPMiddleware.
+type RealIPOptions struct {
+	headers []string
+}
+
+// RealIPOption represents a function type that modifies RealIPOptions.
+type RealIPOption func(o *RealIPOptions)
+
+// WithRealIPHeaders sets the headers which are looked up for the client IP in the given order,
+// the first present one is used. Besides the Forwarded and the X-Forwarded-For lists, the headers
+// with the single address are supported, e.g. CF-Connecting-IP. Default is Forwarded,
+// X-Forwarded-For and X-Real-IP.
+func WithRealIPHeaders(headers ...string) RealIPOption {
+	return func(o *RealIPOptions) { o.headers = headers }
+}
+
+// RealIPMiddleware returns the middleware which resolves the client IP address and sets it to the
+// request context, see ctxkit.GetClientIP. The headers are only taken into account when the peer
+// is the trusted proxy, otherwise the peer address is the client one. The lists are walked from
+// the right, and the first address which is not the trusted proxy is the client one, so the
+// addresses forged by the client are ignored. Use it before the LoggingMiddleware to log the client IP.
+func RealIPMiddleware(trusted []netip.Prefix, options ...RealIPOption) Middleware {
+	o := RealIPOptions{
+		headers: []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"},
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			peer := parseHostIP(r.RemoteAddr)
+			if !peer.IsValid() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := peer
+
+			if isTrusted(peer) {
+				for _, header := range o.headers {
+					hops := headerHops(r.Header, header)
+					if len(hops) == 0 {
+						continue
+					}
+
+					for i := len(hops) - 1; i >= 0; i-- {
+						hop := parseHostIP(hops[i])
+						if !hop.IsValid() {
+							break
+						}
+
+						client = hop
+
+						if !isTrusted(hop) {
+							break
+						}
+					}
+
+					break
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctxkit.SetClientIP(r.Context(), client)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// headerHops returns the addresses of the header in the order they have been added by the proxies.
+func headerHops(header http.Header, key string) []string {
+	values := header.Values(key)
+	if len(values) == 0 {
+		return nil
+	}
+
+	var hops []string
+
+	for _, value := range values {
+		for element := range strings.SplitSeq(value, ",") {
+			element = strings.TrimSpace(element)
+
+			if http.CanonicalHeaderKey(key) != "Forwarded" {
+				hops = append(hops, element)
+				continue
+			}
+
+			// The Forwarded element is the list of pairs, e.g. for=192.0.2.60;proto=http.
+			for pair := range strings.SplitSeq(element, ";") {
+				if name, value, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(name, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+	}
+
+	return hops
+}
+
+// parseHostIP parses the IP address with an optional port, e.g. 192.0.2.1:80 or "[2001:db8::1]:4711".
+// It returns the invalid netip.Addr if the address cannot be parsed.
+func parseHostIP(s string) netip.Addr {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")); err == nil {
+		return addr.Unmap()
+	}
+
+	return netip.Addr{}
+}
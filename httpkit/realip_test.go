@@ -0,0 +1,80 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/ctxkit"
+)
+
+func TestRealIPMiddleware(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8", "192.0.2.1")
+	td.CmpNoError(t, err)
+
+	_, err = ParseTrustedProxies("10.0.0.0/33")
+	td.CmpError(t, err)
+
+	var got string
+
+	handler := RealIPMiddleware(trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		got = ctxkit.GetClientIP(r.Context()).String()
+	}))
+
+	tests := map[string]struct {
+		remote string
+		header http.Header
+		want   string
+	}{
+		"untrusted peer": {
+			remote: "203.0.113.7:4000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1"}},
+			want:   "203.0.113.7",
+		},
+		"forwarded for": {
+			remote: "10.0.0.1:4000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 10.0.0.2"}},
+			want:   "203.0.113.7",
+		},
+		"forwarded for across values": {
+			remote: "10.0.0.1:4000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1", "192.0.2.1"}},
+			want:   "198.51.100.1",
+		},
+		"all trusted": {
+			remote: "10.0.0.1:4000",
+			header: http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			want:   "10.0.0.3",
+		},
+		"forwarded": {
+			remote: "10.0.0.1:4000",
+			header: http.Header{
+				"Forwarded":       {`for=198.51.100.1;proto=https, for="[2001:db8::1]:4711"`},
+				"X-Forwarded-For": {"203.0.113.7"},
+			},
+			want: "2001:db8::1",
+		},
+		"real ip": {
+			remote: "192.0.2.1:4000",
+			header: http.Header{"X-Real-Ip": {"198.51.100.1"}},
+			want:   "198.51.100.1",
+		},
+		"invalid hop": {
+			remote: "10.0.0.1:4000",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1, unknown, 10.0.0.2"}},
+			want:   "10.0.0.2",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			r.Header = tt.header
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			td.Cmp(t, got, tt.want)
+		})
+	}
+}
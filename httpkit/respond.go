@@ -1,12 +1,15 @@
 package httpkit
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/plainq/servekit/ctxkit"
 	"github.com/plainq/servekit/errkit"
@@ -43,14 +46,19 @@ var (
 		case errors.Is(err, errkit.ErrInvalidArgument):
 			statusCode = http.StatusBadRequest
 
-		case errors.Is(err, errkit.ErrUnavailable):
+		case errors.Is(err, errkit.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
 			statusCode = http.StatusServiceUnavailable
 
+		case errors.Is(err, errkit.ErrPreconditionFailed):
+			statusCode = http.StatusPreconditionFailed
+
 		default:
 			statusCode = http.StatusInternalServerError
 		}
 
-		if o.statusCode != 0 {
+		// Explicitly given error status overrides the mapped one.
+		// Not an error status is the default of ResponseOptions.
+		if o.statusCode >= http.StatusBadRequest {
 			statusCode = o.statusCode
 		}
 
@@ -65,16 +73,72 @@ var (
 	htmlTemplater HTMLTemplateProvider = &noopTemplater{}
 )
 
-// SetHTTPErrorResponder sets the given responder as errHTTPResponder.
+const (
+	// errHTTPResponderKey represents a Key for context by which
+	// the HTTPErrorResponder of the request can be received from the context.
+	errHTTPResponderKey ctxkit.Key = "ctx.httpkit.error-responder"
+
+	// htmlTemplaterKey represents a Key for context by which
+	// the HTMLTemplateProvider of the request can be received from the context.
+	htmlTemplaterKey ctxkit.Key = "ctx.httpkit.html-templater"
+)
+
+// SetHTTPErrorResponder sets the given responder as errHTTPResponder, which is used
+// by default, when the request context has no responder. See ErrorResponderMiddleware.
 func SetHTTPErrorResponder(responder HTTPErrorResponder) {
 	errHTTPResponderInit.Do(func() { errHTTPResponder = responder })
 }
 
-// SetHTMLTemplater sets the given templater as htmlTemplater.
+// SetHTMLTemplater sets the given templater as htmlTemplater, which is used
+// by default, when the request context has no templater. See TemplaterMiddleware.
 func SetHTMLTemplater(templater HTMLTemplateProvider) {
 	htmlTemplaterInit.Do(func() { htmlTemplater = templater })
 }
 
+// ErrorResponderMiddleware returns the middleware which sets the responder to the request
+// context, so it is used by the ErrorHTTP instead of the default one. The middleware can be
+// applied to the route group to override the responder of the listener.
+func ErrorResponderMiddleware(responder HTTPErrorResponder) Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ctxkit.Set(r.Context(), errHTTPResponderKey, responder)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// TemplaterMiddleware returns the middleware which sets the templater to the request
+// context, so it is used by the TemplateHTML instead of the default one. The middleware can be
+// applied to the route group to override the templater of the listener.
+func TemplaterMiddleware(templater HTMLTemplateProvider) Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ctxkit.Set(r.Context(), htmlTemplaterKey, templater)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// errorResponder returns the responder of the request context or the default one.
+func errorResponder(ctx context.Context) HTTPErrorResponder {
+	if responder := ctxkit.Get[HTTPErrorResponder](ctx, errHTTPResponderKey); responder != nil {
+		return responder
+	}
+
+	return errHTTPResponder
+}
+
+// templater returns the templater of the request context or the default one.
+func templater(ctx context.Context) HTMLTemplateProvider {
+	if t := ctxkit.Get[HTMLTemplateProvider](ctx, htmlTemplaterKey); t != nil {
+		return t
+	}
+
+	return htmlTemplater
+}
+
 // HTMLTemplateProvider wraps a Template method to render requested HTML template.
 type HTMLTemplateProvider interface {
 	// Template renders the HTML templates by given name.
@@ -112,6 +176,13 @@ type ResponseOptions struct {
 	statusCode  int
 	headers     http.Header
 	reportError bool
+
+	// etag, contentETag, lastModified and cacheControl
+	// control HTTP caching and conditional requests.
+	etag         string
+	contentETag  bool
+	lastModified time.Time
+	cacheControl string
 }
 
 // NewResponseOptions returns a pointer to a new ResponseOptions object with default values and applies the given options to it.
@@ -146,21 +217,26 @@ func (o *ResponseOptions) setHeadersToResponse(w http.ResponseWriter) {
 type HTTPErrorResponder func(w http.ResponseWriter, err error, options ...ResponseOption)
 
 // Status writes an HTTP status to the w http.ResponseWriter.
-func Status(w http.ResponseWriter, _ *http.Request, statusCode int, options ...ResponseOption) {
+func Status(w http.ResponseWriter, r *http.Request, statusCode int, options ...ResponseOption) {
 	o := NewResponseOptions(w, options...)
 	o.statusCode = statusCode
+
+	if o.conditional(w, r, nil) {
+		return
+	}
+
 	w.WriteHeader(o.statusCode)
 }
 
 // JSON tries to encode v into json representation and write it to response writer.
+// The value is encoded into a buffer first, so encoding errors result in a clean 500 response.
 func JSON(w http.ResponseWriter, r *http.Request, v any, options ...ResponseOption) {
 	o := NewResponseOptions(w, options...)
 
-	coder := json.NewEncoder(w)
-	coder.SetEscapeHTML(true)
+	var buf bytes.Buffer
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(o.statusCode)
+	coder := json.NewEncoder(&buf)
+	coder.SetEscapeHTML(true)
 
 	if err := coder.Encode(v); err != nil {
 		// Get log hook from the context to set an error which
@@ -170,6 +246,20 @@ func JSON(w http.ResponseWriter, r *http.Request, v any, options ...ResponseOpti
 		}
 
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if o.conditional(w, r, buf.Bytes()) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(o.statusCode)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		if hook := ctxkit.GetLogErrHook(r.Context()); hook != nil {
+			hook(err)
+		}
 	}
 }
 
@@ -177,6 +267,10 @@ func JSON(w http.ResponseWriter, r *http.Request, v any, options ...ResponseOpti
 func HTML(w http.ResponseWriter, r *http.Request, v []byte, options ...ResponseOption) {
 	o := NewResponseOptions(w, options...)
 
+	if o.conditional(w, r, v) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(o.statusCode)
 
@@ -200,6 +294,10 @@ func HTML(w http.ResponseWriter, r *http.Request, v []byte, options ...ResponseO
 func TEXT(w http.ResponseWriter, r *http.Request, v []byte, options ...ResponseOption) {
 	o := NewResponseOptions(w, options...)
 
+	if o.conditional(w, r, v) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(o.statusCode)
 
@@ -228,42 +326,65 @@ func ErrorHTTP(w http.ResponseWriter, r *http.Request, err error, options ...Res
 		hook(err)
 	}
 
-	// Call the default error responder.
-	errHTTPResponder(w, err, options...)
+	// Call the error responder of the request or the default one.
+	errorResponder(r.Context())(w, err, options...)
 }
 
 // TemplateHTML generates an HTML template response for the given name and data.
 // It sets the Content-Type header to "text/html; charset=utf-8" and writes the
-// response with the specified status code. If the template execution fails, an
-// error will be logged and a 500 Internal Server Error response will be sent.
+// response with the specified status code. The template is rendered into a buffer
+// first, so if the template execution fails, nothing is written but the error
+// response by the ErrorHTTP with 500 status, and the error is logged.
 // Additional options can be passed to modify the response using the Option functions.
 func TemplateHTML( //nolint:revive // argument-limit is acceptable here.
 	w http.ResponseWriter, r *http.Request, name string, v any, options ...ResponseOption,
 ) {
 	o := NewResponseOptions(w, options...)
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(o.statusCode)
+	// The content ETag requires the rendered body, otherwise skip the rendering.
+	if !o.contentETag && o.conditional(w, r, nil) {
+		return
+	}
 
-	templ, err := htmlTemplater.Template(r.Context(), name)
+	templ, err := templater(r.Context()).Template(r.Context(), name)
 	if err != nil {
-		if hook := ctxkit.GetLogErrHook(r.Context()); hook != nil {
-			hook(err)
+		ErrorHTTP(w, r, err, WithStatus(http.StatusInternalServerError))
+		return
+	}
+
+	// Bind the template functions to the request which has the CSP nonce. The clone is escaped
+	// on each execution, so the templates of the requests without the nonce are executed as is.
+	if CSPNonce(r.Context()) != "" {
+		clone, err := templ.Clone()
+		if err != nil {
+			ErrorHTTP(w, r, fmt.Errorf("bind template %q to the request: %w", name, err),
+				WithStatus(http.StatusInternalServerError),
+			)
+
+			return
 		}
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		templ = clone.Funcs(requestTemplateFuncs(r.Context()))
+	}
+
+	var buf bytes.Buffer
 
+	if err := templ.Execute(&buf, v); err != nil {
+		ErrorHTTP(w, r, err, WithStatus(http.StatusInternalServerError))
 		return
 	}
 
-	if err := templ.Execute(w, v); err != nil {
-		// Get log hook from the context to set an error which
-		// will be logged along with access log line.
+	if o.contentETag && o.conditional(w, r, buf.Bytes()) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(o.statusCode)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
 		if hook := ctxkit.GetLogErrHook(r.Context()); hook != nil {
 			hook(err)
 		}
-
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
@@ -0,0 +1,355 @@
+package httpkit
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plainq/servekit/ctxkit"
+)
+
+// Content-Security-Policy source keywords.
+const (
+	CSPSelf           = "'self'"
+	CSPNone           = "'none'"
+	CSPStrictDynamic  = "'strict-dynamic'"
+	CSPUnsafeInline   = "'unsafe-inline'"
+	CSPUnsafeEval     = "'unsafe-eval'"
+	CSPReportSample   = "'report-sample'"
+	CSPWasmUnsafeEval = "'wasm-unsafe-eval'"
+)
+
+// cspNonceKey represents a Key for context by which
+// the Content-Security-Policy nonce can be received from the context.
+const cspNonceKey ctxkit.Key = "ctx.httpkit.csp-nonce"
+
+// cspReportGroup represents the name of the Reporting API endpoint for CSP violation reports.
+const cspReportGroup = "csp-endpoint"
+
+// CSP represents a typed Content-Security-Policy.
+// Empty source lists are omitted from the resulting header value.
+type CSP struct {
+	DefaultSrc     []string
+	ScriptSrc      []string
+	StyleSrc       []string
+	ImgSrc         []string
+	ConnectSrc     []string
+	FontSrc        []string
+	ObjectSrc      []string
+	MediaSrc       []string
+	FrameSrc       []string
+	WorkerSrc      []string
+	ManifestSrc    []string
+	FrameAncestors []string
+	BaseURI        []string
+	FormAction     []string
+
+	// UpgradeInsecureRequests instructs browsers to upgrade HTTP requests to HTTPS.
+	UpgradeInsecureRequests bool
+
+	// Nonce enables the per-request nonce in the script-src and style-src directives, if they are set.
+	// The nonce is available by the CSPNonce function and the cspNonce template function.
+	Nonce bool
+
+	// ReportURI represents the URI to send violation reports to, see CSPReportHandler.
+	ReportURI string
+
+	// ReportOnly makes browsers report violations without enforcing the policy.
+	ReportOnly bool
+}
+
+// Header returns the Content-Security-Policy header value with the given nonce.
+func (p CSP) Header(nonce string) string {
+	withNonce := func(sources []string) []string {
+		if !p.Nonce || nonce == "" || len(sources) == 0 {
+			return sources
+		}
+
+		return append(sources[:len(sources):len(sources)], "'nonce-"+nonce+"'")
+	}
+
+	directives := []struct {
+		name    string
+		sources []string
+	}{
+		{"default-src", p.DefaultSrc},
+		{"script-src", withNonce(p.ScriptSrc)},
+		{"style-src", withNonce(p.StyleSrc)},
+		{"img-src", p.ImgSrc},
+		{"connect-src", p.ConnectSrc},
+		{"font-src", p.FontSrc},
+		{"object-src", p.ObjectSrc},
+		{"media-src", p.MediaSrc},
+		{"frame-src", p.FrameSrc},
+		{"worker-src", p.WorkerSrc},
+		{"manifest-src", p.ManifestSrc},
+		{"frame-ancestors", p.FrameAncestors},
+		{"base-uri", p.BaseURI},
+		{"form-action", p.FormAction},
+	}
+
+	policy := make([]string, 0, len(directives)+3)
+
+	for _, d := range directives {
+		if len(d.sources) > 0 {
+			policy = append(policy, d.name+" "+strings.Join(d.sources, " "))
+		}
+	}
+
+	if p.UpgradeInsecureRequests {
+		policy = append(policy, "upgrade-insecure-requests")
+	}
+
+	if p.ReportURI != "" {
+		policy = append(policy, "report-uri "+p.ReportURI, "report-to "+cspReportGroup)
+	}
+
+	return strings.Join(policy, "; ")
+}
+
+// SecurityHeadersOptions represents the options of the SecurityHeadersMiddleware.
+type SecurityHeadersOptions struct {
+	headers http.Header
+	csp     *CSP
+}
+
+// SecurityHeadersOption represents a function type that modifies SecurityHeadersOptions.
+type SecurityHeadersOption func(o *SecurityHeadersOptions)
+
+// WithHSTS sets the Strict-Transport-Security header. Zero maxAge removes the header.
+// Default is 2 years with subdomains included.
+func WithHSTS(maxAge time.Duration, includeSubDomains, preload bool) SecurityHeadersOption {
+	return func(o *SecurityHeadersOptions) {
+		if maxAge <= 0 {
+			o.headers.Del("Strict-Transport-Security")
+			return
+		}
+
+		value := "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+
+		if includeSubDomains {
+			value += "; includeSubDomains"
+		}
+
+		if preload {
+			value += "; preload"
+		}
+
+		o.headers.Set("Strict-Transport-Security", value)
+	}
+}
+
+// WithSecurityHeader sets the header to the given value. Empty value removes the header.
+// It is used to set headers which have no typed option and to override the defaults.
+func WithSecurityHeader(key, value string) SecurityHeadersOption {
+	return func(o *SecurityHeadersOptions) {
+		if value == "" {
+			o.headers.Del(key)
+			return
+		}
+
+		o.headers.Set(key, value)
+	}
+}
+
+// WithReferrerPolicy sets the Referrer-Policy header. Default is "strict-origin-when-cross-origin".
+func WithReferrerPolicy(policy string) SecurityHeadersOption {
+	return WithSecurityHeader("Referrer-Policy", policy)
+}
+
+// WithPermissionsPolicy sets the Permissions-Policy header, e.g. "camera=(), geolocation=(self)".
+func WithPermissionsPolicy(policy string) SecurityHeadersOption {
+	return WithSecurityHeader("Permissions-Policy", policy)
+}
+
+// WithCrossOriginOpenerPolicy sets the Cross-Origin-Opener-Policy header. Default is "same-origin".
+func WithCrossOriginOpenerPolicy(policy string) SecurityHeadersOption {
+	return WithSecurityHeader("Cross-Origin-Opener-Policy", policy)
+}
+
+// WithCrossOriginEmbedderPolicy sets the Cross-Origin-Embedder-Policy header, e.g. "require-corp".
+func WithCrossOriginEmbedderPolicy(policy string) SecurityHeadersOption {
+	return WithSecurityHeader("Cross-Origin-Embedder-Policy", policy)
+}
+
+// WithCSP sets the Content-Security-Policy.
+func WithCSP(policy CSP) SecurityHeadersOption {
+	return func(o *SecurityHeadersOptions) { o.csp = &policy }
+}
+
+// SecurityHeadersMiddleware returns the middleware which sets security related response headers.
+// By default, it sets the Strict-Transport-Security, X-Content-Type-Options, Referrer-Policy
+// and Cross-Origin-Opener-Policy headers. If the CSP has the Nonce enabled, the middleware
+// generates a nonce for every request, which is exposed to templates rendered by TemplateHTML
+// as the cspNonce function, e.g. <script nonce="{{ cspNonce }}">. Templates must be parsed
+// with the TemplateFuncs to use it. Such templates are cloned by the TemplateHTML to bind the
+// nonce, see the FSTemplater which returns the clones of the cached templates.
+func SecurityHeadersMiddleware(options ...SecurityHeadersOption) Middleware {
+	o := SecurityHeadersOptions{
+		headers: http.Header{
+			"Strict-Transport-Security":  {"max-age=63072000; includeSubDomains"},
+			"X-Content-Type-Options":     {"nosniff"},
+			"Referrer-Policy":            {"strict-origin-when-cross-origin"},
+			"Cross-Origin-Opener-Policy": {"same-origin"},
+		},
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	if o.csp != nil && o.csp.ReportURI != "" {
+		o.headers.Set("Reporting-Endpoints", cspReportGroup+`="`+o.csp.ReportURI+`"`)
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if o.csp != nil && o.csp.ReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			for key, values := range o.headers {
+				w.Header()[key] = slices.Clone(values)
+			}
+
+			if o.csp != nil {
+				var nonce string
+
+				if o.csp.Nonce {
+					nonce = newCSPNonce()
+					r = r.WithContext(ctxkit.Set(r.Context(), cspNonceKey, nonce))
+				}
+
+				w.Header().Set(cspHeader, o.csp.Header(nonce))
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CSPNonce returns the Content-Security-Policy nonce of the request from the context.
+// Returns an empty string if the nonce is not enabled.
+func CSPNonce(ctx context.Context) string { return ctxkit.Get[string](ctx, cspNonceKey) }
+
+// TemplateFuncs returns the template functions which are bound to the request by TemplateHTML.
+// Add them to the templates before parsing to use them, e.g. template.New("").Funcs(TemplateFuncs()).
+//   - cspNonce returns the Content-Security-Policy nonce of the request.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"cspNonce": func() string { return "" },
+	}
+}
+
+// requestTemplateFuncs returns the template functions bound to the request.
+func requestTemplateFuncs(ctx context.Context) template.FuncMap {
+	nonce := CSPNonce(ctx)
+
+	return template.FuncMap{
+		"cspNonce": func() string { return nonce },
+	}
+}
+
+// newCSPNonce returns a random nonce with 128 bits of entropy.
+func newCSPNonce() string {
+	b := make([]byte, 16)
+
+	// The rand.Read never returns an error.
+	_, _ = rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// maxCSPReportSize represents the max size of the CSP violation report body.
+const maxCSPReportSize = 64 << 10
+
+// cspViolation represents the CSP violation report fields
+// in both the report-uri and the Reporting API formats.
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	DocumentURL        string `json:"documentURL"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	BlockedURI         string `json:"blocked-uri"`
+	BlockedURL         string `json:"blockedURL"`
+	SourceFile         string `json:"source-file"`
+	SourceFileAPI      string `json:"sourceFile"`
+	LineNumber         int    `json:"line-number"`
+	LineNumberAPI      int    `json:"lineNumber"`
+	Disposition        string `json:"disposition"`
+}
+
+// attrs returns the log attributes of the violation.
+func (v *cspViolation) attrs() []any {
+	return []any{
+		slog.String("document", cmp.Or(v.DocumentURI, v.DocumentURL)),
+		slog.String("directive", cmp.Or(v.ViolatedDirective, v.EffectiveDirective)),
+		slog.String("blocked", cmp.Or(v.BlockedURI, v.BlockedURL)),
+		slog.String("source", cmp.Or(v.SourceFile, v.SourceFileAPI)),
+		slog.Int("line", max(v.LineNumber, v.LineNumberAPI)),
+		slog.String("disposition", v.Disposition),
+	}
+}
+
+// CSPReportHandler returns the handler which logs Content-Security-Policy violation reports
+// sent by browsers to the CSP.ReportURI. Both the legacy application/csp-report and the
+// Reporting API application/reports+json formats are supported.
+func CSPReportHandler(logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportSize))
+		if err != nil {
+			Status(w, r, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var violations []cspViolation
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/reports+json") {
+			var reports []struct {
+				Type string       `json:"type"`
+				Body cspViolation `json:"body"`
+			}
+
+			if err := json.Unmarshal(body, &reports); err != nil {
+				Status(w, r, http.StatusBadRequest)
+				return
+			}
+
+			for _, report := range reports {
+				if report.Type == "csp-violation" {
+					violations = append(violations, report.Body)
+				}
+			}
+		} else {
+			var report struct {
+				Body cspViolation `json:"csp-report"`
+			}
+
+			if err := json.Unmarshal(body, &report); err != nil {
+				Status(w, r, http.StatusBadRequest)
+				return
+			}
+
+			violations = append(violations, report.Body)
+		}
+
+		for _, v := range violations {
+			logger.Warn("CSP violation", v.attrs()...)
+		}
+
+		Status(w, r, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,131 @@
+package httpkit
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+type staticTemplater struct{ templ *template.Template }
+
+func (s *staticTemplater) Template(context.Context, string) (*template.Template, error) {
+	return s.templ, nil
+}
+
+func TestCSP_Header(t *testing.T) {
+	policy := CSP{
+		DefaultSrc:              []string{CSPSelf},
+		ScriptSrc:               []string{CSPSelf, CSPStrictDynamic},
+		ObjectSrc:               []string{CSPNone},
+		UpgradeInsecureRequests: true,
+		Nonce:                   true,
+		ReportURI:               "/csp-report",
+	}
+
+	td.Cmp(t, policy.Header("abc"), "default-src 'self'; script-src 'self' 'strict-dynamic' 'nonce-abc'; "+
+		"object-src 'none'; upgrade-insecure-requests; report-uri /csp-report; report-to csp-endpoint",
+	)
+
+	// Nonce must not leak into the policy source lists.
+	td.Cmp(t, policy.ScriptSrc, []string{CSPSelf, CSPStrictDynamic})
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	prev := htmlTemplater
+	t.Cleanup(func() { htmlTemplater = prev })
+
+	templ := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(`<script nonce="{{ cspNonce }}"></script>`))
+	htmlTemplater = &staticTemplater{templ: templ}
+
+	handler := SecurityHeadersMiddleware(
+		WithHSTS(0, false, false),
+		WithPermissionsPolicy("camera=()"),
+		WithCSP(CSP{ScriptSrc: []string{CSPSelf}, Nonce: true}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		TemplateHTML(w, r, "page", nil)
+	}))
+
+	var nonces []string
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+		td.Cmp(t, w.Header().Get("Strict-Transport-Security"), "")
+		td.Cmp(t, w.Header().Get("X-Content-Type-Options"), "nosniff")
+		td.Cmp(t, w.Header().Get("Permissions-Policy"), "camera=()")
+
+		csp := w.Header().Get("Content-Security-Policy")
+		nonce := strings.TrimSuffix(strings.TrimPrefix(csp, "script-src 'self' 'nonce-"), "'")
+
+		td.Cmp(t, w.Body.String(), `<script nonce="`+nonce+`"></script>`)
+
+		nonces = append(nonces, nonce)
+	}
+
+	td.Cmp(t, nonces[0], td.Not(nonces[1]))
+}
+
+func TestTemplateHTML_executedTemplate(t *testing.T) {
+	prev := htmlTemplater
+	t.Cleanup(func() { htmlTemplater = prev })
+
+	// Templates which have been executed can not be cloned, so they are
+	// served as is to the requests without the CSP nonce.
+	templ := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(`<p>{{ . }}</p>`))
+	td.CmpNoError(t, templ.Execute(io.Discard, "warm"))
+
+	htmlTemplater = &staticTemplater{templ: templ}
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		TemplateHTML(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody), "page", "hello")
+
+		td.Cmp(t, w.Code, http.StatusOK)
+		td.Cmp(t, w.Body.String(), "<p>hello</p>")
+	}
+}
+
+func TestCSPReportHandler(t *testing.T) {
+	var buf bytes.Buffer
+
+	handler := CSPReportHandler(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	f := func(name, contentType, body string, want int) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			buf.Reset()
+
+			r := httptest.NewRequest(http.MethodPost, "/csp-report", strings.NewReader(body))
+			r.Header.Set("Content-Type", contentType)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			td.Cmp(t, w.Code, want)
+
+			if want == http.StatusNoContent {
+				td.Cmp(t, buf.String(), td.Contains("directive=script-src"))
+			}
+		})
+	}
+
+	f("Legacy", "application/csp-report",
+		`{"csp-report":{"document-uri":"https://example.com","violated-directive":"script-src","blocked-uri":"inline"}}`,
+		http.StatusNoContent,
+	)
+	f("ReportingAPI", "application/reports+json",
+		`[{"type":"csp-violation","body":{"documentURL":"https://example.com","effectiveDirective":"script-src"}}]`,
+		http.StatusNoContent,
+	)
+	f("Malformed", "application/csp-report", `{`, http.StatusBadRequest)
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Compilation time check that CookieStore implements the Store.
+var _ Store = (*CookieStore)(nil)
+
+var (
+	// ErrInvalidKey indicates that the CookieStore key has invalid length.
+	ErrInvalidKey = errors.New("session: key must be 16, 24 or 32 bytes long")
+
+	// ErrCookieTooLarge indicates that the encoded session does not fit into the cookie.
+	ErrCookieTooLarge = errors.New("session: encoded session exceeds the cookie size limit")
+)
+
+// maxCookieValueSize represents the max size of the cookie value, which
+// leaves room for the cookie attributes within the 4096 bytes browser limit.
+const maxCookieValueSize = 3800
+
+// CookieStore implements Store interface keeping the whole session in the cookie.
+// The session is encrypted and authenticated with AES-GCM, so it can not be read or
+// modified by the client. Sessions can not be revoked before they expire, use the
+// server-side store if the revocation is required.
+type CookieStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieStore returns a pointer to a new instance of CookieStore.
+// The first key is used to encrypt sessions, and all the keys are used to decrypt them,
+// so the keys can be rotated by prepending a new key and removing the oldest one later.
+func NewCookieStore(key []byte, oldKeys ...[]byte) (*CookieStore, error) {
+	s := CookieStore{
+		aeads: make([]cipher.AEAD, 0, 1+len(oldKeys)),
+	}
+
+	for _, k := range append([][]byte{key}, oldKeys...) {
+		switch len(k) {
+		case 16, 24, 32:
+
+		default:
+			return nil, ErrInvalidKey
+		}
+
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("session: create cipher: %w", err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("session: create gcm: %w", err)
+		}
+
+		s.aeads = append(s.aeads, aead)
+	}
+
+	return &s, nil
+}
+
+func (s *CookieStore) Load(_ context.Context, value string) (*Session, error) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, aead := range s.aeads {
+		if len(ciphertext) < aead.NonceSize() {
+			continue
+		}
+
+		nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+		plaintext, err := aead.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			continue
+		}
+
+		var session Session
+
+		if err := json.Unmarshal(plaintext, &session); err != nil {
+			return nil, fmt.Errorf("session: unmarshal: %w", err)
+		}
+
+		return &session, nil
+	}
+
+	return nil, nil
+}
+
+func (s *CookieStore) Save(_ context.Context, session *Session, _ time.Duration) (string, error) {
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return "", fmt.Errorf("session: marshal: %w", err)
+	}
+
+	aead := s.aeads[0]
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("session: generate nonce: %w", err)
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil))
+	if len(value) > maxCookieValueSize {
+		return "", ErrCookieTooLarge
+	}
+
+	return value, nil
+}
+
+// Delete does nothing, since the cookie sessions are not stored on the server.
+func (*CookieStore) Delete(context.Context, string) error { return nil }
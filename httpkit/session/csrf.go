@@ -0,0 +1,82 @@
+package session
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"net/http"
+
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+const (
+	// CSRFHeader represents the name of the header with the CSRF token.
+	CSRFHeader = "X-CSRF-Token"
+
+	// CSRFField represents the name of the form field with the CSRF token.
+	CSRFField = "csrf_token"
+)
+
+// ErrCSRFTokenInvalid indicates that the request has no valid CSRF token.
+var ErrCSRFTokenInvalid = errors.Join(errkit.ErrUnauthorized, errors.New("csrf token is invalid"))
+
+// CSRFMiddleware returns the middleware which protects from the cross-site request forgery
+// with the synchronizer token pattern. The token is kept in the session, and must be sent back
+// in the X-CSRF-Token header or the csrf_token form field with every request which method is not
+// GET, HEAD, OPTIONS or TRACE. Such requests without a valid token are rejected with 403.
+// It must be mounted after the Manager.Middleware.
+func CSRFMiddleware() httpkit.Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			s := FromContext(r.Context())
+			if s == nil {
+				httpkit.ErrorHTTP(w, r, ErrCSRFTokenInvalid, httpkit.WithStatus(http.StatusForbidden))
+				return
+			}
+
+			s.mu.Lock()
+			want := s.data.CSRFToken
+			s.mu.Unlock()
+
+			got := r.Header.Get(CSRFHeader)
+			if got == "" {
+				got = r.PostFormValue(CSRFField)
+			}
+
+			if want == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+				httpkit.ErrorHTTP(w, r, ErrCSRFTokenInvalid, httpkit.WithStatus(http.StatusForbidden))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CSRFToken returns the CSRF token of the session from the context and generates it if needed.
+// Pass the token to the templates to render it in forms or in the meta tag for scripts.
+// Returns an empty string if the context has no session.
+func CSRFToken(ctx context.Context) string {
+	s := FromContext(ctx)
+	if s == nil {
+		return ""
+	}
+
+	return s.csrfToken()
+}
+
+// CSRFTemplateField returns the hidden form input with the CSRF token of the session from the context.
+func CSRFTemplateField(ctx context.Context) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + CSRFField + `" value="` + //nolint:gosec // Token is URL-safe base64.
+		template.HTMLEscapeString(CSRFToken(ctx)) + `">`)
+}
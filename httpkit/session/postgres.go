@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/plainq/servekit/dbkit/pgkit"
+)
+
+// Compilation time check that PostgresStore implements the Store.
+var _ Store = (*PostgresStore)(nil)
+
+const postgresSchema = `
+create table if not exists sessions
+(
+    id         text        not null primary key,
+    data       jsonb       not null,
+    expires_at timestamptz not null
+);
+
+create index if not exists sessions_expires_at_idx on sessions (expires_at);`
+
+// PostgresStore implements Store interface on top of the pgkit.Conn.
+// Expired sessions are deleted on access and by the DeleteExpired.
+type PostgresStore struct {
+	conn *pgkit.Conn
+	now  func() time.Time
+}
+
+// NewPostgresStore returns a pointer to a new instance of PostgresStore.
+// Creates the sessions table if it does not exist.
+func NewPostgresStore(conn *pgkit.Conn) (*PostgresStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, postgresSchema); err != nil {
+		return nil, fmt.Errorf("postgres: create sessions table: %w", err)
+	}
+
+	s := PostgresStore{
+		conn: conn,
+		now:  time.Now,
+	}
+
+	return &s, nil
+}
+
+func (s *PostgresStore) Load(ctx context.Context, value string) (*Session, error) {
+	const query = `select data from sessions where id = $1 and expires_at > $2;`
+
+	var data []byte
+
+	if err := s.conn.QueryRow(ctx, query, value, s.now()).Scan(&data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("postgres: load session: %w", err)
+	}
+
+	var session Session
+
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("postgres: unmarshal session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (s *PostgresStore) Save(ctx context.Context, session *Session, ttl time.Duration) (string, error) {
+	const query = `
+		insert into sessions (id, data, expires_at)
+		values ($1, $2, $3)
+		on conflict (id) do update
+		    set data       = excluded.data,
+		        expires_at = excluded.expires_at;`
+
+	data, marshalErr := json.Marshal(session)
+	if marshalErr != nil {
+		return "", fmt.Errorf("postgres: marshal session: %w", marshalErr)
+	}
+
+	id := session.ID()
+
+	if _, err := s.conn.Exec(ctx, query, id, data, s.now().Add(ttl)); err != nil {
+		return "", fmt.Errorf("postgres: save session: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, id string) error {
+	const query = `delete from sessions where id = $1;`
+
+	if _, err := s.conn.Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("postgres: delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes all the expired sessions. It should be called periodically.
+func (s *PostgresStore) DeleteExpired(ctx context.Context) error {
+	const query = `delete from sessions where expires_at <= $1;`
+
+	if _, err := s.conn.Exec(ctx, query, s.now()); err != nil {
+		return fmt.Errorf("postgres: delete expired sessions: %w", err)
+	}
+
+	return nil
+}
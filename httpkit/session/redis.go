@@ -0,0 +1,67 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	redisconn "github.com/plainq/servekit/dbkit/rediskit"
+	"github.com/redis/go-redis/v9"
+)
+
+// Compilation time check that RedisStore implements the Store.
+var _ Store = (*RedisStore)(nil)
+
+// redisKeyPrefix represents the prefix of the session keys in Redis.
+const redisKeyPrefix = "session:"
+
+// RedisStore implements Store interface on top of the rediskit.Conn.
+// Sessions expiration relies on Redis keys TTL.
+type RedisStore struct{ conn *redisconn.Conn }
+
+// NewRedisStore returns a pointer to a new instance of RedisStore.
+func NewRedisStore(conn *redisconn.Conn) *RedisStore { return &RedisStore{conn: conn} }
+
+func (s *RedisStore) Load(ctx context.Context, value string) (*Session, error) {
+	data, err := s.conn.Get(ctx, redisKeyPrefix+value).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("redis: load session: %w", err)
+	}
+
+	var session Session
+
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("redis: unmarshal session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, session *Session, ttl time.Duration) (string, error) {
+	data, marshalErr := json.Marshal(session)
+	if marshalErr != nil {
+		return "", fmt.Errorf("redis: marshal session: %w", marshalErr)
+	}
+
+	id := session.ID()
+
+	if err := s.conn.Set(ctx, redisKeyPrefix+id, data, ttl).Err(); err != nil {
+		return "", fmt.Errorf("redis: save session: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	if err := s.conn.Del(ctx, redisKeyPrefix+id).Err(); err != nil {
+		return fmt.Errorf("redis: delete session: %w", err)
+	}
+
+	return nil
+}
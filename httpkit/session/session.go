@@ -0,0 +1,409 @@
+// Package session provides HTTP sessions on top of the httpkit.
+//
+// Sessions are either stateless, when the whole session is kept in the signed and
+// encrypted cookie (see CookieStore), or server-side, when the cookie holds only the
+// session ID and the session data is kept in Redis, SQLite or PostgreSQL.
+// Sessions expire after the idle timeout since the last request and after the absolute
+// timeout since creation, whichever comes first. The session ID must be renewed on
+// every privilege change, e.g. on login, to prevent session fixation.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+// sessionKey represents a Key for context by which
+// the Session can be received from the context.
+const sessionKey ctxkit.Key = "ctx.session"
+
+// Store holds the logic of storing sessions.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns the session by the cookie value.
+	// Returns nil if the session does not exist or the value is not valid.
+	Load(ctx context.Context, value string) (*Session, error)
+
+	// Save saves the session for the given ttl and returns the cookie value.
+	Save(ctx context.Context, s *Session, ttl time.Duration) (string, error)
+
+	// Delete deletes the session by the given ID.
+	Delete(ctx context.Context, id string) error
+}
+
+// Session represents the HTTP session. It is safe for concurrent use.
+type Session struct {
+	mu   sync.Mutex
+	data sessionData
+
+	// prevID holds the persisted ID of the session which ID has been renewed.
+	prevID string
+
+	// persisted indicates that the session has been loaded from the Store.
+	persisted bool
+	modified  bool
+	destroyed bool
+}
+
+// sessionData represents the serializable state of the Session.
+type sessionData struct {
+	ID         string            `json:"id"`
+	Values     map[string]string `json:"values,omitempty"`
+	CSRFToken  string            `json:"csrf_token,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	LastSeenAt time.Time         `json:"last_seen_at"`
+}
+
+// newSession returns a pointer to a new instance of Session.
+func newSession(now time.Time) *Session {
+	s := Session{
+		data: sessionData{
+			ID:         randomString(),
+			Values:     make(map[string]string),
+			CreatedAt:  now,
+			LastSeenAt: now,
+		},
+	}
+
+	return &s
+}
+
+// ID returns the session ID.
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.ID
+}
+
+// CreatedAt returns the session creation time.
+func (s *Session) CreatedAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.CreatedAt
+}
+
+// Get returns the session value by the given key.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.data.Values[key]
+}
+
+// Set sets the session value by the given key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]string)
+	}
+
+	s.data.Values[key] = value
+	s.modified = true
+}
+
+// Delete deletes the session value by the given key.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.data.Values, key)
+	s.modified = true
+}
+
+// RenewID assigns a new ID to the session keeping its values, and regenerates the CSRF token.
+// It must be called on every privilege change, e.g. on login or logout, to prevent session fixation.
+func (s *Session) RenewID() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.persisted && s.prevID == "" {
+		s.prevID = s.data.ID
+	}
+
+	s.data.ID = randomString()
+
+	if s.data.CSRFToken != "" {
+		s.data.CSRFToken = randomString()
+	}
+
+	s.modified = true
+}
+
+// Destroy deletes the session from the Store and expires the session cookie.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.destroyed = true
+}
+
+// MarshalJSON implements json.Marshaler. It is used by the Store implementations.
+func (s *Session) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return json.Marshal(s.data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is used by the Store implementations.
+func (s *Session) UnmarshalJSON(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return json.Unmarshal(data, &s.data)
+}
+
+// csrfToken returns the CSRF token of the session and generates it if needed.
+func (s *Session) csrfToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data.CSRFToken == "" {
+		s.data.CSRFToken = randomString()
+		s.modified = true
+	}
+
+	return s.data.CSRFToken
+}
+
+// FromContext returns the Session from the context.
+// Returns nil if the context has no session, i.e. the Manager.Middleware is not mounted.
+func FromContext(ctx context.Context) *Session { return ctxkit.Get[*Session](ctx, sessionKey) }
+
+// Option represents a function type that modifies the Manager.
+type Option func(m *Manager)
+
+// WithCookie sets the template of the session cookie. Only Name, Path, Domain, Secure,
+// HttpOnly, SameSite and Partitioned fields are used. Default cookie is named "session",
+// has the "/" path, and is Secure, HttpOnly and SameSite=Lax.
+func WithCookie(cookie http.Cookie) Option {
+	return func(m *Manager) { m.cookie = cookie }
+}
+
+// WithIdleTimeout sets the time after which the session expires if there are no requests.
+// Default is 30 minutes.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(m *Manager) { m.idleTimeout = timeout }
+}
+
+// WithAbsoluteTimeout sets the time after which the session expires regardless of the activity.
+// Default is 12 hours.
+func WithAbsoluteTimeout(timeout time.Duration) Option {
+	return func(m *Manager) { m.absoluteTimeout = timeout }
+}
+
+// Manager loads and saves sessions of HTTP requests.
+type Manager struct {
+	store           Store
+	cookie          http.Cookie
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+	now             func() time.Time
+}
+
+// NewManager returns a pointer to a new instance of Manager.
+func NewManager(store Store, options ...Option) *Manager {
+	m := Manager{
+		store: store,
+		cookie: http.Cookie{
+			Name:     "session",
+			Path:     "/",
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		idleTimeout:     30 * time.Minute,
+		absoluteTimeout: 12 * time.Hour,
+		now:             time.Now,
+	}
+
+	for _, option := range options {
+		option(&m)
+	}
+
+	return &m
+}
+
+// Middleware returns the middleware which loads the session of the request and puts it
+// to the request context. The session is saved before the response headers are written.
+// Sessions without values are not saved, so anonymous visitors do not create sessions.
+func (m *Manager) Middleware() httpkit.Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			s := m.load(r)
+
+			sw := sessionWriter{
+				ResponseWriter: w,
+				commit:         func() { m.save(w, r, s) },
+			}
+
+			next.ServeHTTP(&sw, r.WithContext(ctxkit.Set(r.Context(), sessionKey, s)))
+
+			sw.commitOnce()
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// load loads the session of the request, or returns a new one if the
+// request has no session, or it is expired.
+func (m *Manager) load(r *http.Request) *Session {
+	now := m.now()
+
+	cookie, err := r.Cookie(m.cookie.Name)
+	if err != nil {
+		return newSession(now)
+	}
+
+	s, loadErr := m.store.Load(r.Context(), cookie.Value)
+	if loadErr != nil {
+		logError(r.Context(), fmt.Errorf("session: load: %w", loadErr))
+		return newSession(now)
+	}
+
+	if s == nil {
+		return newSession(now)
+	}
+
+	if now.Sub(s.data.LastSeenAt) >= m.idleTimeout || now.Sub(s.data.CreatedAt) >= m.absoluteTimeout {
+		if err := m.store.Delete(r.Context(), s.data.ID); err != nil {
+			logError(r.Context(), fmt.Errorf("session: delete expired: %w", err))
+		}
+
+		return newSession(now)
+	}
+
+	s.persisted = true
+
+	return s
+}
+
+// save saves the session and sets the session cookie to the response.
+func (m *Manager) save(w http.ResponseWriter, r *http.Request, s *Session) {
+	now := m.now()
+
+	s.mu.Lock()
+
+	var (
+		id        = s.data.ID
+		prevID    = s.prevID
+		destroyed = s.destroyed
+		empty     = len(s.data.Values) == 0 && s.data.CSRFToken == ""
+		touch     = s.modified || now.Sub(s.data.LastSeenAt) >= m.idleTimeout/10
+	)
+
+	if touch {
+		s.data.LastSeenAt = now
+	}
+
+	expiresAt := s.data.LastSeenAt.Add(m.idleTimeout)
+	if absolute := s.data.CreatedAt.Add(m.absoluteTimeout); absolute.Before(expiresAt) {
+		expiresAt = absolute
+	}
+
+	s.mu.Unlock()
+
+	if prevID != "" {
+		if err := m.store.Delete(r.Context(), prevID); err != nil {
+			logError(r.Context(), fmt.Errorf("session: delete renewed: %w", err))
+		}
+	}
+
+	switch {
+	case destroyed:
+		if s.persisted || prevID != "" {
+			if err := m.store.Delete(r.Context(), id); err != nil {
+				logError(r.Context(), fmt.Errorf("session: destroy: %w", err))
+			}
+
+			cookie := m.cookie
+			cookie.MaxAge = -1
+			http.SetCookie(w, &cookie)
+		}
+
+	case !touch, empty && !s.persisted:
+		return
+
+	default:
+		value, err := m.store.Save(r.Context(), s, expiresAt.Sub(now))
+		if err != nil {
+			logError(r.Context(), fmt.Errorf("session: save: %w", err))
+			return
+		}
+
+		cookie := m.cookie
+		cookie.Value = value
+		cookie.Expires = expiresAt
+		http.SetCookie(w, &cookie)
+	}
+}
+
+// sessionWriter wraps the http.ResponseWriter to save
+// the session before the response headers are written.
+type sessionWriter struct {
+	http.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+func (w *sessionWriter) WriteHeader(statusCode int) {
+	w.commitOnce()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.commitOnce()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (w *sessionWriter) Flush() {
+	w.commitOnce()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying http.ResponseWriter for the http.ResponseController.
+func (w *sessionWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *sessionWriter) commitOnce() {
+	if w.committed {
+		return
+	}
+
+	w.committed = true
+	w.commit()
+}
+
+// logError passes the error to the access log hook.
+func logError(ctx context.Context, err error) {
+	if hook := ctxkit.GetLogErrHook(ctx); hook != nil {
+		hook(err)
+	}
+}
+
+// randomString returns a random URL-safe string with 256 bits of entropy.
+func randomString() string {
+	b := make([]byte, 32)
+
+	// The rand.Read never returns an error.
+	_, _ = rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,165 @@
+package session
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// testClient keeps the session cookie between requests.
+type testClient struct {
+	t       *testing.T
+	handler http.Handler
+	cookie  *http.Cookie
+}
+
+func (c *testClient) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	c.t.Helper()
+
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+
+	r := httptest.NewRequest(method, path, body)
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	if c.cookie != nil {
+		r.AddCookie(c.cookie)
+	}
+
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+			continue
+		}
+
+		c.cookie = cookie
+	}
+
+	return w
+}
+
+func newTestHandler(m *Manager) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /whoami", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(FromContext(r.Context()).Get("user")))
+	})
+
+	mux.HandleFunc("GET /form", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(CSRFToken(r.Context())))
+	})
+
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		s := FromContext(r.Context())
+		s.RenewID()
+		s.Set("user", r.PostFormValue("user"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Destroy()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return m.Middleware()(CSRFMiddleware()(mux))
+}
+
+func TestManager(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	store, err := NewCookieStore(key)
+	td.Require(t).CmpNoError(err)
+
+	now := time.Now()
+
+	m := NewManager(store, WithIdleTimeout(time.Hour), WithAbsoluteTimeout(2*time.Hour))
+	m.now = func() time.Time { return now }
+
+	c := testClient{t: t, handler: newTestHandler(m)}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "")
+		td.CmpNil(t, c.cookie)
+	})
+
+	t.Run("CSRF", func(t *testing.T) {
+		td.Cmp(t, c.do(http.MethodPost, "/login", url.Values{"user": {"alice"}}).Code, http.StatusForbidden)
+
+		token := c.do(http.MethodGet, "/form", nil).Body.String()
+		td.Require(t).NotNil(c.cookie)
+
+		td.Cmp(t, c.do(http.MethodPost, "/login", url.Values{"user": {"alice"}, CSRFField: {"wrong"}}).Code, http.StatusForbidden)
+		td.Cmp(t, c.do(http.MethodPost, "/login", url.Values{"user": {"alice"}, CSRFField: {token}}).Code, http.StatusNoContent)
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+		// Token is regenerated on the session ID renewal.
+		td.Cmp(t, c.do(http.MethodGet, "/form", nil).Body.String(), td.Not(token))
+	})
+
+	t.Run("KeyRotation", func(t *testing.T) {
+		rotated, err := NewCookieStore(bytes.Repeat([]byte{2}, 32), key)
+		td.Require(t).CmpNoError(err)
+
+		m.store = rotated
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+		m.store, err = NewCookieStore(bytes.Repeat([]byte{3}, 32))
+		td.Require(t).CmpNoError(err)
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "")
+
+		m.store = rotated
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+		now = now.Add(59 * time.Minute)
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+		now = now.Add(59 * time.Minute)
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+		// Absolute timeout is reached regardless of the activity.
+		now = now.Add(5 * time.Minute)
+		td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "")
+	})
+}
+
+func TestNewCookieStore(t *testing.T) {
+	_, err := NewCookieStore([]byte("short"))
+	td.CmpErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestCookieStore_tampered(t *testing.T) {
+	store, err := NewCookieStore(bytes.Repeat([]byte{1}, 16))
+	td.Require(t).CmpNoError(err)
+
+	value, err := store.Save(t.Context(), newSession(time.Now()), time.Hour)
+	td.Require(t).CmpNoError(err)
+
+	s, err := store.Load(t.Context(), value)
+	td.CmpNoError(t, err)
+	td.CmpNotNil(t, s)
+
+	tampered := []byte(value)
+	tampered[len(tampered)/2] ^= 1
+
+	s, err = store.Load(t.Context(), string(tampered))
+	td.CmpNoError(t, err)
+	td.CmpNil(t, s)
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/plainq/servekit/dbkit/litekit"
+)
+
+// Compilation time check that SQLiteStore implements the Store.
+var _ Store = (*SQLiteStore)(nil)
+
+const sqliteSchema = `
+create table if not exists sessions
+(
+    id         text    not null primary key,
+    data       text    not null,
+    expires_at integer not null
+);
+
+create index if not exists sessions_expires_at_idx on sessions (expires_at);`
+
+// SQLiteStore implements Store interface on top of the litekit.Conn.
+// Expired sessions are deleted on access and by the DeleteExpired.
+type SQLiteStore struct {
+	conn *litekit.Conn
+	now  func() time.Time
+}
+
+// NewSQLiteStore returns a pointer to a new instance of SQLiteStore.
+// Creates the sessions table if it does not exist.
+func NewSQLiteStore(conn *litekit.Conn) (*SQLiteStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := conn.ExecContext(ctx, sqliteSchema); err != nil {
+		return nil, fmt.Errorf("sqlite: create sessions table: %w", err)
+	}
+
+	s := SQLiteStore{
+		conn: conn,
+		now:  time.Now,
+	}
+
+	return &s, nil
+}
+
+func (s *SQLiteStore) Load(ctx context.Context, value string) (*Session, error) {
+	const query = `select data from sessions where id = ? and expires_at > ?;`
+
+	var data string
+
+	if err := s.conn.QueryRowContext(ctx, query, value, s.now().UnixNano()).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("sqlite: load session: %w", err)
+	}
+
+	var session Session
+
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return nil, fmt.Errorf("sqlite: unmarshal session: %w", err)
+	}
+
+	return &session, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, session *Session, ttl time.Duration) (string, error) {
+	const query = `
+		insert into sessions (id, data, expires_at)
+		values (?, ?, ?)
+		on conflict (id) do update
+		    set data       = excluded.data,
+		        expires_at = excluded.expires_at;`
+
+	data, marshalErr := json.Marshal(session)
+	if marshalErr != nil {
+		return "", fmt.Errorf("sqlite: marshal session: %w", marshalErr)
+	}
+
+	id := session.ID()
+
+	if _, err := s.conn.ExecContext(ctx, query, id, string(data), s.now().Add(ttl).UnixNano()); err != nil {
+		return "", fmt.Errorf("sqlite: save session: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	const query = `delete from sessions where id = ?;`
+
+	if _, err := s.conn.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("sqlite: delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes all the expired sessions. It should be called periodically.
+func (s *SQLiteStore) DeleteExpired(ctx context.Context) error {
+	const query = `delete from sessions where expires_at <= ?;`
+
+	if _, err := s.conn.ExecContext(ctx, query, s.now().UnixNano()); err != nil {
+		return fmt.Errorf("sqlite: delete expired sessions: %w", err)
+	}
+
+	return nil
+}
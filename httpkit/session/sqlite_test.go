@@ -0,0 +1,49 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/dbkit/litekit"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	conn, err := litekit.New(filepath.Join(t.TempDir(), "test.db"))
+	td.Require(t).CmpNoError(err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	store, err := NewSQLiteStore(conn)
+	td.Require(t).CmpNoError(err)
+
+	c := testClient{t: t, handler: newTestHandler(NewManager(store))}
+
+	token := c.do(http.MethodGet, "/form", nil).Body.String()
+	td.Require(t).NotNil(c.cookie)
+
+	anonymousID := c.cookie.Value
+
+	td.Cmp(t, c.do(http.MethodPost, "/login", url.Values{"user": {"alice"}, CSRFField: {token}}).Code, http.StatusNoContent)
+	td.Cmp(t, c.cookie.Value, td.Not(anonymousID))
+	td.Cmp(t, c.do(http.MethodGet, "/whoami", nil).Body.String(), "alice")
+
+	// The session ID before the login must not be valid anymore.
+	s, err := store.Load(t.Context(), anonymousID)
+	td.CmpNoError(t, err)
+	td.CmpNil(t, s)
+
+	loggedInID := c.cookie.Value
+	token = c.do(http.MethodGet, "/form", nil).Body.String()
+
+	td.Cmp(t, c.do(http.MethodPost, "/logout", url.Values{CSRFField: {token}}).Code, http.StatusNoContent)
+	td.CmpNil(t, c.cookie)
+
+	s, err = store.Load(t.Context(), loggedInID)
+	td.CmpNoError(t, err)
+	td.CmpNil(t, s)
+
+	td.CmpNoError(t, store.DeleteExpired(t.Context()))
+}
@@ -0,0 +1,300 @@
+package httpkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// sseHeartbeatInterval represents default interval between SSE heartbeats.
+	sseHeartbeatInterval = 15 * time.Second
+
+	// sseWriteTimeout represents default timeout of a single SSE write.
+	sseWriteTimeout = 10 * time.Second
+)
+
+// ErrStreamingUnsupported indicates that the http.ResponseWriter does not support flushing.
+var ErrStreamingUnsupported = errors.New("streaming is not supported by the response writer")
+
+// SSEEvent represents a single Server-Sent Event.
+// See: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type SSEEvent struct {
+	// ID represents the event ID which the client sends back
+	// in the Last-Event-ID header when it reconnects.
+	ID string
+
+	// Event represents the event type. The client dispatches
+	// events without a type as "message" events.
+	Event string
+
+	// Data represents the event payload. Strings and byte slices
+	// are written as is, other values are encoded to JSON.
+	Data any
+
+	// Retry represents the reconnection time the client should use.
+	Retry time.Duration
+}
+
+// SSEOption represents a function which configures the SSE stream.
+type SSEOption func(s *SSE)
+
+// WithSSEHeartbeat sets the interval between heartbeat comments which keep the
+// connection open through proxies. Zero interval disables heartbeats.
+func WithSSEHeartbeat(interval time.Duration) SSEOption {
+	return func(s *SSE) { s.heartbeat = interval }
+}
+
+// WithSSEWriteTimeout sets the timeout of a single event write,
+// after which the client is considered gone.
+func WithSSEWriteTimeout(timeout time.Duration) SSEOption {
+	return func(s *SSE) { s.writeTimeout = timeout }
+}
+
+// WithSSEReplay sets the replay buffer which is used to resend the events missed
+// by the client since the Last-Event-ID. The buffer is filled by the publisher.
+func WithSSEReplay(buffer *SSEReplayBuffer) SSEOption {
+	return func(s *SSE) { s.replay = buffer }
+}
+
+// SSE represents a Server-Sent Events stream.
+type SSE struct {
+	mu sync.Mutex
+
+	w  http.ResponseWriter
+	r  *http.Request
+	rc *http.ResponseController
+
+	lastEventID  string
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+	replay       *SSEReplayBuffer
+}
+
+// NewSSE sets the stream headers, disables the ListenerHTTP write timeout for the
+// response and returns a pointer to a new instance of SSE. After that, the response
+// is committed and the errors should not be written by the error responders.
+func NewSSE(w http.ResponseWriter, r *http.Request, options ...SSEOption) (*SSE, error) {
+	s := SSE{
+		w:            w,
+		r:            r,
+		rc:           http.NewResponseController(w),
+		lastEventID:  r.Header.Get("Last-Event-ID"),
+		heartbeat:    sseHeartbeatInterval,
+		writeTimeout: sseWriteTimeout,
+	}
+
+	for _, option := range options {
+		option(&s)
+	}
+
+	// The server-wide write timeout is replaced by the per write timeout.
+	if err := s.rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, fmt.Errorf("reset write deadline: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := s.rc.Flush(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStreamingUnsupported, err)
+	}
+
+	return &s, nil
+}
+
+// LastEventID returns the ID of the last event received by the client before it reconnected.
+func (s *SSE) LastEventID() string { return s.lastEventID }
+
+// Send writes the event to the stream and flushes it to the client.
+func (s *SSE) Send(e SSEEvent) error {
+	var buf bytes.Buffer
+
+	if err := encodeEvent(&buf, e); err != nil {
+		return err
+	}
+
+	return s.write(buf.Bytes())
+}
+
+// Stream sends the events missed since the Last-Event-ID from the replay buffer, then sends
+// events from the channel and heartbeats until the channel is closed, the client disconnects,
+// or the ListenerHTTP shuts down. Returns nil in these cases and the error if writing fails.
+func (s *SSE) Stream(events <-chan SSEEvent) error {
+	if s.replay != nil && s.lastEventID != "" {
+		for _, e := range s.replay.Since(s.lastEventID) {
+			if err := s.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	var heartbeat <-chan time.Time
+
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+
+		heartbeat = ticker.C
+	}
+
+	shutdown := ShutdownSignal(s.r.Context())
+
+	for {
+		select {
+		case <-s.r.Context().Done():
+			return nil
+
+		case <-shutdown:
+			return nil
+
+		case <-heartbeat:
+			if err := s.write([]byte(": heartbeat\n\n")); err != nil {
+				return err
+			}
+
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if err := s.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *SSE) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writeTimeout > 0 {
+		if err := s.rc.SetWriteDeadline(time.Now().Add(s.writeTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("set write deadline: %w", err)
+		}
+	}
+
+	if _, err := s.w.Write(p); err != nil {
+		return fmt.Errorf("write event: %w", err)
+	}
+
+	if err := s.rc.Flush(); err != nil {
+		return fmt.Errorf("flush event: %w", err)
+	}
+
+	return nil
+}
+
+// encodeEvent writes the event in the text/event-stream format to the buffer.
+func encodeEvent(buf *bytes.Buffer, e SSEEvent) error {
+	if e.ID != "" {
+		buf.WriteString("id: " + sanitizeEventField(e.ID) + "\n")
+	}
+
+	if e.Event != "" {
+		buf.WriteString("event: " + sanitizeEventField(e.Event) + "\n")
+	}
+
+	if e.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	var data string
+
+	switch v := e.Data.(type) {
+	case nil:
+
+	case string:
+		data = v
+
+	case []byte:
+		data = string(v)
+
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encode event data: %w", err)
+		}
+
+		data = string(b)
+	}
+
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+
+	buf.WriteString("\n")
+
+	return nil
+}
+
+// sanitizeEventField removes line breaks which would break the event framing.
+func sanitizeEventField(v string) string {
+	return strings.NewReplacer("\n", "", "\r", "").Replace(v)
+}
+
+// SSEReplayBuffer represents a bounded buffer of the recent events, which
+// is used to resend events missed by the reconnected clients. Publishers
+// add each event with ID to the buffer before sending it to the streams.
+// It is safe for concurrent use and can be shared between streams.
+type SSEReplayBuffer struct {
+	mu     sync.RWMutex
+	events []SSEEvent
+	size   int
+}
+
+// NewSSEReplayBuffer returns a pointer to a new instance of SSEReplayBuffer
+// which keeps up to size recent events.
+func NewSSEReplayBuffer(size int) *SSEReplayBuffer {
+	return &SSEReplayBuffer{
+		events: make([]SSEEvent, 0, max(size, 0)),
+		size:   size,
+	}
+}
+
+// Add adds the event to the buffer evicting the oldest one if the buffer is full.
+func (b *SSEReplayBuffer) Add(e SSEEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.size <= 0 {
+		return
+	}
+
+	if len(b.events) == b.size {
+		copy(b.events, b.events[1:])
+		b.events = b.events[:len(b.events)-1]
+	}
+
+	b.events = append(b.events, e)
+}
+
+// Since returns the events added after the event with the given ID.
+// Returns nil if the event with the given ID is not in the buffer anymore.
+func (b *SSEReplayBuffer) Since(id string) []SSEEvent {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for i := len(b.events) - 1; i >= 0; i-- {
+		if b.events[i].ID == id {
+			events := make([]SSEEvent, len(b.events)-i-1)
+			copy(events, b.events[i+1:])
+
+			return events
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package httpkit
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestEncodeEvent(t *testing.T) {
+	f := func(name string, e SSEEvent, want string) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			td.CmpNoError(t, encodeEvent(&buf, e))
+			td.Cmp(t, buf.String(), want)
+		})
+	}
+
+	f("DataOnly", SSEEvent{Data: "hello"}, "data: hello\n\n")
+	f("AllFields", SSEEvent{ID: "1", Event: "progress", Data: []byte("50"), Retry: 3 * time.Second},
+		"id: 1\nevent: progress\nretry: 3000\ndata: 50\n\n",
+	)
+	f("Multiline", SSEEvent{Data: "a\r\nb\nc"}, "data: a\ndata: b\ndata: c\n\n")
+	f("JSON", SSEEvent{Data: map[string]int{"done": 1}}, "data: {\"done\":1}\n\n")
+	f("SanitizedFields", SSEEvent{ID: "1\n2", Event: "a\rb", Data: "x"}, "id: 12\nevent: ab\ndata: x\n\n")
+}
+
+func TestSSEReplayBuffer(t *testing.T) {
+	buffer := NewSSEReplayBuffer(3)
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		buffer.Add(SSEEvent{ID: id})
+	}
+
+	td.Cmp(t, buffer.Since("1"), td.Nil())
+	td.Cmp(t, buffer.Since("2"), []SSEEvent{{ID: "3"}, {ID: "4"}})
+	td.Cmp(t, buffer.Since("4"), []SSEEvent{})
+}
+
+func TestSSE_Stream(t *testing.T) {
+	buffer := NewSSEReplayBuffer(10)
+	buffer.Add(SSEEvent{ID: "1", Data: "one"})
+	buffer.Add(SSEEvent{ID: "2", Data: "two"})
+
+	r := httptest.NewRequest(http.MethodGet, "/events", http.NoBody)
+	r.Header.Set("Last-Event-ID", "1")
+
+	w := httptest.NewRecorder()
+
+	stream, err := NewSSE(w, r, WithSSEReplay(buffer), WithSSEHeartbeat(0))
+	td.Require(t).CmpNoError(err)
+
+	td.Cmp(t, stream.LastEventID(), "1")
+	td.Cmp(t, w.Header().Get("Content-Type"), "text/event-stream")
+
+	events := make(chan SSEEvent, 1)
+	events <- SSEEvent{ID: "3", Data: "three"}
+	close(events)
+
+	td.CmpNoError(t, stream.Stream(events))
+	td.Cmp(t, w.Body.String(), "id: 2\ndata: two\n\nid: 3\ndata: three\n\n")
+}
+
+func TestSSE_Stream_shutdown(t *testing.T) {
+	shutdown := make(chan struct{})
+
+	ctx := withShutdownSignal(context.Background(), shutdown)
+	r := httptest.NewRequestWithContext(ctx, http.MethodGet, "/events", http.NoBody)
+
+	stream, err := NewSSE(httptest.NewRecorder(), r)
+	td.Require(t).CmpNoError(err)
+
+	done := make(chan error)
+
+	go func() { done <- stream.Stream(make(chan SSEEvent)) }()
+
+	close(shutdown)
+
+	select {
+	case err := <-done:
+		td.CmpNoError(t, err)
+
+	case <-time.After(time.Second):
+		t.Fatal("stream has not been stopped on shutdown")
+	}
+}
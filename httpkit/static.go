@@ -0,0 +1,301 @@
+package httpkit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/plainq/servekit/errkit"
+)
+
+// immutableCacheControl represents the Cache-Control of the fingerprinted assets, which never change.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// StaticOptions represents the options of the Static handler.
+type StaticOptions struct {
+	prefix      string
+	spa         bool
+	fingerprint bool
+	maxAge      time.Duration
+	immutable   *regexp.Regexp
+}
+
+// StaticOption represents a function type that modifies StaticOptions.
+type StaticOption func(o *StaticOptions)
+
+// WithStaticPrefix sets the path the Static handler is mounted on, which is used to build the asset URLs.
+func WithStaticPrefix(prefix string) StaticOption {
+	return func(o *StaticOptions) { o.prefix = strings.TrimSuffix(prefix, "/") }
+}
+
+// WithSPAFallback enables the fallback to the root index.html for the paths which do not exist
+// and have no file extension, so the client-side routes of single-page apps can be reloaded.
+func WithSPAFallback() StaticOption {
+	return func(o *StaticOptions) { o.spa = true }
+}
+
+// WithFingerprinting enables the content hash in the asset URLs built by the AssetURL, e.g. app.3f2a1b9c.js,
+// which are served with the immutable Cache-Control, so browsers never revalidate them.
+func WithFingerprinting() StaticOption {
+	return func(o *StaticOptions) { o.fingerprint = true }
+}
+
+// WithStaticMaxAge sets the max-age of the Cache-Control of the assets which are not fingerprinted.
+// Default is zero, so browsers revalidate them with the ETag.
+func WithStaticMaxAge(maxAge time.Duration) StaticOption {
+	return func(o *StaticOptions) { o.maxAge = maxAge }
+}
+
+// WithImmutablePattern sets the pattern of the file names which are fingerprinted by the bundler
+// and must be served with the immutable Cache-Control. Default matches the hex hash before
+// the extension, e.g. app.3f2a1b9c.js or app-3f2a1b9c.js.
+func WithImmutablePattern(pattern *regexp.Regexp) StaticOption {
+	return func(o *StaticOptions) { o.immutable = pattern }
+}
+
+// staticFile represents the served file.
+type staticFile struct {
+	name string
+	hash string
+}
+
+// Static represents the handler which serves the files of the fs.FS, e.g. the embed.FS
+// with the admin UI. Directory listing is disabled, the directories are served by their
+// index.html. If the client accepts it, the precompressed .br or .gz variant of the file
+// is served with the Content-Encoding.
+type Static struct {
+	fsys  fs.FS
+	opts  StaticOptions
+	files map[string]staticFile
+
+	// hashed maps the fingerprinted names to the file names.
+	hashed map[string]string
+}
+
+// NewStatic returns a pointer to a new instance of Static. The files are hashed
+// on creation to build the ETags and the fingerprinted names.
+func NewStatic(fsys fs.FS, options ...StaticOption) (*Static, error) {
+	o := StaticOptions{
+		immutable: regexp.MustCompile(`[.-][0-9a-f]{8,}\.\w+$`),
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	s := Static{
+		fsys:   fsys,
+		opts:   o,
+		files:  make(map[string]staticFile),
+		hashed: make(map[string]string),
+	}
+
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+
+		defer func() { _ = f.Close() }()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+
+		file := staticFile{name: name, hash: hex.EncodeToString(h.Sum(nil))[:16]}
+		s.files[name] = file
+
+		if o.fingerprint {
+			ext := path.Ext(name)
+			s.hashed[strings.TrimSuffix(name, ext)+"."+file.hash[:8]+ext] = name
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("static: hash files: %w", err)
+	}
+
+	return &s, nil
+}
+
+// AssetURL returns the URL of the asset with the prefix, and with the content hash if the
+// fingerprinting is enabled. Returns the URL without the hash if the asset does not exist.
+func (s *Static) AssetURL(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	if file, ok := s.files[name]; ok && s.opts.fingerprint {
+		ext := path.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "." + file.hash[:8] + ext
+	}
+
+	return s.opts.prefix + "/" + name
+}
+
+// TemplateFuncs returns the template functions of the assets. Add them to the templates
+// before parsing to reference the fingerprinted URLs, e.g. <script src="{{ asset "app.js" }}">.
+//   - asset returns the URL of the asset, see AssetURL.
+func (s *Static) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{"asset": s.AssetURL}
+}
+
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, errors.New("method not allowed")),
+			WithStatus(http.StatusMethodNotAllowed),
+		)
+
+		return
+	}
+
+	// The chi router keeps the path relative to the mount point in the route context.
+	urlPath := r.URL.Path
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		urlPath = rctx.RoutePath
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+urlPath), "/")
+	cacheControl := "no-cache"
+
+	if s.opts.maxAge > 0 {
+		cacheControl = "public, max-age=" + strconv.FormatInt(int64(s.opts.maxAge/time.Second), 10)
+	}
+
+	file, ok := s.lookup(name)
+
+	switch {
+	case ok && (s.hashed[name] != "" || s.opts.immutable.MatchString(name)):
+		cacheControl = immutableCacheControl
+
+	case !ok && s.opts.spa && path.Ext(name) == "":
+		file, ok = s.files["index.html"]
+	}
+
+	if !ok {
+		ErrorHTTP(w, r, errkit.ErrNotFound)
+		return
+	}
+
+	// Redirect to the directory path, so the relative links of its index.html are resolved.
+	if name != "" && file.name == path.Join(name, "index.html") && !strings.HasSuffix(urlPath, "/") {
+		target := r.URL.Path + "/"
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if err := s.serveFile(w, r, file); err != nil {
+		ErrorHTTP(w, r, fmt.Errorf("static: serve %s: %w", file.name, err))
+	}
+}
+
+// lookup returns the file by the request path, which may be the fingerprinted name or the directory.
+func (s *Static) lookup(name string) (staticFile, bool) {
+	if original, ok := s.hashed[name]; ok {
+		name = original
+	}
+
+	if file, ok := s.files[name]; ok {
+		return file, true
+	}
+
+	file, ok := s.files[path.Join(name, "index.html")]
+
+	return file, ok
+}
+
+// serveFile writes the file or its precompressed variant.
+func (s *Static) serveFile(w http.ResponseWriter, r *http.Request, file staticFile) error {
+	contentType := mime.TypeByExtension(path.Ext(file.name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	name, etag := file.name, file.hash
+	accept := r.Header.Get("Accept-Encoding")
+
+	for _, encoding := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		variant, ok := s.files[file.name+encoding.ext]
+		if !ok || !acceptsEncoding(accept, encoding.name) {
+			continue
+		}
+
+		name, etag = variant.name, variant.hash
+		w.Header().Set("Content-Encoding", encoding.name)
+
+		break
+	}
+
+	w.Header().Set("ETag", `"`+etag+`"`)
+
+	content, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return err
+	}
+
+	// The zero modtime disables the Last-Modified, the ETag is used instead.
+	http.ServeContent(w, r, file.name, time.Time{}, bytes.NewReader(content))
+
+	return nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value accepts the content coding,
+// e.g. "gzip, br;q=0.8". The coding listed with q=0, or not listed and not matched
+// by the "*", is not acceptable.
+func acceptsEncoding(header, coding string) bool {
+	wildcard := false
+
+	for element := range strings.SplitSeq(header, ",") {
+		name, params, _ := strings.Cut(element, ";")
+		name = strings.TrimSpace(name)
+
+		accepted := true
+
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(key, "q") {
+				continue
+			}
+
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			accepted = err == nil && q > 0
+		}
+
+		switch {
+		case strings.EqualFold(name, coding):
+			return accepted
+
+		case name == "*":
+			wildcard = accepted
+		}
+	}
+
+	return wildcard
+}
@@ -0,0 +1,142 @@
+package httpkit
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestStatic(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":             {Data: []byte("<html>app</html>")},
+		"app.js":                 {Data: []byte("console.log('app')")},
+		"app.js.br":              {Data: []byte("brotli")},
+		"app.js.gz":              {Data: []byte("gzip")},
+		"chunk.0123456789ab.css": {Data: []byte("body{}")},
+		"docs/index.html":        {Data: []byte("<html>docs</html>")},
+		"empty/file.txt":         {Data: []byte("file")},
+	}
+
+	static, err := NewStatic(fsys, WithStaticPrefix("/ui"), WithSPAFallback(), WithFingerprinting())
+	td.Require(t).CmpNoError(err)
+
+	router := chi.NewRouter()
+	router.Mount("/ui", static)
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for key, values := range header {
+			r.Header[key] = values
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	f := func(target string, header http.Header, wantStatus int, wantHeader map[string]string, wantBody string) {
+		t.Helper()
+
+		w := serve(target, header)
+
+		td.Cmp(t, w.Code, wantStatus, target)
+
+		for key, value := range wantHeader {
+			td.Cmp(t, w.Header().Get(key), value, target+" "+key)
+		}
+
+		if wantBody != "" {
+			td.Cmp(t, w.Body.String(), wantBody, target)
+		}
+	}
+
+	f("/ui/", nil, http.StatusOK, map[string]string{
+		"Content-Type":  "text/html; charset=utf-8",
+		"Cache-Control": "no-cache",
+	}, "<html>app</html>")
+
+	f("/ui/app.js", nil, http.StatusOK, map[string]string{
+		"Content-Type":     "text/javascript; charset=utf-8",
+		"Content-Encoding": "",
+	}, "console.log('app')")
+
+	f("/ui/app.js", http.Header{"Accept-Encoding": {"gzip, br"}}, http.StatusOK, map[string]string{
+		"Content-Type":     "text/javascript; charset=utf-8",
+		"Content-Encoding": "br",
+		"Vary":             "Accept-Encoding",
+	}, "brotli")
+
+	f("/ui/app.js", http.Header{"Accept-Encoding": {"gzip"}}, http.StatusOK, map[string]string{
+		"Content-Encoding": "gzip",
+	}, "gzip")
+
+	f("/ui/app.js", http.Header{"Accept-Encoding": {"br;q=0, gzip;q=0.5"}}, http.StatusOK, map[string]string{
+		"Content-Encoding": "gzip",
+	}, "gzip")
+
+	f("/ui/app.js", http.Header{"Accept-Encoding": {"gzip;q=0, *;q=0"}}, http.StatusOK, map[string]string{
+		"Content-Encoding": "",
+	}, "console.log('app')")
+
+	f("/ui/app.js", http.Header{"Accept-Encoding": {"*"}}, http.StatusOK, map[string]string{
+		"Content-Encoding": "br",
+	}, "brotli")
+
+	f(static.AssetURL("app.js"), nil, http.StatusOK, map[string]string{
+		"Cache-Control": immutableCacheControl,
+	}, "console.log('app')")
+
+	f("/ui/chunk.0123456789ab.css", nil, http.StatusOK, map[string]string{
+		"Content-Type":  "text/css; charset=utf-8",
+		"Cache-Control": immutableCacheControl,
+	}, "body{}")
+
+	f("/ui/users/42", nil, http.StatusOK, nil, "<html>app</html>")
+	f("/ui/missing.js", nil, http.StatusNotFound, nil, "")
+	f("/ui/empty/", nil, http.StatusOK, nil, "<html>app</html>")
+	f("/ui/docs", nil, http.StatusMovedPermanently, map[string]string{"Location": "/ui/docs/"}, "")
+	f("/ui/docs/", nil, http.StatusOK, nil, "<html>docs</html>")
+	f("/ui/../../etc/passwd.txt", nil, http.StatusNotFound, nil, "")
+
+	etag := serve("/ui/app.js", nil).Header().Get("ETag")
+	td.CmpNot(t, etag, "")
+
+	f("/ui/app.js", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, nil, "")
+}
+
+func TestStatic_noFallback(t *testing.T) {
+	static, err := NewStatic(fstest.MapFS{"empty/file.txt": {Data: []byte("file")}})
+	td.Require(t).CmpNoError(err)
+
+	for _, target := range []string{"/", "/empty/", "/users/42", "/../empty/file.txt/.."} {
+		w := httptest.NewRecorder()
+		static.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		td.Cmp(t, w.Code, http.StatusNotFound, target)
+	}
+
+	w := httptest.NewRecorder()
+	static.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/empty/file.txt", nil))
+
+	td.Cmp(t, w.Code, http.StatusMethodNotAllowed)
+}
+
+func TestStatic_TemplateFuncs(t *testing.T) {
+	static, err := NewStatic(fstest.MapFS{"app.js": {Data: []byte("app")}}, WithStaticPrefix("/static/"), WithFingerprinting())
+	td.Require(t).CmpNoError(err)
+
+	tmpl := template.Must(template.New("").Funcs(static.TemplateFuncs()).Parse(`<script src="{{ asset "app.js" }}"></script>`))
+
+	var buf bytes.Buffer
+
+	td.Require(t).CmpNoError(tmpl.Execute(&buf, nil))
+	td.Cmp(t, buf.String(), td.Re(`^<script src="/static/app\.[0-9a-f]{8}\.js"></script>$`))
+	td.Cmp(t, static.AssetURL("missing.js"), "/static/missing.js")
+}
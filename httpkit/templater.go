@@ -0,0 +1,235 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"maps"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/plainq/servekit/errkit"
+)
+
+// Compilation time check that FSTemplater implements the HTMLTemplateProvider.
+var _ HTMLTemplateProvider = (*FSTemplater)(nil)
+
+// FSTemplaterOptions represents the options of the FSTemplater.
+type FSTemplaterOptions struct {
+	funcs       template.FuncMap
+	layoutsDir  string
+	partialsDir string
+	ext         string
+	dev         bool
+}
+
+// FSTemplaterOption represents a function type that modifies FSTemplaterOptions.
+type FSTemplaterOption func(o *FSTemplaterOptions)
+
+// WithTemplateFuncs adds the functions shared by all templates.
+// The TemplateFuncs of the package are added by default.
+func WithTemplateFuncs(funcs template.FuncMap) FSTemplaterOption {
+	return func(o *FSTemplaterOptions) { maps.Copy(o.funcs, funcs) }
+}
+
+// WithTemplateDirs sets the directories of the layouts and the partials. Default is "layouts" and "partials".
+func WithTemplateDirs(layouts, partials string) FSTemplaterOption {
+	return func(o *FSTemplaterOptions) {
+		o.layoutsDir = layouts
+		o.partialsDir = partials
+	}
+}
+
+// WithTemplateExt sets the extension of the template files. Default is ".html".
+func WithTemplateExt(ext string) FSTemplaterOption {
+	return func(o *FSTemplaterOptions) { o.ext = ext }
+}
+
+// WithDevMode enables the development mode, in which the templates are re-parsed when
+// the files change, so the changes are visible without the restart. Use it with the os.DirFS.
+func WithDevMode(enabled bool) FSTemplaterOption {
+	return func(o *FSTemplaterOptions) { o.dev = enabled }
+}
+
+// FSTemplater implements the HTMLTemplateProvider loading the templates from the fs.FS.
+// Every file out of the layouts and the partials directories is a page, which is parsed
+// together with all the layouts and partials, and is named by its path, e.g. "users/show.html".
+// The layouts and partials are named by their paths too, so the page can use the layout by
+// defining the blocks and calling it, e.g.
+//
+//	{{ define "content" }}...{{ end }}
+//	{{ template "layouts/base.html" . }}
+//
+// The parsed templates are cached, so the errors are reported by the NewFSTemplater.
+type FSTemplater struct {
+	fsys fs.FS
+	opts FSTemplaterOptions
+
+	mu      sync.RWMutex
+	pages   map[string]*template.Template
+	version string
+}
+
+// NewFSTemplater returns a pointer to a new instance of FSTemplater with parsed templates.
+// Set it as the default templater by the SetHTMLTemplater.
+func NewFSTemplater(fsys fs.FS, options ...FSTemplaterOption) (*FSTemplater, error) {
+	o := FSTemplaterOptions{
+		funcs:       TemplateFuncs(),
+		layoutsDir:  "layouts",
+		partialsDir: "partials",
+		ext:         ".html",
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	t := FSTemplater{fsys: fsys, opts: o}
+
+	if err := t.parse(); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// Template returns the clone of the page template by its name. In the development mode
+// the templates are re-parsed if the files have changed since the last call.
+func (t *FSTemplater) Template(_ context.Context, name string) (*template.Template, error) {
+	if t.opts.dev {
+		version, err := t.currentVersion()
+		if err != nil {
+			return nil, err
+		}
+
+		t.mu.RLock()
+		changed := version != t.version
+		t.mu.RUnlock()
+
+		if changed {
+			if err := t.parse(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	page, ok := t.pages[name]
+	if !ok {
+		return nil, errors.Join(errkit.ErrNotFound, fmt.Errorf("templater: template %q not found", name))
+	}
+
+	// The cached page is never executed, so each caller, e.g. the TemplateHTML
+	// binding the CSP nonce, is able to clone the returned template.
+	clone, err := page.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("templater: clone template %q: %w", name, err)
+	}
+
+	return clone, nil
+}
+
+// parse parses all the templates and replaces the cached ones.
+func (t *FSTemplater) parse() error {
+	var (
+		shared []string
+		pages  []string
+	)
+
+	version, err := t.walk(func(name string) {
+		if t.inDir(name, t.opts.layoutsDir) || t.inDir(name, t.opts.partialsDir) {
+			shared = append(shared, name)
+		} else {
+			pages = append(pages, name)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	base := template.New("").Funcs(t.opts.funcs)
+
+	for _, name := range shared {
+		if err := t.parseFile(base, name); err != nil {
+			return err
+		}
+	}
+
+	parsed := make(map[string]*template.Template, len(pages))
+
+	for _, name := range pages {
+		set, err := base.Clone()
+		if err != nil {
+			return fmt.Errorf("templater: clone templates: %w", err)
+		}
+
+		if err := t.parseFile(set, name); err != nil {
+			return err
+		}
+
+		parsed[name] = set.Lookup(name)
+	}
+
+	t.mu.Lock()
+	t.pages, t.version = parsed, version
+	t.mu.Unlock()
+
+	return nil
+}
+
+// parseFile parses the file as the template named by its path into the set.
+func (t *FSTemplater) parseFile(set *template.Template, name string) error {
+	content, err := fs.ReadFile(t.fsys, name)
+	if err != nil {
+		return fmt.Errorf("templater: read %s: %w", name, err)
+	}
+
+	if _, err := set.New(name).Parse(string(content)); err != nil {
+		return fmt.Errorf("templater: parse %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// currentVersion returns the version of the template files.
+func (t *FSTemplater) currentVersion() (string, error) { return t.walk(func(string) {}) }
+
+// walk calls the fn for every template file and returns the version of the files,
+// which is built of their names, sizes and modification times.
+func (t *FSTemplater) walk(fn func(name string)) (string, error) {
+	var version strings.Builder
+
+	err := fs.WalkDir(t.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != t.opts.ext {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		version.WriteString(name + ":" + strconv.FormatInt(info.Size(), 10) + ":" +
+			strconv.FormatInt(info.ModTime().UnixNano(), 10) + ";")
+
+		fn(name)
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("templater: walk templates: %w", err)
+	}
+
+	return version.String(), nil
+}
+
+// inDir reports whether the file is in the directory.
+func (*FSTemplater) inDir(name, dir string) bool {
+	return dir != "" && strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+}
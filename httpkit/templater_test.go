@@ -0,0 +1,117 @@
+package httpkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+)
+
+func TestFSTemplater(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html":    {Data: []byte(`<main>{{ template "content" . }}</main>{{ template "partials/footer.html" }}`)},
+		"partials/footer.html": {Data: []byte(`<footer>{{ upper "footer" }}</footer>`)},
+		"index.html":           {Data: []byte(`{{ define "content" }}Hello, {{ . }}{{ end }}{{ template "layouts/base.html" . }}`)},
+		"users/show.html":      {Data: []byte(`{{ define "content" }}User {{ . }}{{ end }}{{ template "layouts/base.html" . }}`)},
+		"broken.html":          {Data: []byte(`{{ define "content" }}{{ .Missing }}{{ end }}{{ template "layouts/base.html" . }}`)},
+		"readme.txt":           {Data: []byte(`{{ not a template`)},
+	}
+
+	templater, err := NewFSTemplater(fsys, WithTemplateFuncs(map[string]any{
+		"upper": func(s string) string { return s + "!" },
+	}))
+	td.Require(t).CmpNoError(err)
+
+	prev := htmlTemplater
+	t.Cleanup(func() { htmlTemplater = prev })
+
+	htmlTemplater = templater
+
+	render := func(name string, v any) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		TemplateHTML(w, httptest.NewRequest(http.MethodGet, "/", nil), name, v)
+
+		return w
+	}
+
+	w := render("index.html", "World")
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Body.String(), `<main>Hello, World</main><footer>footer!</footer>`)
+
+	w = render("users/show.html", 42)
+	td.Cmp(t, w.Body.String(), `<main>User 42</main><footer>footer!</footer>`)
+
+	w = render("broken.html", 42)
+	td.Cmp(t, w.Code, http.StatusInternalServerError)
+	td.Cmp(t, w.Body.String(), "Internal Server Error\n", "nothing of the page is written")
+
+	_, err = templater.Template(context.Background(), "missing.html")
+	td.CmpErrorIs(t, err, errkit.ErrNotFound)
+
+	_, err = templater.Template(context.Background(), "layouts/base.html")
+	td.CmpErrorIs(t, err, errkit.ErrNotFound, "layouts are not pages")
+}
+
+func TestFSTemplater_devMode(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`v1`), ModTime: time.Unix(1, 0)}}
+
+	cached, err := NewFSTemplater(fsys)
+	td.Require(t).CmpNoError(err)
+
+	dev, err := NewFSTemplater(fsys, WithDevMode(true))
+	td.Require(t).CmpNoError(err)
+
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`v2`), ModTime: time.Unix(2, 0)}
+
+	execute := func(templater *FSTemplater) string {
+		templ, err := templater.Template(context.Background(), "index.html")
+		td.Require(t).CmpNoError(err)
+
+		w := httptest.NewRecorder()
+		td.Require(t).CmpNoError(templ.Execute(w, nil))
+
+		return w.Body.String()
+	}
+
+	td.Cmp(t, execute(cached), "v1")
+	td.Cmp(t, execute(dev), "v2")
+
+	fsys["index.html"] = &fstest.MapFile{Data: []byte(`{{ broken`), ModTime: time.Unix(3, 0)}
+
+	_, err = dev.Template(context.Background(), "index.html")
+	td.CmpError(t, err)
+
+	_, err = NewFSTemplater(fsys)
+	td.CmpError(t, err)
+}
+
+func TestFSTemplater_cspNonce(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`<script nonce="{{ cspNonce }}"></script>`)}}
+
+	templater, err := NewFSTemplater(fsys)
+	td.Require(t).CmpNoError(err)
+
+	prev := htmlTemplater
+	t.Cleanup(func() { htmlTemplater = prev })
+
+	htmlTemplater = templater
+
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { TemplateHTML(w, r, "index.html", nil) })
+
+	// The page executed without the nonce is cloned to bind the nonce afterwards.
+	w := httptest.NewRecorder()
+	page.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Body.String(), `<script nonce=""></script>`)
+
+	w = httptest.NewRecorder()
+	SecurityHeadersMiddleware(WithCSP(CSP{ScriptSrc: []string{CSPSelf}, Nonce: true}))(page).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	td.Cmp(t, w.Code, http.StatusOK)
+	td.Cmp(t, w.Body.String(), td.Re(`^<script nonce="[^"]+"></script>$`))
+}
@@ -0,0 +1,81 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// HeaderRequestTimeout represents the header which carries the time budget of the request
+// in seconds, e.g. "1.5". Clients created by NewClient forward the remaining budget of the
+// request context in this header, and the TimeoutMiddleware honours it.
+const HeaderRequestTimeout = "Request-Timeout"
+
+// timeoutWriteSlack represents the extra time given to the connection write deadline
+// over the request deadline, so the timeout response can still be written.
+const timeoutWriteSlack = time.Second
+
+// TimeoutMiddleware returns the middleware which sets the deadline to the request context.
+// The deadline is shortened by the budget from the Request-Timeout header of the request,
+// if it is shorter. The connection read and write deadlines are adjusted to the request
+// deadline, so the route may run longer than the server-wide timeouts allow. If the deadline
+// is exceeded before the handler has written the response, the response is written by the
+// ErrorHTTP with the context.DeadlineExceeded error, which is 503 by default.
+// Mount it per route group to give slow endpoints their own budget.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			budget := timeout
+
+			if incoming, ok := ParseRequestTimeout(r.Header.Get(HeaderRequestTimeout)); ok && incoming < budget {
+				budget = incoming
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+
+			deadline, _ := ctx.Deadline()
+
+			// The connection deadlines are not supported by the
+			// http.ResponseController for some writers, that is fine.
+			rc := http.NewResponseController(w)
+			_ = rc.SetReadDeadline(deadline)
+			_ = rc.SetWriteDeadline(deadline.Add(timeoutWriteSlack))
+
+			// The http.Server does not reset the write deadline of the keep-alive
+			// connection without the WriteTimeout, so reset it for the next request.
+			defer func() { _ = rc.SetWriteDeadline(time.Time{}) }()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				ErrorHTTP(ww, r, context.DeadlineExceeded)
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ParseRequestTimeout parses the value of the Request-Timeout header.
+// The second return value reports whether the value is a valid positive budget.
+func ParseRequestTimeout(value string) (time.Duration, bool) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || seconds <= 0 || seconds > float64(time.Duration(1<<63-1)/time.Second) {
+		return 0, false
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// FormatRequestTimeout returns the value of the Request-Timeout header for the given budget.
+func FormatRequestTimeout(budget time.Duration) string {
+	return strconv.FormatFloat(budget.Seconds(), 'f', 3, 64)
+}
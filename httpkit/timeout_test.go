@@ -0,0 +1,89 @@
+package httpkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	t.Run("Exceeded", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(HeaderRequestTimeout, "0.05")
+
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		handler.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, http.StatusServiceUnavailable)
+		td.CmpLt(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Written", func(t *testing.T) {
+		handler := TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		td.Cmp(t, w.Code, http.StatusAccepted)
+	})
+}
+
+func TestParseRequestTimeout(t *testing.T) {
+	f := func(value string, want time.Duration, wantOK bool) {
+		t.Helper()
+
+		got, ok := ParseRequestTimeout(value)
+		td.Cmp(t, ok, wantOK, value)
+		td.Cmp(t, got, want, value)
+	}
+
+	f("1.5", 1500*time.Millisecond, true)
+	f(" 2 ", 2*time.Second, true)
+	f("0", 0, false)
+	f("-1", 0, false)
+	f("abc", 0, false)
+	f("", 0, false)
+	f("1e300", 0, false)
+
+	td.Cmp(t, FormatRequestTimeout(1500*time.Millisecond), "1.500")
+}
+
+func TestClient_requestTimeout(t *testing.T) {
+	var got string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(HeaderRequestTimeout)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	td.Require(t).CmpNoError(err)
+
+	res, err := NewClient().Do(req)
+	td.Require(t).CmpNoError(err)
+	td.CmpNoError(t, res.Body.Close())
+
+	budget, ok := ParseRequestTimeout(got)
+	td.Cmp(t, ok, true)
+	td.Cmp(t, budget, td.Between(9*time.Second, 10*time.Second))
+	td.Cmp(t, req.Header.Get(HeaderRequestTimeout), "", "the original request is not modified")
+}
@@ -0,0 +1,252 @@
+package httpkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+)
+
+// apiVersionKey represents a Key for context by which
+// the served API version can be received from the context.
+const apiVersionKey ctxkit.Key = "ctx.httpkit.api-version"
+
+// APIVersion returns the API version served by the VersionRouter from the context,
+// so the handlers shared between the versions can tell them apart.
+// If searched values is absent in context, then empty string wil be returned.
+func APIVersion(ctx context.Context) string {
+	return ctxkit.Get[string](ctx, apiVersionKey)
+}
+
+// VersionRouterOptions represents the options of the VersionRouter.
+type VersionRouterOptions struct {
+	prefix         bool
+	header         string
+	mediaParam     string
+	defaultVersion string
+}
+
+// VersionRouterOption represents a function type that modifies VersionRouterOptions.
+type VersionRouterOption func(o *VersionRouterOptions)
+
+// WithVersionPrefix enables the version selection by the first segment of the path, e.g. /v2/users.
+// The segment is stripped before the path is routed by the version handler.
+func WithVersionPrefix() VersionRouterOption {
+	return func(o *VersionRouterOptions) { o.prefix = true }
+}
+
+// WithVersionHeader enables the version selection by the given request header, e.g. API-Version: 2.
+func WithVersionHeader(header string) VersionRouterOption {
+	return func(o *VersionRouterOptions) { o.header = header }
+}
+
+// WithVersionMediaType enables the version selection by the given parameter of the media types
+// of the Accept header, e.g. Accept: application/json; version=2.
+func WithVersionMediaType(param string) VersionRouterOption {
+	return func(o *VersionRouterOptions) { o.mediaParam = param }
+}
+
+// WithDefaultVersion sets the version which serves the requests without the version.
+// By default, such requests are responded with 404 status.
+func WithDefaultVersion(version string) VersionRouterOption {
+	return func(o *VersionRouterOptions) { o.defaultVersion = version }
+}
+
+// VersionOptions represents the options of the version of the VersionRouter.
+type VersionOptions struct {
+	deprecation time.Time
+	sunset      time.Time
+	link        string
+}
+
+// VersionOption represents a function type that modifies VersionOptions.
+type VersionOption func(o *VersionOptions)
+
+// WithVersionDeprecation marks the version as deprecated since the given time.
+// The responses have the Deprecation header.
+func WithVersionDeprecation(since time.Time) VersionOption {
+	return func(o *VersionOptions) { o.deprecation = since }
+}
+
+// WithVersionSunset sets the time when the version stops being served.
+// The responses have the Sunset header.
+func WithVersionSunset(at time.Time) VersionOption {
+	return func(o *VersionOptions) { o.sunset = at }
+}
+
+// WithVersionLink sets the link to the deprecation notice, e.g. the migration guide.
+// The responses have the Link header with the deprecation relation.
+func WithVersionLink(link string) VersionOption {
+	return func(o *VersionOptions) { o.link = link }
+}
+
+// apiVersion represents the version of the VersionRouter.
+type apiVersion struct {
+	name    string
+	handler http.Handler
+	options VersionOptions
+}
+
+// VersionRouter represents the handler which serves the versions of the API side by side.
+// The version is selected by the path prefix, the request header or the media type parameter,
+// in this order, as enabled by the options. Unless the version is selected by the path, the
+// responses have the Vary header of the selecting headers. The served version is set to the
+// request context, see APIVersion, logged and recorded in the metrics labels by the LoggingMiddleware
+// and the MetricsMiddleware. The handlers can be shared between the versions, e.g. the typed
+// handlers of the openapi package registered in the API of each version.
+type VersionRouter struct {
+	options  VersionRouterOptions
+	versions map[string]*apiVersion
+}
+
+// NewVersionRouter returns a pointer to a new instance of VersionRouter.
+func NewVersionRouter(options ...VersionRouterOption) *VersionRouter {
+	o := VersionRouterOptions{}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &VersionRouter{
+		options:  o,
+		versions: make(map[string]*apiVersion),
+	}
+}
+
+// Handle registers the handler of the given version, e.g. v1.
+// The version is matched with or without the "v" prefix, so v2 is selected by API-Version: 2.
+func (v *VersionRouter) Handle(version string, handler http.Handler, options ...VersionOption) {
+	o := VersionOptions{}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	v.versions[version] = &apiVersion{name: version, handler: handler, options: o}
+}
+
+// Route registers the routes of the given version. See Handle.
+func (v *VersionRouter) Route(version string, fn func(r chi.Router), options ...VersionOption) {
+	router := chi.NewRouter()
+	fn(router)
+
+	v.Handle(version, router, options...)
+}
+
+// ServeHTTP implements http.Handler interface.
+func (v *VersionRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	version, r, err := v.resolve(w, r)
+	if err != nil {
+		ErrorHTTP(w, r, err)
+		return
+	}
+
+	ctx := ctxkit.Set(r.Context(), apiVersionKey, version.name)
+
+	ctxkit.MetricLabel(ctx, "version", version.name)
+	ctxkit.LogAttrs(ctx, slog.String("api_version", version.name))
+
+	if !version.options.deprecation.IsZero() {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(version.options.deprecation.Unix(), 10))
+	}
+
+	if !version.options.sunset.IsZero() {
+		w.Header().Set("Sunset", version.options.sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if version.options.link != "" {
+		w.Header().Add("Link", "<"+version.options.link+`>; rel="deprecation"`)
+	}
+
+	version.handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// resolve returns the version of the request and the request to pass to its handler.
+// Unless the version is selected by the path, the response varies by the headers which
+// select the version, so the Vary header is set for the shared caches.
+func (v *VersionRouter) resolve(w http.ResponseWriter, r *http.Request) (*apiVersion, *http.Request, error) {
+	if v.options.prefix {
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			rctx = chi.NewRouteContext()
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+		}
+
+		routePath := rctx.RoutePath
+		if routePath == "" {
+			routePath = r.URL.Path
+		}
+
+		segment, rest, _ := strings.Cut(strings.TrimPrefix(routePath, "/"), "/")
+
+		if version, ok := v.versions[segment]; ok {
+			rctx.RoutePath = "/" + rest
+			return version, r, nil
+		}
+	}
+
+	if v.options.header != "" {
+		w.Header().Add("Vary", v.options.header)
+	}
+
+	if v.options.mediaParam != "" {
+		w.Header().Add("Vary", "Accept")
+	}
+
+	if v.options.header != "" {
+		if value := r.Header.Get(v.options.header); value != "" {
+			version, ok := v.lookup(value)
+			if !ok {
+				return nil, r, errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("unsupported API version: %q", value))
+			}
+
+			return version, r, nil
+		}
+	}
+
+	if v.options.mediaParam != "" {
+		for accept := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+			_, params, err := mime.ParseMediaType(accept)
+			if err != nil || params[v.options.mediaParam] == "" {
+				continue
+			}
+
+			version, ok := v.lookup(params[v.options.mediaParam])
+			if !ok {
+				return nil, r, errors.Join(errkit.ErrInvalidArgument,
+					fmt.Errorf("unsupported API version: %q", params[v.options.mediaParam]),
+				)
+			}
+
+			return version, r, nil
+		}
+	}
+
+	if version, ok := v.versions[v.options.defaultVersion]; ok {
+		return version, r, nil
+	}
+
+	return nil, r, errors.Join(errkit.ErrNotFound, errors.New("API version is not specified"))
+}
+
+// lookup returns the version by its name with or without the "v" prefix.
+func (v *VersionRouter) lookup(name string) (*apiVersion, bool) {
+	name = strings.TrimSpace(name)
+
+	if version, ok := v.versions[name]; ok {
+		return version, true
+	}
+
+	version, ok := v.versions["v"+name]
+
+	return version, ok
+}
@@ -0,0 +1,85 @@
+package httpkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/ctxkit"
+)
+
+func TestVersionRouter(t *testing.T) {
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// The handler is shared between the versions.
+	users := func(r chi.Router) {
+		r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			TEXT(w, r, []byte(APIVersion(r.Context())+":"+chi.URLParam(r, "id")))
+		})
+	}
+
+	versions := NewVersionRouter(
+		WithVersionPrefix(),
+		WithVersionHeader("API-Version"),
+		WithVersionMediaType("version"),
+		WithDefaultVersion("v2"),
+	)
+	versions.Route("v1", users,
+		WithVersionDeprecation(time.Unix(1700000000, 0)),
+		WithVersionSunset(sunset),
+		WithVersionLink("https://example.com/migrate"),
+	)
+	versions.Route("v2", users)
+
+	router := chi.NewRouter()
+	router.Use(MetricsMiddleware())
+	router.Mount("/api", versions)
+
+	f := func(target string, header http.Header, wantStatus int, wantBody string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header = header
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, wantStatus, target)
+
+		if wantBody != "" {
+			td.Cmp(t, w.Body.String(), wantBody, target)
+		}
+
+		return w
+	}
+
+	w := f("/api/v1/users/1", http.Header{}, http.StatusOK, "v1:1")
+	td.Cmp(t, w.Header().Get("Deprecation"), "@1700000000")
+	td.Cmp(t, w.Header().Get("Sunset"), "Fri, 01 Jan 2027 00:00:00 GMT")
+	td.Cmp(t, w.Header().Get("Link"), `<https://example.com/migrate>; rel="deprecation"`)
+
+	w = f("/api/v2/users/2", http.Header{}, http.StatusOK, "v2:2")
+	td.Cmp(t, w.Header().Get("Deprecation"), "")
+	td.Cmp(t, w.Header().Values("Vary"), td.Nil(), "the version is selected by the path")
+
+	// The responses of the versions selected by the headers vary by them.
+	w = f("/api/users/3", http.Header{"Api-Version": {"1"}}, http.StatusOK, "v1:3")
+	td.Cmp(t, w.Header().Values("Vary"), []string{"API-Version", "Accept"})
+
+	w = f("/api/users/4", http.Header{"Accept": {"application/json; version=1"}}, http.StatusOK, "v1:4")
+	td.Cmp(t, w.Header().Values("Vary"), []string{"API-Version", "Accept"})
+
+	w = f("/api/users/5", http.Header{}, http.StatusOK, "v2:5")
+	td.Cmp(t, w.Header().Values("Vary"), []string{"API-Version", "Accept"})
+
+	f("/api/users/6", http.Header{"Api-Version": {"3"}}, http.StatusBadRequest, "")
+
+	td.Cmp(t,
+		metrics.GetOrCreateCounter(httpReqTotalStr(http.MethodGet, "/api/users/{id}", "200", ctxkit.FormatMetricLabel("version", "v1"))).Get(),
+		uint64(3),
+	)
+}
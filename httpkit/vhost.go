@@ -0,0 +1,222 @@
+package httpkit
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/plainq/servekit/errkit"
+)
+
+// HostOptions represents the options of the virtual host.
+type HostOptions struct {
+	cert, key    string
+	errResponder HTTPErrorResponder
+	templater    HTMLTemplateProvider
+}
+
+// HostOption represents a function type that modifies HostOptions.
+type HostOption func(o *HostOptions)
+
+// WithHostTLS sets the TLS certificate and key files of the virtual host, which is selected
+// by the SNI of the TLS handshake. The listener serves TLS if any virtual host has the certificate,
+// and the certificate set by the WithTLS is used for the names which match no virtual host.
+func WithHostTLS(cert, key string) HostOption {
+	return func(o *HostOptions) {
+		o.cert = cert
+		o.key = key
+	}
+}
+
+// WithHostErrorResponder sets the responder which is used by the ErrorHTTP
+// for the requests of the virtual host instead of the listener one.
+func WithHostErrorResponder(responder HTTPErrorResponder) HostOption {
+	return func(o *HostOptions) { o.errResponder = responder }
+}
+
+// WithHostTemplater sets the templater which is used by the TemplateHTML
+// for the requests of the virtual host instead of the listener one.
+func WithHostTemplater(templater HTMLTemplateProvider) HostOption {
+	return func(o *HostOptions) { o.templater = templater }
+}
+
+// virtualHost represents the host which requests are routed by its own router.
+type virtualHost struct {
+	// name holds the exact host name, or the suffix of the wildcard pattern, e.g. ".example.com".
+	name     string
+	wildcard bool
+	router   chi.Router
+	cert     *tls.Certificate
+}
+
+// Host registers the router of the virtual host. The requests which Host header matches the
+// pattern are routed by the router instead of the listener one, after the global middlewares.
+// The pattern is either the exact host name, e.g. api.example.com, or the wildcard which matches
+// a single label, e.g. *.example.com. The exact patterns take precedence over the wildcards,
+// and the longer wildcards take precedence over the shorter ones. The health, metrics and
+// profiler routes of the listener are served for any host. It must be called before the Serve.
+func (l *ListenerHTTP) Host(pattern string, fn func(r chi.Router), options ...HostOption) error {
+	o := HostOptions{}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+
+	host := virtualHost{name: pattern}
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		host.name = suffix
+		host.wildcard = true
+	}
+
+	if host.name == "" || strings.Contains(host.name, "*") || (host.wildcard && !strings.HasPrefix(host.name, ".")) {
+		return errors.Join(errkit.ErrInvalidArgument, fmt.Errorf("invalid host pattern: %q", pattern))
+	}
+
+	for _, h := range l.hosts {
+		if h.name == host.name && h.wildcard == host.wildcard {
+			return errors.Join(errkit.ErrAlreadyExists, fmt.Errorf("host %q is already registered", pattern))
+		}
+	}
+
+	if o.cert != "" || o.key != "" {
+		cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+		if err != nil {
+			return fmt.Errorf("load certificate of host %q: %w", pattern, err)
+		}
+
+		host.cert = &cert
+	}
+
+	router := chi.NewRouter()
+
+	if o.errResponder != nil {
+		router.Use(ErrorResponderMiddleware(o.errResponder))
+	}
+
+	if o.templater != nil {
+		router.Use(TemplaterMiddleware(o.templater))
+	}
+
+	fn(router)
+
+	host.router = router
+	l.hosts = append(l.hosts, &host)
+
+	// The chi router runs its middlewares, and so the host routing, only once it has a route.
+	// With builds the middleware chain, so the virtual hosts are served without the listener routes.
+	l.router.With()
+
+	return nil
+}
+
+// matchHost returns the virtual host which matches the given host name or nil.
+func (l *ListenerHTTP) matchHost(name string) *virtualHost {
+	if h, _, err := net.SplitHostPort(name); err == nil {
+		name = h
+	}
+
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	var matched *virtualHost
+
+	for _, host := range l.hosts {
+		if !host.wildcard {
+			if host.name == name {
+				return host
+			}
+
+			continue
+		}
+
+		label, ok := strings.CutSuffix(name, host.name)
+		if !ok || label == "" || strings.Contains(label, ".") {
+			continue
+		}
+
+		if matched == nil || len(host.name) > len(matched.name) {
+			matched = host
+		}
+	}
+
+	return matched
+}
+
+// hostMiddleware returns the middleware which routes the requests of the virtual hosts.
+// The given routes of the listener are served for any host.
+func (l *ListenerHTTP) hostMiddleware(exempt []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if len(l.hosts) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, route := range exempt {
+				if r.URL.Path == route || strings.HasPrefix(r.URL.Path, strings.TrimSuffix(route, "/")+"/") {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			host := l.matchHost(r.Host)
+			if host == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The connection established for the other virtual host with
+			// its own certificate must not be used for this one.
+			if r.TLS != nil && r.TLS.ServerName != "" {
+				if sni := l.matchHost(r.TLS.ServerName); sni != host && sni != nil && sni.cert != nil {
+					ErrorHTTP(w, r, errors.Join(errkit.ErrInvalidArgument, errors.New("host does not match the server name")),
+						WithStatus(http.StatusMisdirectedRequest),
+					)
+
+					return
+				}
+			}
+
+			host.router.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// hostCertificates reports whether any virtual host has the certificate.
+func (l *ListenerHTTP) hostCertificates() bool {
+	for _, host := range l.hosts {
+		if host.cert != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostCertificate returns the certificate of the virtual host selected by the SNI.
+// It returns nil for the names which match no virtual host with the certificate,
+// so the certificate of the listener is used.
+func (l *ListenerHTTP) hostCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if host := l.matchHost(hello.ServerName); host != nil && host.cert != nil {
+		return host.cert, nil
+	}
+
+	if !l.enableTLS {
+		return nil, fmt.Errorf("no certificate for server name %q", hello.ServerName)
+	}
+
+	return nil, nil
+}
+
+// tlsEnabled reports whether the listener serves TLS.
+func (l *ListenerHTTP) tlsEnabled() bool {
+	return l.enableTLS || l.hostCertificates()
+}
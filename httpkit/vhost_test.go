@@ -0,0 +1,142 @@
+package httpkit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/maxatome/go-testdeep/td"
+	"github.com/plainq/servekit/errkit"
+)
+
+// writeCertificate writes the self-signed certificate of the given name and its key to the temporary files.
+func writeCertificate(t *testing.T, name string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	td.Require(t).CmpNoError(err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	td.Require(t).CmpNoError(err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	td.Require(t).CmpNoError(err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	td.Require(t).CmpNoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	td.Require(t).CmpNoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestListenerHTTP_Host(t *testing.T) {
+	l, err := NewListenerHTTP(":0", WithHealthCheck())
+	td.Require(t).CmpNoError(err)
+
+	text := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { TEXT(w, r, []byte(body)) }
+	}
+
+	teapot := func(w http.ResponseWriter, _ error, _ ...ResponseOption) { w.WriteHeader(http.StatusTeapot) }
+
+	certFile, keyFile := writeCertificate(t, "api.example.com")
+
+	td.CmpNoError(t, l.Host("api.example.com", func(r chi.Router) {
+		r.Get("/", text("api"))
+		r.Get("/fail", func(w http.ResponseWriter, r *http.Request) { ErrorHTTP(w, r, errkit.ErrNotFound) })
+	}, WithHostTLS(certFile, keyFile), WithHostErrorResponder(teapot)))
+
+	td.CmpNoError(t, l.Host("*.example.com", func(r chi.Router) { r.Get("/", text("wildcard")) }))
+	td.CmpError(t, l.Host("*.example.com", func(chi.Router) {}))
+	td.CmpError(t, l.Host("api.*.com", func(chi.Router) {}))
+
+	l.MountGroup("/", func(r chi.Router) { r.Get("/", text("default")) })
+
+	f := func(host, target string, wantStatus int, wantBody string) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Host = host
+
+		w := httptest.NewRecorder()
+		l.router.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, wantStatus, host+target)
+
+		if wantBody != "" {
+			td.Cmp(t, w.Body.String(), wantBody, host+target)
+		}
+	}
+
+	f("API.example.com:443", "/", http.StatusOK, "api")
+	f("docs.example.com", "/", http.StatusOK, "wildcard")
+	f("a.docs.example.com", "/", http.StatusOK, "default")
+	f("localhost", "/", http.StatusOK, "default")
+	f("api.example.com", "/fail", http.StatusTeapot, "")
+	f("api.example.com", "/health", http.StatusOK, "")
+
+	// The certificate is selected by the SNI.
+	td.CmpTrue(t, l.tlsEnabled())
+
+	cert, err := l.hostCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+	td.CmpNoError(t, err)
+	td.CmpNotNil(t, cert)
+
+	_, err = l.hostCertificate(&tls.ClientHelloInfo{ServerName: "docs.example.com"})
+	td.CmpError(t, err)
+
+	// The connection of the other host with its own certificate is misdirected.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "docs.example.com"
+	r.TLS = &tls.ConnectionState{ServerName: "api.example.com"}
+
+	w := httptest.NewRecorder()
+	l.router.ServeHTTP(w, r)
+	td.Cmp(t, w.Code, http.StatusMisdirectedRequest)
+}
+
+func TestListenerHTTP_Host_noRoutes(t *testing.T) {
+	l, err := NewListenerHTTP(":0")
+	td.Require(t).CmpNoError(err)
+
+	td.CmpNoError(t, l.Host("api.example.com", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) { TEXT(w, r, []byte("api")) })
+	}))
+
+	f := func(host string, wantStatus int) {
+		t.Helper()
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+
+		w := httptest.NewRecorder()
+		l.server.Handler.ServeHTTP(w, r)
+
+		td.Cmp(t, w.Code, wantStatus, host)
+	}
+
+	f("api.example.com", http.StatusOK)
+	f("localhost", http.StatusNotFound)
+}
@@ -0,0 +1,241 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MessageType represents the type of the WebSocket message.
+type MessageType byte
+
+const (
+	// TextMessage represents a UTF-8 encoded text message.
+	TextMessage MessageType = 1
+
+	// BinaryMessage represents a binary message.
+	BinaryMessage MessageType = 2
+)
+
+// opcode represents the WebSocket frame opcode.
+// See: https://www.rfc-editor.org/rfc/rfc6455#section-5.2
+type opcode byte
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+func (op opcode) isControl() bool { return op&0x8 != 0 }
+
+// maxControlPayload represents the max payload length of the control frame.
+const maxControlPayload = 125
+
+// maxMessageSize represents the hard limit of the received message size,
+// which applies regardless of the read limit, so the peer can not exhaust the memory.
+const maxMessageSize = 64 << 20
+
+// Close codes defined by RFC 6455.
+// See: https://www.rfc-editor.org/rfc/rfc6455#section-7.4.1
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatusReceived    = 1005
+	CloseInvalidPayloadData  = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseInternalServerError = 1011
+	CloseTryAgainLater       = 1013
+)
+
+// CloseError represents the close frame received from the peer.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: closed with code " + strconv.Itoa(e.Code) + ": " + e.Reason
+}
+
+// frame represents a single WebSocket frame.
+type frame struct {
+	fin     bool
+	opcode  opcode
+	payload []byte
+}
+
+// readFrame reads a single frame from the reader. Frames sent by the client must be masked.
+// Data payloads exceeding the limit are rejected with the CloseMessageTooBig code.
+// Negative limit, or the one exceeding the maxMessageSize, means the maxMessageSize.
+func readFrame(r *bufio.Reader, limit int64) (frame, error) {
+	if limit < 0 || limit > maxMessageSize {
+		limit = maxMessageSize
+	}
+
+	var header [2]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    header[0]&0x80 != 0,
+		opcode: opcode(header[0] & 0x0F),
+	}
+
+	if header[0]&0x70 != 0 {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "reserved bits are set"}
+	}
+
+	switch f.opcode {
+	case opContinuation, opText, opBinary, opClose, opPing, opPong:
+
+	default:
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "unknown opcode"}
+	}
+
+	if header[1]&0x80 == 0 {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "client frame is not masked"}
+	}
+
+	length := int64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return frame{}, err
+		}
+
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+
+	if f.opcode.isControl() && (length > maxControlPayload || !f.fin) {
+		return frame{}, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+
+	if !f.opcode.isControl() && length > limit {
+		return frame{}, &CloseError{Code: CloseMessageTooBig, Reason: "message is too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return frame{}, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return frame{}, err
+	}
+
+	maskBytes(mask, f.payload)
+
+	return f, nil
+}
+
+// writeFrame writes a single frame to the writer. The payload is masked if the mask is given.
+func writeFrame(w *bufio.Writer, f frame, mask *[4]byte) error {
+	b0 := byte(f.opcode)
+	if f.fin {
+		b0 |= 0x80
+	}
+
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+
+	header := make([]byte, 0, 14)
+	header = append(header, b0)
+
+	switch n := len(f.payload); {
+	case n <= 125:
+		header = append(header, maskBit|byte(n))
+
+	case n <= 0xFFFF:
+		header = append(header, maskBit|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+
+	default:
+		header = append(header, maskBit|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	payload := f.payload
+
+	if mask != nil {
+		header = append(header, mask[:]...)
+		payload = make([]byte, len(f.payload))
+		copy(payload, f.payload)
+		maskBytes(*mask, payload)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("write frame header: %w", err)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return fmt.Errorf("write frame payload: %w", err)
+	}
+
+	return w.Flush()
+}
+
+// maskBytes applies the masking algorithm to the payload in place.
+func maskBytes(mask [4]byte, payload []byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
+
+// closePayload returns the payload of the close frame with the given code and reason.
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+
+	// Reason must fit into the control frame along with the code.
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+
+	payload := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(reason)), uint16(code)) //nolint:gosec // Close codes fit uint16.
+
+	return append(payload, reason...)
+}
+
+// parseClosePayload returns the close code and reason from the close frame payload.
+func parseClosePayload(payload []byte) (*CloseError, error) {
+	if len(payload) == 0 {
+		return &CloseError{Code: CloseNoStatusReceived}, nil
+	}
+
+	if len(payload) < 2 {
+		return nil, &CloseError{Code: CloseProtocolError, Reason: "invalid close payload"}
+	}
+
+	reason := payload[2:]
+	if !utf8.Valid(reason) {
+		return nil, &CloseError{Code: CloseInvalidPayloadData, Reason: "invalid close reason"}
+	}
+
+	return &CloseError{Code: int(binary.BigEndian.Uint16(payload)), Reason: string(reason)}, nil
+}
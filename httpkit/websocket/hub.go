@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// messagesDropped represents the total number of messages which have not
+// been delivered by the Hub because of the full connection write queue.
+var messagesDropped = metrics.NewCounter(`websocket_messages_dropped_total`)
+
+// Hub represents a set of connections subscribed to topics.
+// Connections are removed from the Hub automatically when they are closed.
+// It is safe for concurrent use.
+type Hub struct {
+	mu     sync.RWMutex
+	conns  map[*Conn]map[string]struct{}
+	topics map[string]map[*Conn]struct{}
+}
+
+// NewHub returns a pointer to a new instance of Hub.
+func NewHub() *Hub {
+	h := Hub{
+		conns:  make(map[*Conn]map[string]struct{}),
+		topics: make(map[string]map[*Conn]struct{}),
+	}
+
+	return &h
+}
+
+// Register adds the connection to the Hub without any topic subscription.
+// Registered connections receive messages sent by BroadcastAll.
+func (h *Hub) Register(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.register(c)
+}
+
+// Subscribe subscribes the connection to the given topics and registers it if needed.
+func (h *Hub) Subscribe(c *Conn, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.register(c)
+
+	for _, topic := range topics {
+		h.conns[c][topic] = struct{}{}
+
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Conn]struct{})
+		}
+
+		h.topics[topic][c] = struct{}{}
+	}
+}
+
+// Unsubscribe unsubscribes the connection from the given topics.
+func (h *Hub) Unsubscribe(c *Conn, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(h.conns[c], topic)
+		h.unsubscribe(c, topic)
+	}
+}
+
+// Remove removes the connection from the Hub and all its topics.
+func (h *Hub) Remove(c *Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic := range h.conns[c] {
+		h.unsubscribe(c, topic)
+	}
+
+	delete(h.conns, c)
+}
+
+// Broadcast puts the message to the write queues of all connections subscribed to the topic.
+// Connections with the full write queue are closed with CloseTryAgainLater code, so the
+// slow consumers do not block the others. Returns the number of connections the message is queued to.
+func (h *Hub) Broadcast(topic string, msgType MessageType, data []byte) int {
+	h.mu.RLock()
+
+	conns := make([]*Conn, 0, len(h.topics[topic]))
+	for c := range h.topics[topic] {
+		conns = append(conns, c)
+	}
+
+	h.mu.RUnlock()
+
+	return broadcast(conns, msgType, data)
+}
+
+// BroadcastAll puts the message to the write queues of all registered connections.
+// See Broadcast for the details.
+func (h *Hub) BroadcastAll(msgType MessageType, data []byte) int {
+	h.mu.RLock()
+
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+
+	h.mu.RUnlock()
+
+	return broadcast(conns, msgType, data)
+}
+
+// Len returns the number of connections registered in the Hub.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.conns)
+}
+
+// TopicLen returns the number of connections subscribed to the topic.
+func (h *Hub) TopicLen(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.topics[topic])
+}
+
+// Close closes all registered connections with the given code and reason.
+func (h *Hub) Close(code int, reason string) {
+	h.mu.RLock()
+
+	conns := make([]*Conn, 0, len(h.conns))
+	for c := range h.conns {
+		conns = append(conns, c)
+	}
+
+	h.mu.RUnlock()
+
+	for _, c := range conns {
+		c.Close(code, reason)
+	}
+}
+
+// register adds the connection to the Hub. Must be called under the lock.
+func (h *Hub) register(c *Conn) {
+	if _, ok := h.conns[c]; ok {
+		return
+	}
+
+	h.conns[c] = make(map[string]struct{})
+
+	c.OnClose(func() { h.Remove(c) })
+}
+
+// unsubscribe removes the connection from the topic. Must be called under the lock.
+func (h *Hub) unsubscribe(c *Conn, topic string) {
+	delete(h.topics[topic], c)
+
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+func broadcast(conns []*Conn, msgType MessageType, data []byte) int {
+	var sent int
+
+	for _, c := range conns {
+		if err := c.WriteMessage(msgType, data); err != nil {
+			messagesDropped.Inc()
+
+			if errors.Is(err, ErrWriteQueueFull) {
+				c.Close(CloseTryAgainLater, "slow consumer")
+			}
+
+			continue
+		}
+
+		sent++
+	}
+
+	return sent
+}
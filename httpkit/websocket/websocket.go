@@ -0,0 +1,572 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455)
+// on top of the ListenerHTTP routes, along with the Hub for topic-based broadcast.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // SHA-1 is required by RFC 6455.
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/plainq/servekit/errkit"
+	"github.com/plainq/servekit/httpkit"
+)
+
+const (
+	// acceptGUID represents the GUID which is used to compute Sec-WebSocket-Accept.
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// defaultReadLimit represents default max size of the received message.
+	defaultReadLimit = 32 << 10
+
+	// defaultWriteQueueSize represents default size of the per connection write queue.
+	defaultWriteQueueSize = 64
+
+	// defaultPingInterval represents default interval between pings.
+	defaultPingInterval = 30 * time.Second
+
+	// defaultPongWait represents default time to wait for any frame from the peer
+	// (including pong) before the connection is considered dead.
+	defaultPongWait = 60 * time.Second
+
+	// defaultWriteWait represents default timeout of a single frame write.
+	defaultWriteWait = 10 * time.Second
+)
+
+const (
+	// ErrClosed indicates that the connection has been closed.
+	ErrClosed Error = "websocket: connection closed"
+
+	// ErrWriteQueueFull indicates that the peer does not read messages fast enough.
+	ErrWriteQueueFull Error = "websocket: write queue is full"
+)
+
+// Error represents package level errors.
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+var (
+	// connectionsActive represents the number of currently open connections.
+	connectionsActive = metrics.NewCounter(`websocket_connections_active`)
+
+	// messagesReceived represents the total number of received messages.
+	messagesReceived = metrics.NewCounter(`websocket_messages_received_total`)
+
+	// messagesSent represents the total number of sent messages.
+	messagesSent = metrics.NewCounter(`websocket_messages_sent_total`)
+)
+
+// Option represents a function which configures the Conn.
+type Option func(o *Options)
+
+// Options represents the configuration of the upgraded connection.
+type Options struct {
+	readLimit      int64
+	writeQueueSize int
+	pingInterval   time.Duration
+	pongWait       time.Duration
+	writeWait      time.Duration
+	subprotocols   []string
+	checkOrigin    func(r *http.Request) bool
+}
+
+// WithReadLimit sets the max size of the received message in bytes.
+// Connections receiving bigger messages are closed with CloseMessageTooBig code.
+// Zero or negative limit, as well as the one above 64 MiB, means 64 MiB.
+func WithReadLimit(limit int64) Option { return func(o *Options) { o.readLimit = limit } }
+
+// WithWriteQueueSize sets the size of the per connection write queue.
+func WithWriteQueueSize(size int) Option { return func(o *Options) { o.writeQueueSize = size } }
+
+// WithPing sets the interval between pings and the time to wait for any frame from
+// the peer before the connection is considered dead. The interval should be less than wait.
+func WithPing(interval, wait time.Duration) Option {
+	return func(o *Options) {
+		o.pingInterval = interval
+		o.pongWait = wait
+	}
+}
+
+// WithWriteWait sets the timeout of a single frame write.
+func WithWriteWait(timeout time.Duration) Option { return func(o *Options) { o.writeWait = timeout } }
+
+// WithSubprotocols sets the subprotocols supported by the server in the order of preference.
+func WithSubprotocols(protocols ...string) Option {
+	return func(o *Options) { o.subprotocols = protocols }
+}
+
+// WithCheckOrigin sets the function which validates the Origin header of the handshake request.
+// By default, requests with Origin header are accepted only when it matches the Host header.
+func WithCheckOrigin(check func(r *http.Request) bool) Option {
+	return func(o *Options) { o.checkOrigin = check }
+}
+
+// Conn represents the server side of the WebSocket connection.
+// ReadMessage must be called from a single goroutine,
+// while WriteMessage and Close are safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+
+	subprotocol string
+	opts        Options
+
+	// queue holds data messages, control holds control frames
+	// which are written ahead of the data messages.
+	queue   chan frame
+	control chan frame
+
+	// closeFrame holds the close frame which is written
+	// by the writeLoop once the closing is closed.
+	closeFrame frame
+	closing    chan struct{}
+	closeOnce  sync.Once
+	done       chan struct{}
+
+	mu      sync.Mutex
+	onClose []func()
+}
+
+// Upgrade upgrades the HTTP request to the WebSocket connection. If the handshake fails, the
+// error is written via httpkit.ErrorHTTP and returned. The connection is closed with
+// CloseGoingAway code when the ListenerHTTP serving the request shuts down.
+func Upgrade(w http.ResponseWriter, r *http.Request, options ...Option) (*Conn, error) {
+	o := Options{
+		readLimit:      defaultReadLimit,
+		writeQueueSize: defaultWriteQueueSize,
+		pingInterval:   defaultPingInterval,
+		pongWait:       defaultPongWait,
+		writeWait:      defaultWriteWait,
+		checkOrigin:    sameOrigin,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	key, err := validateHandshake(r)
+	if err != nil {
+		if r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+
+		httpkit.ErrorHTTP(w, r, err)
+
+		return nil, err
+	}
+
+	if !o.checkOrigin(r) {
+		err := fmt.Errorf("%w: websocket: origin is not allowed", errkit.ErrUnauthorized)
+		httpkit.ErrorHTTP(w, r, err, httpkit.WithStatus(http.StatusForbidden))
+
+		return nil, err
+	}
+
+	subprotocol := selectSubprotocol(r, o.subprotocols)
+
+	netConn, brw, hijackErr := http.NewResponseController(w).Hijack()
+	if hijackErr != nil {
+		err := fmt.Errorf("websocket: hijack connection: %w", hijackErr)
+		httpkit.ErrorHTTP(w, r, err)
+
+		return nil, err
+	}
+
+	// Reset deadlines set by the http.Server.
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: reset deadline: %w", err)
+	}
+
+	var b strings.Builder
+
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	b.WriteString("Upgrade: websocket\r\n")
+	b.WriteString("Connection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+
+	b.WriteString("\r\n")
+
+	if err := netConn.SetWriteDeadline(time.Now().Add(o.writeWait)); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: set write deadline: %w", err)
+	}
+
+	if _, err := brw.WriteString(b.String()); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: write handshake: %w", err)
+	}
+
+	if err := brw.Flush(); err != nil {
+		_ = netConn.Close()
+		return nil, fmt.Errorf("websocket: write handshake: %w", err)
+	}
+
+	c := Conn{
+		conn:        netConn,
+		br:          brw.Reader,
+		bw:          bufio.NewWriter(netConn),
+		subprotocol: subprotocol,
+		opts:        o,
+		queue:       make(chan frame, o.writeQueueSize),
+		control:     make(chan frame, 8),
+		closing:     make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	connectionsActive.Inc()
+
+	go c.writeLoop(httpkit.ShutdownSignal(r.Context()))
+
+	return &c, nil
+}
+
+// Subprotocol returns the negotiated subprotocol.
+func (c *Conn) Subprotocol() string { return c.subprotocol }
+
+// RemoteAddr returns the remote network address.
+func (c *Conn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// Done returns a channel which is closed when the connection is closed.
+func (c *Conn) Done() <-chan struct{} { return c.done }
+
+// OnClose registers the function which is called when the connection is closed.
+func (c *Conn) OnClose(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		go fn()
+
+	default:
+		c.onClose = append(c.onClose, fn)
+	}
+}
+
+// ReadMessage reads the next data message. Pings are answered automatically.
+// Returns *CloseError when the peer closes the connection, or when the protocol is
+// violated, in which case the connection is closed with the corresponding code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		msgType MessageType
+		message []byte
+	)
+
+	for {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.opts.pongWait)); err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+
+		limit := int64(maxMessageSize)
+		if c.opts.readLimit > 0 {
+			limit = min(c.opts.readLimit, limit)
+		}
+
+		limit -= int64(len(message))
+
+		f, err := readFrame(c.br, limit)
+		if err != nil {
+			return 0, nil, c.readFailed(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			c.sendControl(frame{fin: true, opcode: opPong, payload: f.payload})
+			continue
+
+		case opPong:
+			continue
+
+		case opClose:
+			closeErr, parseErr := parseClosePayload(f.payload)
+			if parseErr != nil {
+				return 0, nil, c.readFailed(parseErr)
+			}
+
+			code := closeErr.Code
+			if code == CloseNoStatusReceived {
+				code = CloseNormalClosure
+			}
+
+			c.Close(code, "")
+
+			return 0, nil, closeErr
+
+		case opText, opBinary:
+			if message != nil {
+				return 0, nil, c.readFailed(&CloseError{Code: CloseProtocolError, Reason: "expected continuation frame"})
+			}
+
+			msgType = MessageType(f.opcode)
+			message = f.payload
+
+		case opContinuation:
+			if message == nil {
+				return 0, nil, c.readFailed(&CloseError{Code: CloseProtocolError, Reason: "unexpected continuation frame"})
+			}
+
+			message = append(message, f.payload...)
+		}
+
+		if !f.fin {
+			continue
+		}
+
+		if msgType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.readFailed(&CloseError{Code: CloseInvalidPayloadData, Reason: "invalid UTF-8"})
+		}
+
+		messagesReceived.Inc()
+
+		return msgType, message, nil
+	}
+}
+
+// ReadJSON reads the next data message and decodes it from JSON into v.
+func (c *Conn) ReadJSON(v any) error {
+	_, message, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(message, v); err != nil {
+		return fmt.Errorf("%w: websocket: decode message: %w", errkit.ErrInvalidArgument, err)
+	}
+
+	return nil
+}
+
+// WriteMessage puts the message to the connection write queue.
+// Returns ErrWriteQueueFull if the peer does not read messages fast enough.
+// Only TextMessage and BinaryMessage are accepted, control frames are sent by the Conn itself.
+func (c *Conn) WriteMessage(msgType MessageType, data []byte) error {
+	if msgType != TextMessage && msgType != BinaryMessage {
+		return fmt.Errorf("%w: websocket: invalid message type: %d", errkit.ErrInvalidArgument, msgType)
+	}
+
+	select {
+	case <-c.closing:
+		return ErrClosed
+
+	default:
+	}
+
+	select {
+	case c.queue <- frame{fin: true, opcode: opcode(msgType), payload: data}:
+		return nil
+
+	case <-c.closing:
+		return ErrClosed
+
+	default:
+		return ErrWriteQueueFull
+	}
+}
+
+// WriteJSON encodes v into JSON and puts it as the text message to the connection write queue.
+func (c *Conn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("websocket: encode message: %w", err)
+	}
+
+	return c.WriteMessage(TextMessage, data)
+}
+
+// Close sends the close frame with the given code and reason, and closes the connection.
+// It is safe to call Close multiple times, only the first call sends the close frame.
+func (c *Conn) Close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeFrame = frame{fin: true, opcode: opClose, payload: closePayload(code, reason)}
+		close(c.closing)
+	})
+}
+
+// readFailed closes the connection with the code of the protocol error and returns the error.
+func (c *Conn) readFailed(err error) error {
+	var closeErr *CloseError
+	if errors.As(err, &closeErr) {
+		c.Close(closeErr.Code, closeErr.Reason)
+		return err
+	}
+
+	c.Close(CloseNoStatusReceived, "")
+
+	select {
+	case <-c.closing:
+		return errors.Join(ErrClosed, err)
+
+	default:
+		return err
+	}
+}
+
+// sendControl puts the control frame to the control queue. It waits while the queue is full,
+// which is bounded by the write timeout of the writeLoop, and gives up once the connection is closing.
+func (c *Conn) sendControl(f frame) {
+	select {
+	case c.control <- f:
+
+	case <-c.closing:
+	}
+}
+
+// writeLoop is the only writer to the underlying connection.
+// It writes queued frames and pings until the connection is closed.
+func (c *Conn) writeLoop(shutdown <-chan struct{}) {
+	ticker := time.NewTicker(c.opts.pingInterval)
+
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+
+		c.mu.Lock()
+		close(c.done)
+		hooks := c.onClose
+		c.mu.Unlock()
+
+		connectionsActive.Dec()
+
+		for _, fn := range hooks {
+			fn()
+		}
+	}()
+
+	for {
+		// Control frames are written ahead of the data messages.
+		select {
+		case f := <-c.control:
+			if c.write(f) != nil {
+				return
+			}
+
+			continue
+
+		default:
+		}
+
+		select {
+		case f := <-c.control:
+			if c.write(f) != nil {
+				return
+			}
+
+		case f := <-c.queue:
+			if c.write(f) != nil {
+				return
+			}
+
+			messagesSent.Inc()
+
+		case <-ticker.C:
+			if c.write(frame{fin: true, opcode: opPing}) != nil {
+				return
+			}
+
+		case <-shutdown:
+			shutdown = nil
+			c.Close(CloseGoingAway, "server is shutting down")
+
+		case <-c.closing:
+			// The close frame is written regardless of the queued frames.
+			_ = c.write(c.closeFrame)
+			return
+		}
+	}
+}
+
+func (c *Conn) write(f frame) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.opts.writeWait)); err != nil {
+		return err
+	}
+
+	return writeFrame(c.bw, f, nil)
+}
+
+// validateHandshake validates the opening handshake request
+// and returns the Sec-WebSocket-Key header value.
+func validateHandshake(r *http.Request) (string, error) {
+	if r.Method != http.MethodGet {
+		return "", fmt.Errorf("%w: websocket: handshake method must be GET", errkit.ErrInvalidArgument)
+	}
+
+	if !httpkit.HeaderContainsToken(r.Header, "Connection", "upgrade") {
+		return "", fmt.Errorf("%w: websocket: 'upgrade' token not found in 'Connection' header", errkit.ErrInvalidArgument)
+	}
+
+	if !httpkit.HeaderContainsToken(r.Header, "Upgrade", "websocket") {
+		return "", fmt.Errorf("%w: websocket: 'websocket' token not found in 'Upgrade' header", errkit.ErrInvalidArgument)
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", fmt.Errorf("%w: websocket: unsupported version", errkit.ErrInvalidArgument)
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return "", fmt.Errorf("%w: websocket: invalid 'Sec-WebSocket-Key' header", errkit.ErrInvalidArgument)
+	}
+
+	return key, nil
+}
+
+// acceptKey computes the Sec-WebSocket-Accept header value.
+func acceptKey(key string) string {
+	h := sha1.New() //nolint:gosec // SHA-1 is required by RFC 6455.
+	h.Write([]byte(key + acceptGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// selectSubprotocol returns the first server subprotocol requested by the client.
+func selectSubprotocol(r *http.Request, supported []string) string {
+	requested := make([]string, 0)
+
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			requested = append(requested, strings.TrimSpace(p))
+		}
+	}
+
+	for _, p := range supported {
+		if slices.Contains(requested, p) {
+			return p
+		}
+	}
+
+	return ""
+}
+
+// sameOrigin reports whether the Origin header is absent or matches the Host header.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
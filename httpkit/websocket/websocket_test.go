@@ -0,0 +1,239 @@
+package websocket
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+// testClient represents a minimal WebSocket client used by tests.
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+	bw   *bufio.Writer
+}
+
+func dial(t *testing.T, rawURL string, headers ...string) (*testClient, *http.Response) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, http.NoBody)
+	td.Require(t).CmpNoError(err)
+
+	conn, err := net.Dial("tcp", req.URL.Host)
+	td.Require(t).CmpNoError(err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	td.Require(t).CmpNoError(req.Write(conn))
+
+	c := testClient{conn: conn, br: bufio.NewReader(conn), bw: bufio.NewWriter(conn)}
+
+	resp, err := http.ReadResponse(c.br, req)
+	td.Require(t).CmpNoError(err)
+
+	return &c, resp
+}
+
+func (c *testClient) write(t *testing.T, op opcode, payload []byte) {
+	t.Helper()
+
+	td.Require(t).CmpNoError(writeFrame(c.bw, frame{fin: true, opcode: op, payload: payload}, &[4]byte{1, 2, 3, 4}))
+}
+
+// read reads an unmasked server frame.
+func (c *testClient) read(t *testing.T) frame {
+	t.Helper()
+
+	td.Require(t).CmpNoError(c.conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+
+	header := make([]byte, 2)
+	_, err := c.br.Read(header[:1])
+	td.Require(t).CmpNoError(err)
+	_, err = c.br.Read(header[1:])
+	td.Require(t).CmpNoError(err)
+
+	payload := make([]byte, header[1]&0x7F)
+	for n := 0; n < len(payload); {
+		m, err := c.br.Read(payload[n:])
+		td.Require(t).CmpNoError(err)
+		n += m
+	}
+
+	return frame{fin: header[0]&0x80 != 0, opcode: opcode(header[0] & 0x0F), payload: payload}
+}
+
+func echoServer(t *testing.T, options ...Option) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, options...)
+		if err != nil {
+			return
+		}
+
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestUpgrade(t *testing.T) {
+	server := echoServer(t, WithSubprotocols("v2", "v1"))
+
+	client, resp := dial(t, server.URL, "Sec-WebSocket-Protocol", "v1, v2")
+	td.Cmp(t, resp.StatusCode, http.StatusSwitchingProtocols)
+	td.Cmp(t, resp.Header.Get("Sec-WebSocket-Accept"), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	td.Cmp(t, resp.Header.Get("Sec-WebSocket-Protocol"), "v2")
+
+	t.Run("Echo", func(t *testing.T) {
+		client.write(t, opText, []byte("hello"))
+		td.Cmp(t, client.read(t), frame{fin: true, opcode: opText, payload: []byte("hello")})
+	})
+
+	t.Run("Ping", func(t *testing.T) {
+		client.write(t, opPing, []byte("p"))
+		td.Cmp(t, client.read(t), frame{fin: true, opcode: opPong, payload: []byte("p")})
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		client.write(t, opClose, closePayload(CloseNormalClosure, "bye"))
+		td.Cmp(t, client.read(t), frame{fin: true, opcode: opClose, payload: closePayload(CloseNormalClosure, "")})
+	})
+}
+
+func TestUpgrade_readLimit(t *testing.T) {
+	server := echoServer(t, WithReadLimit(4))
+
+	client, _ := dial(t, server.URL)
+	client.write(t, opBinary, []byte("too long"))
+
+	f := client.read(t)
+	td.Cmp(t, f.opcode, opClose)
+
+	closeErr, err := parseClosePayload(f.payload)
+	td.CmpNoError(t, err)
+	td.Cmp(t, closeErr.Code, CloseMessageTooBig)
+}
+
+func TestUpgrade_rejected(t *testing.T) {
+	server := echoServer(t)
+
+	f := func(name string, want int, headers ...string) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			_, resp := dial(t, server.URL, headers...)
+			td.Cmp(t, resp.StatusCode, want)
+		})
+	}
+
+	f("Version", http.StatusBadRequest, "Sec-WebSocket-Version", "8")
+	f("Key", http.StatusBadRequest, "Sec-WebSocket-Key", "short")
+	f("Origin", http.StatusForbidden, "Origin", "https://evil.example")
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	subscribed := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+
+		hub.Subscribe(conn, r.URL.Query().Get("topic"))
+		subscribed <- struct{}{}
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	news, _ := dial(t, server.URL+"/?topic=news")
+	<-subscribed
+
+	sport, _ := dial(t, server.URL+"/?topic=sport")
+	<-subscribed
+
+	td.Cmp(t, hub.Len(), 2)
+	td.Cmp(t, hub.Broadcast("news", TextMessage, []byte("hot")), 1)
+	td.Cmp(t, news.read(t).payload, []byte("hot"))
+
+	td.Cmp(t, hub.BroadcastAll(TextMessage, []byte("all")), 2)
+	td.Cmp(t, news.read(t).payload, []byte("all"))
+	td.Cmp(t, sport.read(t).payload, []byte("all"))
+
+	hub.Close(CloseGoingAway, "bye")
+	td.Cmp(t, news.read(t).opcode, opClose)
+	td.Cmp(t, sport.read(t).opcode, opClose)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	td.Cmp(t, hub.Len(), 0)
+}
+
+func TestReadFrame_unmasked(t *testing.T) {
+	var buf strings.Builder
+
+	bw := bufio.NewWriter(&buf)
+	td.CmpNoError(t, writeFrame(bw, frame{fin: true, opcode: opText, payload: []byte("x")}, nil))
+
+	_, err := readFrame(bufio.NewReader(strings.NewReader(buf.String())), -1)
+
+	var closeErr *CloseError
+	td.CmpTrue(t, errors.As(err, &closeErr))
+	td.Cmp(t, closeErr.Code, CloseProtocolError)
+}
+
+func TestReadFrame_tooBig(t *testing.T) {
+	// Masked binary frame which declares 1 TiB payload.
+	header := []byte{0x82, 0x80 | 127, 0, 0, 1, 0, 0, 0, 0, 0, 1, 2, 3, 4}
+
+	for _, limit := range []int64{-1, 1 << 62} {
+		_, err := readFrame(bufio.NewReader(strings.NewReader(string(header))), limit)
+
+		var closeErr *CloseError
+		td.CmpTrue(t, errors.As(err, &closeErr))
+		td.Cmp(t, closeErr.Code, CloseMessageTooBig)
+	}
+}
+
+func TestConn_WriteMessage_controlType(t *testing.T) {
+	td.CmpError(t, (&Conn{}).WriteMessage(MessageType(opClose), nil))
+	td.CmpError(t, (&Conn{}).WriteMessage(MessageType(opPing), nil))
+}
@@ -0,0 +1,304 @@
+// Package limitkit provides the adaptive concurrency limiter which caps the number
+// of in-flight requests and adjusts the cap by the observed latency, so the service
+// sheds the excess load instead of queueing requests until they time out.
+package limitkit
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/plainq/servekit/errkit"
+)
+
+// ErrLimitExceeded indicates that the request was shed because the concurrency limit is reached.
+var ErrLimitExceeded = errors.Join(errkit.ErrUnavailable, errors.New("concurrency limit exceeded"))
+
+// Priority represents the priority class of the request.
+type Priority uint8
+
+const (
+	// PriorityLow requests are shed first, when the in-flight requests
+	// reach the low priority share of the limit.
+	PriorityLow Priority = iota
+
+	// PriorityNormal requests are shed when the in-flight requests reach the limit.
+	PriorityNormal
+
+	// PriorityCritical requests are never shed and do not affect the limit,
+	// use it for health checks and admin routes.
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+
+	case PriorityNormal:
+		return "normal"
+
+	case PriorityCritical:
+		return "critical"
+
+	default:
+		return "unknown"
+	}
+}
+
+// Algorithm represents the algorithm which adjusts the concurrency limit.
+type Algorithm interface {
+	// Update returns the new limit after the request has completed with the given latency.
+	// The inflight is the number of in-flight requests when the request has started, and
+	// the dropped reports whether the request has failed due to the overload.
+	// The Limiter serializes the calls, so implementations need no locking.
+	Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// AIMD implements the additive increase, multiplicative decrease Algorithm.
+// The limit grows by one while requests succeed and the limit is utilized,
+// and is multiplied by the backoff ratio when a request is dropped or slower than the timeout.
+type AIMD struct {
+	backoff float64
+	timeout time.Duration
+}
+
+// NewAIMD returns a pointer to a new instance of AIMD. Requests slower than the timeout
+// are treated as dropped, zero timeout disables the check. The backoff ratio is 0.9.
+func NewAIMD(timeout time.Duration) *AIMD {
+	return &AIMD{backoff: 0.9, timeout: timeout}
+}
+
+func (a *AIMD) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || (a.timeout > 0 && rtt > a.timeout) {
+		return limit * a.backoff
+	}
+
+	// Grow only if the limit is utilized, otherwise the limit
+	// grows without bound while the service is idle.
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+
+	return limit
+}
+
+// Gradient implements the Algorithm which adjusts the limit by the ratio of the long-term
+// average latency to the current latency. The limit shrinks when the latency grows over the
+// long-term average times the tolerance, which indicates queueing, and grows otherwise.
+type Gradient struct {
+	smoothing float64
+	tolerance float64
+	window    float64
+	longRTT   float64
+}
+
+// NewGradient returns a pointer to a new instance of Gradient with the default
+// tolerance of 1.5, smoothing of 0.2 and the long-term average window of 600 samples.
+func NewGradient() *Gradient {
+	return &Gradient{smoothing: 0.2, tolerance: 1.5, window: 600}
+}
+
+func (g *Gradient) Update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	shortRTT := rtt.Seconds()
+	if shortRTT <= 0 {
+		return limit
+	}
+
+	if g.longRTT == 0 {
+		g.longRTT = shortRTT
+	}
+
+	g.longRTT += (shortRTT - g.longRTT) / g.window
+
+	if dropped {
+		return limit / 2
+	}
+
+	gradient := math.Max(0.5, math.Min(1, g.tolerance*g.longRTT/shortRTT))
+
+	// Do not grow the limit which is not utilized.
+	if gradient == 1 && float64(inflight)*2 < limit {
+		return limit
+	}
+
+	next := limit*gradient + math.Sqrt(limit)
+
+	return limit*(1-g.smoothing) + next*g.smoothing
+}
+
+// Options represents the options of the Limiter.
+type Options struct {
+	algorithm Algorithm
+	initial   float64
+	minLimit  float64
+	maxLimit  float64
+	lowRatio  float64
+}
+
+// Option represents a function type that modifies Options.
+type Option func(o *Options)
+
+// WithAlgorithm sets the Algorithm which adjusts the limit. Default is Gradient.
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(o *Options) { o.algorithm = algorithm }
+}
+
+// WithInitialLimit sets the initial limit. Default is 20.
+func WithInitialLimit(limit int) Option {
+	return func(o *Options) { o.initial = float64(limit) }
+}
+
+// WithLimitBounds sets the bounds of the limit. Default is from 1 to 1000.
+func WithLimitBounds(minLimit, maxLimit int) Option {
+	return func(o *Options) {
+		o.minLimit = float64(max(1, minLimit))
+		o.maxLimit = float64(max(minLimit, maxLimit))
+	}
+}
+
+// WithLowPriorityRatio sets the share of the limit available to the low priority requests.
+// Default is 0.8, so the low priority requests are shed before the normal ones.
+func WithLowPriorityRatio(ratio float64) Option {
+	return func(o *Options) { o.lowRatio = math.Max(0, math.Min(1, ratio)) }
+}
+
+// Limiter caps the number of in-flight requests by the adaptive limit.
+// It exports the concurrency_limit, concurrency_inflight and
+// concurrency_rejected_total metrics labeled by the name of the limiter.
+type Limiter struct {
+	name string
+	opts Options
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+
+	limitGauge    *metrics.Gauge
+	inflightGauge *metrics.Gauge
+}
+
+// NewLimiter returns a pointer to a new instance of Limiter.
+// The name is used as the label of the metrics and must be unique.
+func NewLimiter(name string, options ...Option) *Limiter {
+	o := Options{
+		initial:  20,
+		minLimit: 1,
+		maxLimit: 1000,
+		lowRatio: 0.8,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	if o.algorithm == nil {
+		o.algorithm = NewGradient()
+	}
+
+	l := Limiter{
+		name:          name,
+		opts:          o,
+		limit:         math.Max(o.minLimit, math.Min(o.maxLimit, o.initial)),
+		limitGauge:    metrics.GetOrCreateGauge(`concurrency_limit{name="`+name+`"}`, nil),
+		inflightGauge: metrics.GetOrCreateGauge(`concurrency_inflight{name="`+name+`"}`, nil),
+	}
+
+	l.limitGauge.Set(math.Floor(l.limit))
+
+	return &l
+}
+
+// Limit returns the current limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return int(l.limit)
+}
+
+// Inflight returns the number of in-flight requests.
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.inflight
+}
+
+// Acquire reserves the slot for the request of the given priority.
+// It returns false if the request must be shed, otherwise the returned
+// Token must be released when the request has completed.
+func (l *Limiter) Acquire(p Priority) (*Token, bool) {
+	// Critical requests are not counted as in-flight, so they never take the capacity of the others.
+	if p == PriorityCritical {
+		return &Token{limiter: l, start: time.Now(), critical: true}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := math.Floor(l.limit)
+
+	if p == PriorityLow {
+		capacity = math.Max(1, math.Floor(l.limit*l.opts.lowRatio))
+	}
+
+	if float64(l.inflight) >= capacity {
+		metrics.GetOrCreateCounter(`concurrency_rejected_total{name="` + l.name + `", priority="` + p.String() + `"}`).
+			Inc()
+
+		return nil, false
+	}
+
+	l.inflight++
+	l.inflightGauge.Set(float64(l.inflight))
+
+	t := Token{
+		limiter:  l,
+		start:    time.Now(),
+		inflight: l.inflight,
+	}
+
+	return &t, true
+}
+
+// Token represents the slot of the in-flight request.
+type Token struct {
+	limiter  *Limiter
+	start    time.Time
+	inflight int
+	critical bool
+	once     sync.Once
+}
+
+// Release releases the slot and updates the limit by the latency of the request.
+// The dropped reports whether the request has failed due to the overload, e.g. timed out.
+// Critical requests do not update the limit. Subsequent calls do nothing.
+func (t *Token) Release(dropped bool) {
+	t.once.Do(func() {
+		if t.critical {
+			return
+		}
+
+		rtt := time.Since(t.start)
+
+		l := t.limiter
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.inflight--
+		l.inflightGauge.Set(float64(l.inflight))
+
+		limit := l.opts.algorithm.Update(l.limit, rtt, t.inflight, dropped)
+		l.limit = math.Max(l.opts.minLimit, math.Min(l.opts.maxLimit, limit))
+		l.limitGauge.Set(math.Floor(l.limit))
+	})
+}
+
+// RetryAfter formats the duration as the value of the Retry-After header in whole seconds.
+func RetryAfter(d time.Duration) string {
+	return strconv.FormatInt(max(1, int64(math.Ceil(d.Seconds()))), 10)
+}
@@ -0,0 +1,88 @@
+package limitkit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestLimiter_Acquire(t *testing.T) {
+	l := NewLimiter("test_acquire",
+		WithAlgorithm(NewAIMD(0)),
+		WithInitialLimit(5),
+		WithLowPriorityRatio(0.6),
+	)
+
+	tokens := make([]*Token, 0, 5)
+
+	for range 3 {
+		token, ok := l.Acquire(PriorityLow)
+		td.Require(t).True(ok)
+
+		tokens = append(tokens, token)
+	}
+
+	_, ok := l.Acquire(PriorityLow)
+	td.Cmp(t, ok, false, "low priority is shed at 60% of the limit")
+
+	for range 2 {
+		token, ok := l.Acquire(PriorityNormal)
+		td.Require(t).True(ok)
+
+		tokens = append(tokens, token)
+	}
+
+	_, ok = l.Acquire(PriorityNormal)
+	td.Cmp(t, ok, false, "normal priority is shed at the limit")
+
+	critical, ok := l.Acquire(PriorityCritical)
+	td.Cmp(t, ok, true, "critical priority is never shed")
+	td.Cmp(t, l.Inflight(), 5, "critical requests are not counted")
+
+	critical.Release(false)
+	td.Cmp(t, l.Limit(), 5, "critical requests do not update the limit")
+
+	for _, token := range tokens {
+		token.Release(false)
+		token.Release(false)
+	}
+
+	td.Cmp(t, l.Inflight(), 0)
+	td.Cmp(t, l.Limit(), 8, "the limit grows only while it is utilized")
+}
+
+func TestAIMD_Update(t *testing.T) {
+	a := NewAIMD(100 * time.Millisecond)
+
+	td.Cmp(t, a.Update(10, time.Millisecond, 5, false), 11.0)
+	td.Cmp(t, a.Update(10, time.Millisecond, 1, false), 10.0)
+	td.Cmp(t, a.Update(10, time.Millisecond, 5, true), 9.0)
+	td.Cmp(t, a.Update(10, time.Second, 5, false), 9.0)
+}
+
+func TestGradient_Update(t *testing.T) {
+	g := NewGradient()
+
+	limit := 20.0
+
+	for range 10 {
+		limit = g.Update(limit, 10*time.Millisecond, int(limit), false)
+	}
+
+	td.CmpGt(t, limit, 20.0, "the limit grows with the steady latency")
+
+	grown := limit
+
+	for range 10 {
+		limit = g.Update(limit, 100*time.Millisecond, int(limit), false)
+	}
+
+	td.CmpLt(t, limit, grown, "the limit shrinks with the growing latency")
+	td.Cmp(t, g.Update(limit, 10*time.Millisecond, 0, true), limit/2)
+}
+
+func TestRetryAfter(t *testing.T) {
+	td.Cmp(t, RetryAfter(0), "1")
+	td.Cmp(t, RetryAfter(1500*time.Millisecond), "2")
+}
@@ -1,6 +1,8 @@
 package retry
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -154,3 +156,29 @@ func Test_exponentialBackoff_Next(t *testing.T) {
 		}
 	})
 }
+
+func TestDo(t *testing.T) {
+	var calls int
+
+	err := Do(context.Background(), func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return MarkRetryable(errors.New("temporary"))
+		}
+
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Do() = %v after %d calls, want nil after 3 calls", err, calls)
+	}
+
+	calls = 0
+
+	err = Do(context.Background(), func(context.Context) error {
+		calls++
+		return MarkRetryable(errors.New("temporary"))
+	}, WithMaxAttempts(2))
+	if !errors.Is(err, ErrRetryLimitReached) || calls != 2 {
+		t.Errorf("Do() = %v after %d calls, want %v after 2 calls", err, calls, ErrRetryLimitReached)
+	}
+}
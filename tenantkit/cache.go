@@ -0,0 +1,224 @@
+package tenantkit
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/plainq/servekit/errkit"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOptions represents the options of the CachedResolver.
+type CacheOptions struct {
+	ttl                time.Duration
+	negativeTTL        time.Duration
+	maxEntries         int
+	negativeMaxEntries int
+}
+
+// CacheOption represents a function type that modifies CacheOptions.
+type CacheOption func(o *CacheOptions)
+
+// WithCacheTTL sets the duration the resolved tenants are cached for. Default is 1 minute.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) { o.ttl = ttl }
+}
+
+// WithCacheNegativeTTL sets the duration the unknown tenants are cached for,
+// so the requests of the unknown tenants do not hit the Resolver. Default is 10 seconds.
+func WithCacheNegativeTTL(ttl time.Duration) CacheOption {
+	return func(o *CacheOptions) { o.negativeTTL = ttl }
+}
+
+// WithCacheMaxEntries sets the max number of the cached tenants,
+// the least recently used ones are evicted. Default is 10000.
+func WithCacheMaxEntries(n int) CacheOption {
+	return func(o *CacheOptions) { o.maxEntries = n }
+}
+
+// WithCacheNegativeMaxEntries sets the max number of the cached unknown tenants, the least recently
+// used ones are evicted. They are bounded apart from the known tenants, so the requests with
+// the random tenant IDs do not evict the known ones. Default is 1000.
+func WithCacheNegativeMaxEntries(n int) CacheOption {
+	return func(o *CacheOptions) { o.negativeMaxEntries = n }
+}
+
+// cacheEntry represents the cached result of the Resolver.
+type cacheEntry struct {
+	tenant    *Tenant
+	err       error
+	expiresAt time.Time
+}
+
+// CachedResolver implements Resolver interface which caches the results of the given Resolver.
+// The concurrent lookups of the same tenant are deduplicated, the shared lookup is not canceled
+// when one of the callers goes away. The errors other than errkit.ErrNotFound are not cached.
+type CachedResolver struct {
+	resolver Resolver
+	options  CacheOptions
+	group    singleflight.Group
+
+	mu       sync.Mutex
+	entries  *cacheLRU
+	negative *cacheLRU
+}
+
+// NewCachedResolver returns a pointer to a new instance of CachedResolver.
+func NewCachedResolver(resolver Resolver, options ...CacheOption) *CachedResolver {
+	o := CacheOptions{
+		ttl:                time.Minute,
+		negativeTTL:        10 * time.Second,
+		maxEntries:         10000,
+		negativeMaxEntries: 1000,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &CachedResolver{
+		resolver: resolver,
+		options:  o,
+		entries:  newCacheLRU(o.maxEntries),
+		negative: newCacheLRU(o.negativeMaxEntries),
+	}
+}
+
+// Resolve implements Resolver interface.
+func (c *CachedResolver) Resolve(ctx context.Context, id string) (*Tenant, error) {
+	if entry, ok := c.load(id); ok {
+		return entry.tenant, entry.err
+	}
+
+	// The lookup is shared by the concurrent callers, so it must outlive the context of the first one.
+	lookupCtx := context.WithoutCancel(ctx)
+
+	ch := c.group.DoChan(id, func() (any, error) {
+		tenant, err := c.resolver.Resolve(lookupCtx, id)
+
+		switch {
+		case err == nil:
+			c.store(id, cacheEntry{tenant: tenant, expiresAt: time.Now().Add(c.options.ttl)})
+
+		case errors.Is(err, errkit.ErrNotFound):
+			c.store(id, cacheEntry{err: err, expiresAt: time.Now().Add(c.options.negativeTTL)})
+		}
+
+		return tenant, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		tenant, _ := res.Val.(*Tenant)
+
+		return tenant, nil
+	}
+}
+
+// Invalidate removes the tenant from the cache, e.g. when its metadata has been changed.
+func (c *CachedResolver) Invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries.remove(id)
+	c.negative.remove(id)
+}
+
+// load returns the cached entry if it is not expired.
+func (c *CachedResolver) load(id string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries.get(id)
+	if !ok {
+		entry, ok = c.negative.get(id)
+	}
+
+	return entry, ok && time.Now().Before(entry.expiresAt)
+}
+
+// store caches the entry of the known or the unknown tenant.
+func (c *CachedResolver) store(id string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.err != nil {
+		c.entries.remove(id)
+		c.negative.put(id, entry)
+
+		return
+	}
+
+	c.negative.remove(id)
+	c.entries.put(id, entry)
+}
+
+// cacheLRU represents the bounded cache which evicts the least recently used entries.
+// It is not safe for the concurrent use.
+type cacheLRU struct {
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+}
+
+// cacheItem represents the element of the cacheLRU order.
+type cacheItem struct {
+	key   string
+	entry cacheEntry
+}
+
+func newCacheLRU(maxEntries int) *cacheLRU {
+	return &cacheLRU{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *cacheLRU) get(key string) (cacheEntry, bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return itemOf(elem).entry, true
+}
+
+func (c *cacheLRU) put(key string, entry cacheEntry) {
+	if elem, ok := c.items[key]; ok {
+		itemOf(elem).entry = entry
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem{key: key, entry: entry})
+
+	for c.order.Len() > max(c.maxEntries, 1) {
+		c.remove(itemOf(c.order.Back()).key)
+	}
+}
+
+func (c *cacheLRU) remove(key string) {
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func itemOf(elem *list.Element) *cacheItem {
+	item, _ := elem.Value.(*cacheItem)
+	return item
+}
@@ -0,0 +1,122 @@
+package tenantkit
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/maxatome/go-testdeep/td"
+)
+
+func TestCachedResolver(t *testing.T) {
+	var calls atomic.Int64
+
+	// The tenants are known by the prefix, e.g. t1.
+	resolver := NewCachedResolver(ResolverFunc(func(_ context.Context, id string) (*Tenant, error) {
+		calls.Add(1)
+
+		if !strings.HasPrefix(id, "t") {
+			return nil, ErrTenantUnknown
+		}
+
+		return &Tenant{ID: id}, nil
+	}), WithCacheMaxEntries(2), WithCacheNegativeMaxEntries(2))
+
+	f := func(id string, wantCalls int64) {
+		t.Helper()
+
+		tenant, err := resolver.Resolve(context.Background(), id)
+		if strings.HasPrefix(id, "t") {
+			td.CmpNoError(t, err)
+			td.Cmp(t, tenant, &Tenant{ID: id})
+		} else {
+			td.CmpErrorIs(t, err, ErrTenantUnknown)
+		}
+
+		td.Cmp(t, calls.Load(), wantCalls, "resolve %s", id)
+	}
+
+	f("t1", 1)
+	f("t1", 1)
+	f("u1", 2)
+	f("u1", 2)
+
+	// The unknown tenants do not evict the known ones.
+	f("u2", 3)
+	f("u3", 4)
+	f("t1", 4)
+	f("u1", 5)
+
+	// The least recently used tenant is evicted.
+	f("t2", 6)
+	f("t1", 6)
+	f("t3", 7)
+	f("t1", 7)
+	f("t2", 8)
+
+	resolver.Invalidate("t2")
+	f("t2", 9)
+}
+
+func TestCachedResolver_ttl(t *testing.T) {
+	var calls atomic.Int64
+
+	resolver := NewCachedResolver(newResolver(&calls), WithCacheTTL(time.Millisecond), WithCacheNegativeTTL(time.Millisecond))
+
+	for range 2 {
+		_, err := resolver.Resolve(context.Background(), "acme")
+		td.CmpNoError(t, err)
+
+		_, err = resolver.Resolve(context.Background(), "globex")
+		td.CmpErrorIs(t, err, ErrTenantUnknown)
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	td.Cmp(t, calls.Load(), int64(4))
+}
+
+func TestCachedResolver_canceled(t *testing.T) {
+	var calls atomic.Int64
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	resolver := NewCachedResolver(ResolverFunc(func(ctx context.Context, id string) (*Tenant, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+
+		// The shared lookup is not canceled by the first caller.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return &Tenant{ID: id}, nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+
+	go func() {
+		_, err := resolver.Resolve(ctx, "acme")
+		first <- err
+	}()
+
+	<-started
+
+	second := make(chan *Tenant, 1)
+
+	go func() {
+		tenant, _ := resolver.Resolve(context.Background(), "acme")
+		second <- tenant
+	}()
+
+	cancel()
+	td.CmpErrorIs(t, <-first, context.Canceled)
+
+	close(release)
+	td.Cmp(t, <-second, &Tenant{ID: "acme"})
+	td.Cmp(t, calls.Load(), int64(1))
+}
@@ -0,0 +1,142 @@
+package tenantkit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/grpckit"
+	"github.com/plainq/servekit/httpkit"
+	"google.golang.org/grpc"
+)
+
+// Options represents the options of the tenant middleware and interceptors.
+type Options struct {
+	strategies   []Strategy
+	metricsLabel bool
+}
+
+// Option represents a function type that modifies Options.
+type Option func(o *Options)
+
+// WithStrategies sets the strategies which extract the tenant ID. They are tried
+// in the given order and the first non-empty ID is used. Default is Header("X-Tenant-ID").
+func WithStrategies(strategies ...Strategy) Option {
+	return func(o *Options) { o.strategies = strategies }
+}
+
+// WithoutMetricsLabel disables the tenant label of the request metrics,
+// e.g. when the number of the tenants is too high for the metrics cardinality.
+func WithoutMetricsLabel() Option {
+	return func(o *Options) { o.metricsLabel = false }
+}
+
+func newOptions(options ...Option) *Options {
+	o := Options{
+		strategies:   []Strategy{Header("X-Tenant-ID")},
+		metricsLabel: true,
+	}
+
+	for _, option := range options {
+		option(&o)
+	}
+
+	return &o
+}
+
+// Middleware returns the middleware which resolves the tenant of the request by the resolver.
+// The tenant can be received from the request context by the FromContext, and it is added to
+// the access log line and the labels of the request metrics. Requests without the tenant are
+// rejected with 400, and requests of the unknown tenants are rejected with 404.
+func Middleware(resolver Resolver, options ...Option) httpkit.Middleware {
+	o := newOptions(options...)
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var id string
+
+			for _, strategy := range o.strategies {
+				if id = strategy.FromHTTP(r); id != "" {
+					break
+				}
+			}
+
+			ctx, err := resolve(r.Context(), resolver, id, o)
+			if err != nil {
+				httpkit.ErrorHTTP(w, r, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// UnaryInterceptor returns the gRPC unary server interceptor which resolves the tenant of the call.
+// See Middleware for the details.
+func UnaryInterceptor(resolver Resolver, options ...Option) grpckit.UnaryInterceptor {
+	o := newOptions(options...)
+
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := resolve(ctx, resolver, callTenantID(ctx, o), o)
+		if err != nil {
+			return grpckit.ErrorGRPC[any](ctx, err)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor returns the gRPC stream server interceptor which resolves the tenant of the call.
+// See Middleware for the details.
+func StreamInterceptor(resolver Resolver, options ...Option) grpckit.StreamInterceptor {
+	o := newOptions(options...)
+
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolve(ss.Context(), resolver, callTenantID(ss.Context(), o), o)
+		if err != nil {
+			_, err = grpckit.ErrorGRPC[any](ctx, err)
+			return err
+		}
+
+		return handler(srv, grpckit.WrapServerStream(ctx, ss))
+	}
+}
+
+// callTenantID extracts the tenant ID of the gRPC call.
+func callTenantID(ctx context.Context, o *Options) string {
+	for _, strategy := range o.strategies {
+		if id := strategy.FromGRPC(ctx); id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// resolve resolves the tenant by the ID and returns the context with it.
+func resolve(ctx context.Context, resolver Resolver, id string, o *Options) (context.Context, error) {
+	if id == "" {
+		return ctx, ErrTenantMissing
+	}
+
+	ctxkit.LogAttrs(ctx, slog.String("tenant", id))
+
+	tenant, err := resolver.Resolve(ctx, id)
+	if err != nil {
+		return ctx, err
+	}
+
+	if tenant == nil {
+		return ctx, ErrTenantUnknown
+	}
+
+	if o.metricsLabel {
+		ctxkit.MetricLabel(ctx, "tenant", tenant.ID)
+	}
+
+	return SetTenant(ctx, tenant), nil
+}
@@ -0,0 +1,146 @@
+// Package tenantkit provides the resolution of the tenant of the request for multi-tenant
+// services. The tenant ID is extracted by the pluggable strategies, e.g. from the subdomain,
+// the header or the JWT claim, and the tenant is loaded by the Resolver, usually cached.
+package tenantkit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/plainq/servekit/authkit/jwtkit"
+	"github.com/plainq/servekit/ctxkit"
+	"github.com/plainq/servekit/errkit"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	// ErrTenantMissing indicates that the request does not specify the tenant.
+	ErrTenantMissing = errors.Join(errkit.ErrInvalidArgument, errors.New("tenant is missing"))
+
+	// ErrTenantUnknown indicates that the tenant of the request does not exist.
+	ErrTenantUnknown = errors.Join(errkit.ErrNotFound, errors.New("tenant is unknown"))
+)
+
+// tenantKey represents a Key for context by which
+// the resolved tenant can be received from the context.
+const tenantKey ctxkit.Key = "ctx.tenantkit.tenant"
+
+// Tenant represents the tenant of the request.
+type Tenant struct {
+	// ID is the tenant identifier extracted from the request.
+	ID string
+
+	// Name is the human-readable tenant name.
+	Name string
+
+	// Metadata holds the arbitrary tenant attributes, e.g. the plan or the database.
+	Metadata map[string]string
+}
+
+// SetTenant sets the tenant to the context.
+func SetTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return ctxkit.Set(ctx, tenantKey, tenant)
+}
+
+// FromContext gets the tenant from the context.
+// The second return value reports whether the tenant is present.
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	tenant := ctxkit.Get[*Tenant](ctx, tenantKey)
+
+	return tenant, tenant != nil
+}
+
+// Resolver loads the tenant by its ID.
+type Resolver interface {
+	// Resolve returns the tenant by the given ID.
+	// It returns the error which wraps errkit.ErrNotFound if the tenant does not exist.
+	Resolve(ctx context.Context, id string) (*Tenant, error)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions as Resolver.
+type ResolverFunc func(ctx context.Context, id string) (*Tenant, error)
+
+// Resolve calls f(ctx, id).
+func (f ResolverFunc) Resolve(ctx context.Context, id string) (*Tenant, error) { return f(ctx, id) }
+
+// Strategy extracts the tenant ID from the HTTP request or the gRPC call.
+// Empty string is returned if the request does not carry the ID.
+type Strategy interface {
+	// FromHTTP extracts the tenant ID from the HTTP request.
+	FromHTTP(r *http.Request) string
+
+	// FromGRPC extracts the tenant ID from the gRPC call context.
+	FromGRPC(ctx context.Context) string
+}
+
+// Header returns the Strategy which extracts the tenant ID from the given HTTP header,
+// e.g. X-Tenant-ID, or the gRPC metadata of the same name.
+func Header(name string) Strategy { return headerStrategy(name) }
+
+type headerStrategy string
+
+func (s headerStrategy) FromHTTP(r *http.Request) string { return r.Header.Get(string(s)) }
+
+func (s headerStrategy) FromGRPC(ctx context.Context) string { return metadataValue(ctx, string(s)) }
+
+// Subdomain returns the Strategy which extracts the tenant ID from the subdomain of the given
+// base domain in the Host header or the :authority of the gRPC call, e.g. acme for acme.example.com.
+func Subdomain(baseDomain string) Strategy {
+	return subdomainStrategy("." + strings.Trim(strings.ToLower(baseDomain), "."))
+}
+
+type subdomainStrategy string
+
+func (s subdomainStrategy) FromHTTP(r *http.Request) string { return s.subdomain(r.Host) }
+
+func (s subdomainStrategy) FromGRPC(ctx context.Context) string {
+	return s.subdomain(metadataValue(ctx, ":authority"))
+}
+
+func (s subdomainStrategy) subdomain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	sub, ok := strings.CutSuffix(strings.ToLower(host), string(s))
+	if !ok || sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+
+	return sub
+}
+
+// Claim returns the Strategy which extracts the tenant ID from the verified JWT claims
+// of type T by the given function. The claims must be set to the context by the JWT
+// authentication middleware or interceptor, which must precede the tenant resolution.
+func Claim[T any](fn func(claims *T) string) Strategy { return claimStrategy[T](fn) }
+
+type claimStrategy[T any] func(claims *T) string
+
+func (s claimStrategy[T]) FromHTTP(r *http.Request) string { return s.FromGRPC(r.Context()) }
+
+func (s claimStrategy[T]) FromGRPC(ctx context.Context) string {
+	claims, ok := jwtkit.ClaimsFromContext[T](ctx)
+	if !ok {
+		return ""
+	}
+
+	return s(claims)
+}
+
+// metadataValue returns the first value of the incoming metadata by the given key.
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,98 @@
+package tenantkit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/maxatome/go-testdeep/td"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newResolver(calls *atomic.Int64) Resolver {
+	return ResolverFunc(func(_ context.Context, id string) (*Tenant, error) {
+		calls.Add(1)
+
+		if id != "acme" {
+			return nil, ErrTenantUnknown
+		}
+
+		return &Tenant{ID: id, Name: "Acme"}, nil
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls atomic.Int64
+
+	resolver := NewCachedResolver(newResolver(&calls))
+
+	handler := Middleware(resolver, WithStrategies(Subdomain("example.com"), Header("X-Tenant-ID")))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := FromContext(r.Context())
+			if !ok {
+				panic("tenant is missing in the context")
+			}
+
+			_, _ = w.Write([]byte(tenant.Name))
+		}),
+	)
+
+	f := func(name, host string, header http.Header, want int) {
+		t.Helper()
+
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			r.Host = host
+
+			for key, values := range header {
+				r.Header[key] = values
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			td.Cmp(t, w.Code, want)
+		})
+	}
+
+	f("Subdomain", "acme.example.com:8080", nil, http.StatusOK)
+	f("Header", "example.com", http.Header{"X-Tenant-Id": {"acme"}}, http.StatusOK)
+	f("Nested", "a.acme.example.com", nil, http.StatusBadRequest)
+	f("Missing", "example.com", nil, http.StatusBadRequest)
+	f("Unknown", "globex.example.com", nil, http.StatusNotFound)
+	f("Unknown cached", "globex.example.com", nil, http.StatusNotFound)
+
+	// The known and the unknown tenants are resolved once.
+	td.Cmp(t, calls.Load(), int64(2))
+
+	resolver.Invalidate("acme")
+	f("Invalidated", "acme.example.com", nil, http.StatusOK)
+	td.Cmp(t, calls.Load(), int64(3))
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	var calls atomic.Int64
+
+	interceptor := UnaryInterceptor(newResolver(&calls), WithStrategies(Header("x-tenant-id")))
+	handler := func(ctx context.Context, _ any) (any, error) {
+		tenant, _ := FromContext(ctx)
+		return tenant.Name, nil
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/svc/Method"}
+
+	resp, err := interceptor(metadata.NewIncomingContext(t.Context(), metadata.Pairs("x-tenant-id", "acme")), nil, info, handler)
+	td.CmpNoError(t, err)
+	td.Cmp(t, resp, "Acme")
+
+	_, err = interceptor(metadata.NewIncomingContext(t.Context(), metadata.Pairs("x-tenant-id", "globex")), nil, info, handler)
+	td.Cmp(t, status.Code(err), codes.NotFound)
+
+	_, err = interceptor(t.Context(), nil, info, handler)
+	td.Cmp(t, status.Code(err), codes.InvalidArgument)
+}